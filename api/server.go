@@ -6,9 +6,13 @@ import (
 	"errors"
 	"io"
 	"log"
+	"monkey-pl/ast"
+	"monkey-pl/checker"
 	"monkey-pl/evaluator"
+	"monkey-pl/highlight"
 	"monkey-pl/lexer"
 	"monkey-pl/object"
+	"monkey-pl/optimize"
 	"monkey-pl/parser"
 	"net/http"
 	"os"
@@ -17,6 +21,10 @@ import (
 
 type EvalRequestBody struct {
 	Code string `json:"code"`
+	// Only used by /eval. When it's set the result is formatted with
+	// object.Pretty, so strings are quoted and big values are split
+	// over several lines.
+	Pretty bool `json:"pretty"`
 }
 
 type EvalResponse struct {
@@ -28,6 +36,19 @@ type EvalResponse struct {
 	IsError bool `json:"isError"`
 }
 
+type AstResponse struct {
+	// Encoded with ast.EncodeJSON. See ast/schema.md for the format.
+	// This is null when the code has syntax errors.
+	Ast    json.RawMessage `json:"ast"`
+	Errors []string        `json:"errors"`
+}
+
+type HighlightResponse struct {
+	// Source as HTML with each token wrapped in `<span class="mk-<kind>">`
+	Html   string           `json:"html"`
+	Tokens []highlight.Span `json:"tokens"`
+}
+
 func enableCors(w *http.ResponseWriter) {
 	(*w).Header().Set("Access-Control-Allow-Origin", "*")
 	(*w).Header().Set("Access-Control-Allow-Headers", "Content-Type")
@@ -37,6 +58,8 @@ func enableCors(w *http.ResponseWriter) {
 func Serve() {
 	log.Printf("Running server on port :%d\n", 5150)
 	http.HandleFunc("/eval", handleEvaluate)
+	http.HandleFunc("/ast", handleAst)
+	http.HandleFunc("/highlight", handleHighlight)
 	err := http.ListenAndServe(":5150", nil)
 	if errors.Is(err, http.ErrServerClosed) {
 		log.Println("The server is shutting down...")
@@ -77,10 +100,41 @@ func handleEvaluate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
-	evaluated := evaluator.Eval(program, env)
+	// Type errors are caught before anything runs
+	if typeErrors := checker.Check(program); len(typeErrors) != 0 {
+		messages := []string{}
+		for _, typeError := range typeErrors {
+			messages = append(messages, "type error: "+typeError.String())
+		}
+		response.Result = strings.Join(messages, "\n")
+		response.IsError = true
+		sendJson(w, func() (interface{}, error) {
+			return response, nil
+		})
+		return
+	}
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		response.Result = err.Error()
+		response.IsError = true
+		sendJson(w, func() (interface{}, error) {
+			return response, nil
+		})
+		return
+	}
+	// Each request gets a fresh environment so nothing can have
+	// shadowed the builtins optimize inlines
+	if program, ok := expanded.(*ast.Program); ok {
+		expanded = optimize.Optimize(program)
+	}
+	evaluated := evaluator.Eval(expanded, env)
 	// TODO: Perhaps this should actually return a NULL object.Object
 	if evaluated == nil {
 		response.Result = "NULL"
+	} else if parsedBody.Pretty {
+		response.Result = object.Pretty(evaluated, object.DefaultPrettyOptions)
 	} else {
 		response.Result = evaluated.Inspect()
 	}
@@ -94,6 +148,57 @@ func handleEvaluate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func handleAst(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	if r.Method != "POST" {
+		return
+	}
+	parsedBody := EvalRequestBody{}
+	err := fromJson(r.Body, &parsedBody)
+	if err != nil {
+		sendErr(w, err, 400)
+		return
+	}
+	// Trivia mode so that comments are included in the output
+	prs := parser.New(lexer.NewWithTrivia(parsedBody.Code))
+	program := prs.ParseProgram()
+
+	response := AstResponse{Errors: prs.Errors()}
+	if len(prs.Errors()) == 0 {
+		encoded, err := ast.EncodeJSON(program)
+		if err != nil {
+			sendErr(w, err, 500)
+			return
+		}
+		response.Ast = encoded
+	}
+	sendJson(w, func() (interface{}, error) {
+		return response, nil
+	})
+}
+
+// Highlighting only needs tokens so this works on code that
+// doesn't parse, like whatever is in the playground's editor
+func handleHighlight(w http.ResponseWriter, r *http.Request) {
+	enableCors(&w)
+	if r.Method != "POST" {
+		return
+	}
+	parsedBody := EvalRequestBody{}
+	err := fromJson(r.Body, &parsedBody)
+	if err != nil {
+		sendErr(w, err, 400)
+		return
+	}
+	response := HighlightResponse{
+		Html:   highlight.HTML(parsedBody.Code),
+		Tokens: highlight.Tokenize(parsedBody.Code),
+	}
+	sendJson(w, func() (interface{}, error) {
+		return response, nil
+	})
+}
+
 func fromJson[T any](body io.Reader, target T) error {
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(body)
@@ -21,6 +21,15 @@ type Expression interface {
 	expressionNode()
 }
 
+// Patterns are the things that values can be bound to. Function
+// parameters are patterns, e.g. the `a`, `b = 10` and `...rest`
+// in `fn(a, b = 10, ...rest) {}`, and so are destructuring lets
+// like `let [a, b] = arr;`
+type Pattern interface {
+	Expression
+	patternNode()
+}
+
 // Statement wrapper for expressions
 type ExpressionStatement struct {
 	Token token.Token // first token of expression
@@ -44,6 +53,11 @@ func (es *ExpressionStatement) String() string {
 // This is the root node of every Monkey AST
 type Program struct {
 	Statements []Statement
+	// These are only set when parsing with a lexer in trivia mode.
+	// Tokens is every token in the source (including EOF) and
+	// Comments attaches the source's comments to nodes.
+	Tokens   []token.Token
+	Comments CommentMap
 }
 
 // Basically this points to the first statement in the program
@@ -66,6 +80,9 @@ func (p *Program) String() string {
 type Identifier struct {
 	Value string
 	Token token.Token // IDENT token
+	// Optional type annotation (e.g. `x: int`). Only let names and
+	// parameters can have one and it is nil everywhere else.
+	Type TypeExpr
 }
 
 // This is an empty implementation to help with type checking
@@ -73,12 +90,16 @@ type Identifier struct {
 // the language simple and because they will generally be
 // used in expressions down the line
 func (i *Identifier) expressionNode() {}
+func (i *Identifier) patternNode()    {}
 
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
 func (i *Identifier) String() string {
+	if i.Type != nil {
+		return i.Value + ": " + i.Type.String()
+	}
 	return i.Value
 }
 
@@ -177,7 +198,9 @@ func (i *InfixExpression) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token // `FUNCTION` token
-	Parameters []*Identifier
+	Name       string      // empty for anonymous functions
+	Parameters []Pattern
+	ReturnType TypeExpr // nil when there's no `-> type` annotation
 	Body       *BlockStatement
 }
 
@@ -196,13 +219,174 @@ func (f *FunctionLiteral) String() string {
 	}
 
 	out.WriteString(f.TokenLiteral())
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if f.ReturnType != nil {
+		out.WriteString("-> " + f.ReturnType.String() + " ")
+	}
 	out.WriteString(f.Body.String())
 	return out.String()
 }
 
+// A pattern with a fallback value, e.g. `b = 10` in `fn(a, b = 10) {}`.
+// The default is only evaluated when no value is supplied.
+type AssignmentPattern struct {
+	Token   token.Token // `=` token
+	Target  Pattern
+	Default Expression
+}
+
+func (a *AssignmentPattern) expressionNode() {}
+func (a *AssignmentPattern) patternNode()    {}
+
+func (a *AssignmentPattern) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *AssignmentPattern) String() string {
+	return a.Target.String() + " = " + a.Default.String()
+}
+
+// Collects any remaining values into an array, e.g. `...rest`
+// in `fn(a, ...rest) {}`
+type RestElement struct {
+	Token  token.Token // `...` token
+	Target *Identifier
+}
+
+func (r *RestElement) expressionNode() {}
+func (r *RestElement) patternNode()    {}
+
+func (r *RestElement) TokenLiteral() string {
+	return r.Token.Literal
+}
+
+func (r *RestElement) String() string {
+	return "..." + r.Target.String()
+}
+
+// Destructures an array, e.g. `[a, b = 2, ...rest]`
+type ArrayPattern struct {
+	Token    token.Token // `[` token
+	Elements []Pattern
+}
+
+func (a *ArrayPattern) expressionNode() {}
+func (a *ArrayPattern) patternNode()    {}
+
+func (a *ArrayPattern) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Destructures a hash by its string keys, e.g. `{name, age: years, ...rest}`
+type HashPattern struct {
+	Token      token.Token // `{` token
+	Properties []*HashPatternProperty
+	Rest       *RestElement // nil when there is no `...rest`
+}
+
+// A single `key: pattern` entry in a HashPattern. For the shorthand
+// `{name}` the value is just an identifier with the same name as the key.
+type HashPatternProperty struct {
+	Token token.Token // key token (IDENT or STRING)
+	Key   string
+	Value Pattern
+}
+
+// Shorthand properties are printed as `name` or `name = default`
+func (hp *HashPatternProperty) String() string {
+	target := hp.Value
+	if assignment, ok := target.(*AssignmentPattern); ok {
+		target = assignment.Target
+	}
+	if ident, ok := target.(*Identifier); ok && ident.Value == hp.Key && ident.Type == nil && hp.Token.Type == token.IDENT {
+		return hp.Value.String()
+	}
+	key := hp.Key
+	if hp.Token.Type == token.STRING {
+		key = `"` + key + `"`
+	}
+	return key + ": " + hp.Value.String()
+}
+
+func (h *HashPattern) expressionNode() {}
+func (h *HashPattern) patternNode()    {}
+
+func (h *HashPattern) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+func (h *HashPattern) String() string {
+	properties := []string{}
+	for _, prop := range h.Properties {
+		properties = append(properties, prop.String())
+	}
+	if h.Rest != nil {
+		properties = append(properties, h.Rest.String())
+	}
+	return "{" + strings.Join(properties, ", ") + "}"
+}
+
+// Expands an array in place. Only valid inside of array literals
+// and call arguments, e.g. `[...a, ...b]` or `f(...args)`
+type SpreadElement struct {
+	Token token.Token // `...` token
+	Value Expression
+}
+
+func (s *SpreadElement) expressionNode() {}
+
+func (s *SpreadElement) TokenLiteral() string {
+	return s.Token.Literal
+}
+
+func (s *SpreadElement) String() string {
+	return "..." + s.Value.String()
+}
+
+// `macro(x, y) { ... }`. Macros look like functions but they receive their
+// arguments as unevaluated ASTs (quotes) and return an AST to splice back
+// into the program. They only exist before evaluation; see DefineMacros.
+type MacroLiteral struct {
+	Token      token.Token // `MACRO` token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (m *MacroLiteral) expressionNode() {}
+
+func (m *MacroLiteral) TokenLiteral() string {
+	return m.Token.Literal
+}
+
+func (m *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(m.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(m.Body.String())
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // `[` token
 	Elements []Expression
@@ -229,10 +413,35 @@ func (a *ArrayLiteral) String() string {
 type HashLiteral struct {
 	Token token.Token // `{` token
 	Pairs map[Expression]Expression
+	// The keys of Pairs in the order they appear in the source.
+	// Go maps are unordered so Pairs alone can't tell us this.
+	Keys []Expression
 }
 
 func (h *HashLiteral) expressionNode() {}
 
+// Returns the keys of Pairs in source order. Hash literals that were
+// built by hand without Keys get any missing keys in map order.
+func (h *HashLiteral) OrderedKeys() []Expression {
+	if len(h.Keys) == len(h.Pairs) {
+		return h.Keys
+	}
+	keys := []Expression{}
+	seen := make(map[Expression]bool)
+	for _, key := range h.Keys {
+		if _, ok := h.Pairs[key]; ok && !seen[key] {
+			keys = append(keys, key)
+			seen[key] = true
+		}
+	}
+	for key := range h.Pairs {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 func (h *HashLiteral) TokenLiteral() string {
 	return h.Token.Literal
 }
@@ -240,8 +449,8 @@ func (h *HashLiteral) TokenLiteral() string {
 func (h *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for key, value := range h.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range h.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+h.Pairs[key].String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -351,9 +560,13 @@ Need:
   - reference to associated Token
 */
 type LetStatement struct {
-	Name  *Identifier
-	Value Expression
-	Token token.Token // LET token
+	Name *Identifier
+	// Destructuring lets (e.g. `let [a, b] = arr;`) bind to a pattern
+	// instead of a name. Pattern is nil for plain `let x = ...;` and
+	// Name is nil when Pattern is set.
+	Pattern Pattern
+	Value   Expression
+	Token   token.Token // LET token
 }
 
 // This is an empty implementation to help type checking
@@ -367,7 +580,11 @@ func (let *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(let.TokenLiteral() + " ")
-	out.WriteString(let.Name.String())
+	if let.Pattern != nil {
+		out.WriteString(let.Pattern.String())
+	} else {
+		out.WriteString(let.Name.String())
+	}
 	out.WriteString(" = ")
 	if let.Value != nil {
 		out.WriteString(let.Value.String())
@@ -376,6 +593,25 @@ func (let *LetStatement) String() string {
 	return out.String()
 }
 
+// Named function declarations like `fn add(a, b) { a + b }`.
+// This is mostly sugar for `let add = fn(a, b) { a + b };` but
+// the function keeps its name around for error messages.
+type FunctionStatement struct {
+	Token    token.Token // `FUNCTION` token
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (fs *FunctionStatement) statementNode() {}
+
+func (fs *FunctionStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+
+func (fs *FunctionStatement) String() string {
+	return fs.Function.String()
+}
+
 type ReturnStatement struct {
 	ReturnValue Expression
 	Token       token.Token // RETURN token
@@ -0,0 +1,156 @@
+package ast
+
+// Returns a deep copy of node. Modify changes the tree it's given in
+// place, so anything that needs to rewrite an AST it doesn't own (e.g.
+// `quote` rewriting a function body that will be run again) should copy
+// it first.
+//
+// Type annotations are shared rather than copied since nothing rewrites
+// them. The same goes for a Program's Tokens and Comments.
+func Copy(node Node) Node {
+	if IsNil(node) {
+		return node
+	}
+	switch node := node.(type) {
+	case *Program:
+		copied := *node
+		copied.Statements = copySlice(node.Statements)
+		return &copied
+	case *ExpressionStatement:
+		copied := *node
+		copied.Expression = copyNode(node.Expression)
+		return &copied
+	case *LetStatement:
+		copied := *node
+		copied.Name = copyNode(node.Name)
+		copied.Pattern = copyNode(node.Pattern)
+		copied.Value = copyNode(node.Value)
+		return &copied
+	case *ReturnStatement:
+		copied := *node
+		copied.ReturnValue = copyNode(node.ReturnValue)
+		return &copied
+	case *FunctionStatement:
+		copied := *node
+		copied.Name = copyNode(node.Name)
+		copied.Function = copyNode(node.Function)
+		return &copied
+	case *BlockStatement:
+		copied := *node
+		copied.Statements = copySlice(node.Statements)
+		return &copied
+	case *Identifier:
+		copied := *node
+		return &copied
+	case *IntegerLiteral:
+		copied := *node
+		return &copied
+	case *BooleanLiteral:
+		copied := *node
+		return &copied
+	case *StringLiteral:
+		copied := *node
+		return &copied
+	case *PrefixExpression:
+		copied := *node
+		copied.Right = copyNode(node.Right)
+		return &copied
+	case *InfixExpression:
+		copied := *node
+		copied.Left = copyNode(node.Left)
+		copied.Right = copyNode(node.Right)
+		return &copied
+	case *IndexExpression:
+		copied := *node
+		copied.Left = copyNode(node.Left)
+		copied.Index = copyNode(node.Index)
+		return &copied
+	case *IfExpression:
+		copied := *node
+		copied.Condition = copyNode(node.Condition)
+		copied.Consequence = copyNode(node.Consequence)
+		copied.Alternative = copyNode(node.Alternative)
+		return &copied
+	case *WhileExpression:
+		copied := *node
+		copied.Condition = copyNode(node.Condition)
+		copied.Body = copyNode(node.Body)
+		return &copied
+	case *FunctionLiteral:
+		copied := *node
+		copied.Parameters = copySlice(node.Parameters)
+		copied.Body = copyNode(node.Body)
+		return &copied
+	case *MacroLiteral:
+		copied := *node
+		copied.Parameters = copySlice(node.Parameters)
+		copied.Body = copyNode(node.Body)
+		return &copied
+	case *CallExpression:
+		copied := *node
+		copied.Function = copyNode(node.Function)
+		copied.Arguments = copySlice(node.Arguments)
+		return &copied
+	case *ArrayLiteral:
+		copied := *node
+		copied.Elements = copySlice(node.Elements)
+		return &copied
+	case *HashLiteral:
+		copied := *node
+		copied.Pairs = make(map[Expression]Expression)
+		copied.Keys = []Expression{}
+		for _, key := range node.OrderedKeys() {
+			newKey := copyNode(key)
+			copied.Pairs[newKey] = copyNode(node.Pairs[key])
+			copied.Keys = append(copied.Keys, newKey)
+		}
+		return &copied
+	case *SpreadElement:
+		copied := *node
+		copied.Value = copyNode(node.Value)
+		return &copied
+	case *AssignmentPattern:
+		copied := *node
+		copied.Target = copyNode(node.Target)
+		copied.Default = copyNode(node.Default)
+		return &copied
+	case *RestElement:
+		copied := *node
+		copied.Target = copyNode(node.Target)
+		return &copied
+	case *ArrayPattern:
+		copied := *node
+		copied.Elements = copySlice(node.Elements)
+		return &copied
+	case *HashPattern:
+		copied := *node
+		copied.Properties = nil
+		for _, property := range node.Properties {
+			newProperty := *property
+			newProperty.Value = copyNode(property.Value)
+			copied.Properties = append(copied.Properties, &newProperty)
+		}
+		copied.Rest = copyNode(node.Rest)
+		return &copied
+	}
+	// Type annotations end up here
+	return node
+}
+
+// Copies node and hands it back as the same static type so it can be
+// assigned straight back into a field
+func copyNode[T Node](node T) T {
+	copied, _ := Copy(node).(T)
+	return copied
+}
+
+func copySlice[T Node](nodes []T) []T {
+	if nodes == nil {
+		return nil
+	}
+	copied := make([]T, len(nodes))
+	for i, node := range nodes {
+		copied[i] = copyNode(node)
+	}
+	return copied
+}
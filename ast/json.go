@@ -0,0 +1,589 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"monkey-pl/token"
+	"reflect"
+)
+
+// JSON encoding for ASTs so that tools written in other languages can work
+// with Monkey programs. Every node becomes an object with a "type" field
+// holding the Go type name (e.g. "LetStatement") and a "token" field with
+// the node's token and position. The rest of the fields are described in
+// schema.md. Encoding is lossless: decoding the JSON gives back the same AST.
+
+// Encodes a node (usually a *Program) as JSON. If the program was parsed in
+// trivia mode its tokens are included and comments are put on the nodes
+// they are attached to.
+func EncodeJSON(node Node) ([]byte, error) {
+	var comments CommentMap
+	if program, ok := node.(*Program); ok {
+		comments = program.Comments
+	}
+	e := &jsonEncoder{comments: comments}
+	return json.Marshal(e.node(node))
+}
+
+// Decodes JSON produced by EncodeJSON back into an AST
+func DecodeJSON(data []byte) (Node, error) {
+	d := &jsonDecoder{comments: CommentMap{}}
+	node := d.node(data)
+	if d.err != nil {
+		return nil, d.err
+	}
+	if program, ok := node.(*Program); ok && len(d.comments) > 0 {
+		program.Comments = d.comments
+	}
+	return node, nil
+}
+
+// encoding/json sorts map keys, but having "type" first makes the
+// output much easier to read, so objects are built as ordered lists
+type jsonField struct {
+	key   string
+	value interface{}
+}
+
+type jsonObject []jsonField
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString("{")
+	for i, field := range o {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		key, _ := json.Marshal(field.key)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteString(":")
+		out.Write(value)
+	}
+	out.WriteString("}")
+	return out.Bytes(), nil
+}
+
+type jsonToken struct {
+	Type           token.TokenType `json:"type"`
+	Literal        string          `json:"literal"`
+	Line           int             `json:"line"`
+	Column         int             `json:"column"`
+	Offset         int             `json:"offset"`
+	LeadingTrivia  string          `json:"leadingTrivia,omitempty"`
+	TrailingTrivia string          `json:"trailingTrivia,omitempty"`
+}
+
+func toJSONToken(tok token.Token) jsonToken {
+	return jsonToken(tok)
+}
+
+func fromJSONToken(tok jsonToken) token.Token {
+	return token.Token(tok)
+}
+
+type jsonComment struct {
+	Text    string `json:"text"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Offset  int    `json:"offset"`
+	OwnLine bool   `json:"ownLine"`
+}
+
+type jsonEncoder struct {
+	comments CommentMap
+}
+
+func (e *jsonEncoder) node(node Node) interface{} {
+	if IsNil(node) {
+		return nil
+	}
+	object := jsonObject{{"type", nodeTypeName(node)}}
+	if tok, ok := nodeToken(node); ok {
+		object = append(object, jsonField{"token", toJSONToken(tok)})
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		object = append(object, jsonField{"statements", e.statements(node.Statements)})
+		if node.Tokens != nil {
+			tokens := []jsonToken{}
+			for _, tok := range node.Tokens {
+				tokens = append(tokens, toJSONToken(tok))
+			}
+			object = append(object, jsonField{"tokens", tokens})
+		}
+	case *ExpressionStatement:
+		object = append(object, jsonField{"expression", e.node(node.Expression)})
+	case *LetStatement:
+		object = append(object,
+			jsonField{"name", e.node(node.Name)},
+			jsonField{"pattern", e.node(node.Pattern)},
+			jsonField{"value", e.node(node.Value)},
+		)
+	case *ReturnStatement:
+		object = append(object, jsonField{"returnValue", e.node(node.ReturnValue)})
+	case *FunctionStatement:
+		object = append(object,
+			jsonField{"name", e.node(node.Name)},
+			jsonField{"function", e.node(node.Function)},
+		)
+	case *BlockStatement:
+		object = append(object, jsonField{"statements", e.statements(node.Statements)})
+	case *Identifier:
+		object = append(object, jsonField{"value", node.Value})
+		// most identifiers aren't annotated so this is left
+		// out instead of being null to keep the output small
+		if node.Type != nil {
+			object = append(object, jsonField{"annotation", e.node(node.Type)})
+		}
+	case *IntegerLiteral:
+		object = append(object, jsonField{"value", node.Value})
+	case *BooleanLiteral:
+		object = append(object, jsonField{"value", node.Value})
+	case *StringLiteral:
+		object = append(object, jsonField{"value", node.Value})
+	case *PrefixExpression:
+		object = append(object,
+			jsonField{"operator", node.Operator},
+			jsonField{"right", e.node(node.Right)},
+		)
+	case *InfixExpression:
+		object = append(object,
+			jsonField{"left", e.node(node.Left)},
+			jsonField{"operator", node.Operator},
+			jsonField{"right", e.node(node.Right)},
+		)
+	case *FunctionLiteral:
+		object = append(object,
+			jsonField{"name", node.Name},
+			jsonField{"parameters", e.patterns(node.Parameters)},
+			jsonField{"returnType", e.node(node.ReturnType)},
+			jsonField{"body", e.node(node.Body)},
+		)
+	case *MacroLiteral:
+		parameters := []interface{}{}
+		for _, parameter := range node.Parameters {
+			parameters = append(parameters, e.node(parameter))
+		}
+		object = append(object,
+			jsonField{"parameters", parameters},
+			jsonField{"body", e.node(node.Body)},
+		)
+	case *AssignmentPattern:
+		object = append(object,
+			jsonField{"target", e.node(node.Target)},
+			jsonField{"default", e.node(node.Default)},
+		)
+	case *RestElement:
+		object = append(object, jsonField{"target", e.node(node.Target)})
+	case *ArrayPattern:
+		object = append(object, jsonField{"elements", e.patterns(node.Elements)})
+	case *HashPattern:
+		properties := []interface{}{}
+		for _, property := range node.Properties {
+			properties = append(properties, jsonObject{
+				{"token", toJSONToken(property.Token)},
+				{"key", property.Key},
+				{"value", e.node(property.Value)},
+			})
+		}
+		object = append(object,
+			jsonField{"properties", properties},
+			jsonField{"rest", e.node(node.Rest)},
+		)
+	case *SpreadElement:
+		object = append(object, jsonField{"value", e.node(node.Value)})
+	case *ArrayLiteral:
+		object = append(object, jsonField{"elements", e.expressions(node.Elements)})
+	case *HashLiteral:
+		// pairs are a list instead of an object since keys can be any
+		// expression. The list is in source order.
+		pairs := []interface{}{}
+		for _, key := range node.OrderedKeys() {
+			pairs = append(pairs, jsonObject{
+				{"key", e.node(key)},
+				{"value", e.node(node.Pairs[key])},
+			})
+		}
+		object = append(object, jsonField{"pairs", pairs})
+	case *IfExpression:
+		object = append(object,
+			jsonField{"condition", e.node(node.Condition)},
+			jsonField{"consequence", e.node(node.Consequence)},
+			jsonField{"alternative", e.node(node.Alternative)},
+		)
+	case *WhileExpression:
+		object = append(object,
+			jsonField{"condition", e.node(node.Condition)},
+			jsonField{"body", e.node(node.Body)},
+		)
+	case *CallExpression:
+		object = append(object,
+			jsonField{"function", e.node(node.Function)},
+			jsonField{"arguments", e.expressions(node.Arguments)},
+		)
+	case *IndexExpression:
+		object = append(object,
+			jsonField{"left", e.node(node.Left)},
+			jsonField{"index", e.node(node.Index)},
+		)
+	case *NamedType:
+		object = append(object, jsonField{"name", node.Name})
+	case *ArrayType:
+		object = append(object, jsonField{"element", e.node(node.Element)})
+	case *HashType:
+		object = append(object,
+			jsonField{"key", e.node(node.Key)},
+			jsonField{"value", e.node(node.Value)},
+		)
+	case *FunctionType:
+		parameters := []interface{}{}
+		for _, parameter := range node.Parameters {
+			parameters = append(parameters, e.node(parameter))
+		}
+		object = append(object,
+			jsonField{"parameters", parameters},
+			jsonField{"return", e.node(node.Return)},
+		)
+	}
+
+	if comments := e.comments[node]; len(comments) > 0 {
+		encoded := []jsonComment{}
+		for _, comment := range comments {
+			encoded = append(encoded, jsonComment(comment))
+		}
+		object = append(object, jsonField{"comments", encoded})
+	}
+	return object
+}
+
+func (e *jsonEncoder) statements(statements []Statement) []interface{} {
+	encoded := []interface{}{}
+	for _, statement := range statements {
+		encoded = append(encoded, e.node(statement))
+	}
+	return encoded
+}
+
+func (e *jsonEncoder) expressions(expressions []Expression) []interface{} {
+	encoded := []interface{}{}
+	for _, expression := range expressions {
+		encoded = append(encoded, e.node(expression))
+	}
+	return encoded
+}
+
+func (e *jsonEncoder) patterns(patterns []Pattern) []interface{} {
+	encoded := []interface{}{}
+	for _, pattern := range patterns {
+		encoded = append(encoded, e.node(pattern))
+	}
+	return encoded
+}
+
+func nodeTypeName(node Node) string {
+	return reflect.TypeOf(node).Elem().Name()
+}
+
+// Every node except Program has a Token field
+func nodeToken(node Node) (token.Token, bool) {
+	field := reflect.ValueOf(node).Elem().FieldByName("Token")
+	if !field.IsValid() {
+		return token.Token{}, false
+	}
+	return field.Interface().(token.Token), true
+}
+
+// The decoder holds on to the first error it runs into and every method
+// becomes a no-op after that. This saves us from checking for an error
+// after decoding every single field.
+type jsonDecoder struct {
+	comments CommentMap
+	err      error
+}
+
+func (d *jsonDecoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
+
+func (d *jsonDecoder) unmarshal(data json.RawMessage, target interface{}) {
+	if d.err != nil || data == nil {
+		return
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		d.fail("invalid AST JSON: %s", err)
+	}
+}
+
+func (d *jsonDecoder) node(data json.RawMessage) Node {
+	if d.err != nil || data == nil || string(data) == "null" {
+		return nil
+	}
+	fields := map[string]json.RawMessage{}
+	d.unmarshal(data, &fields)
+	var typeName string
+	d.unmarshal(fields["type"], &typeName)
+	var jsonTok jsonToken
+	d.unmarshal(fields["token"], &jsonTok)
+	tok := fromJSONToken(jsonTok)
+	if d.err != nil {
+		return nil
+	}
+
+	var node Node
+	switch typeName {
+	case "Program":
+		program := &Program{Statements: d.statements(fields["statements"])}
+		if fields["tokens"] != nil {
+			jsonTokens := []jsonToken{}
+			d.unmarshal(fields["tokens"], &jsonTokens)
+			program.Tokens = []token.Token{}
+			for _, tok := range jsonTokens {
+				program.Tokens = append(program.Tokens, fromJSONToken(tok))
+			}
+		}
+		node = program
+	case "ExpressionStatement":
+		node = &ExpressionStatement{Token: tok, Expression: d.expression(fields["expression"])}
+	case "LetStatement":
+		node = &LetStatement{
+			Token:   tok,
+			Name:    d.identifier(fields["name"]),
+			Pattern: d.pattern(fields["pattern"]),
+			Value:   d.expression(fields["value"]),
+		}
+	case "ReturnStatement":
+		node = &ReturnStatement{Token: tok, ReturnValue: d.expression(fields["returnValue"])}
+	case "FunctionStatement":
+		statement := &FunctionStatement{Token: tok, Name: d.identifier(fields["name"])}
+		statement.Function, _ = d.node(fields["function"]).(*FunctionLiteral)
+		node = statement
+	case "BlockStatement":
+		node = &BlockStatement{Token: tok, Statements: d.statements(fields["statements"])}
+	case "Identifier":
+		identifier := &Identifier{Token: tok, Type: d.typeExpr(fields["annotation"])}
+		d.unmarshal(fields["value"], &identifier.Value)
+		node = identifier
+	case "IntegerLiteral":
+		integer := &IntegerLiteral{Token: tok}
+		d.unmarshal(fields["value"], &integer.Value)
+		node = integer
+	case "BooleanLiteral":
+		boolean := &BooleanLiteral{Token: tok}
+		d.unmarshal(fields["value"], &boolean.Value)
+		node = boolean
+	case "StringLiteral":
+		str := &StringLiteral{Token: tok}
+		d.unmarshal(fields["value"], &str.Value)
+		node = str
+	case "PrefixExpression":
+		prefix := &PrefixExpression{Token: tok, Right: d.expression(fields["right"])}
+		d.unmarshal(fields["operator"], &prefix.Operator)
+		node = prefix
+	case "InfixExpression":
+		infix := &InfixExpression{
+			Token: tok,
+			Left:  d.expression(fields["left"]),
+			Right: d.expression(fields["right"]),
+		}
+		d.unmarshal(fields["operator"], &infix.Operator)
+		node = infix
+	case "FunctionLiteral":
+		function := &FunctionLiteral{
+			Token:      tok,
+			Parameters: d.patterns(fields["parameters"]),
+			ReturnType: d.typeExpr(fields["returnType"]),
+			Body:       d.block(fields["body"]),
+		}
+		d.unmarshal(fields["name"], &function.Name)
+		node = function
+	case "MacroLiteral":
+		macro := &MacroLiteral{Token: tok, Parameters: []*Identifier{}, Body: d.block(fields["body"])}
+		for _, parameter := range d.list(fields["parameters"]) {
+			macro.Parameters = append(macro.Parameters, d.identifier(parameter))
+		}
+		node = macro
+	case "AssignmentPattern":
+		node = &AssignmentPattern{
+			Token:   tok,
+			Target:  d.pattern(fields["target"]),
+			Default: d.expression(fields["default"]),
+		}
+	case "RestElement":
+		node = &RestElement{Token: tok, Target: d.identifier(fields["target"])}
+	case "ArrayPattern":
+		node = &ArrayPattern{Token: tok, Elements: d.patterns(fields["elements"])}
+	case "HashPattern":
+		hash := &HashPattern{Token: tok, Properties: []*HashPatternProperty{}}
+		for _, data := range d.list(fields["properties"]) {
+			propertyFields := map[string]json.RawMessage{}
+			d.unmarshal(data, &propertyFields)
+			var propertyTok jsonToken
+			d.unmarshal(propertyFields["token"], &propertyTok)
+			property := &HashPatternProperty{Token: fromJSONToken(propertyTok), Value: d.pattern(propertyFields["value"])}
+			d.unmarshal(propertyFields["key"], &property.Key)
+			hash.Properties = append(hash.Properties, property)
+		}
+		hash.Rest, _ = d.node(fields["rest"]).(*RestElement)
+		node = hash
+	case "SpreadElement":
+		node = &SpreadElement{Token: tok, Value: d.expression(fields["value"])}
+	case "ArrayLiteral":
+		node = &ArrayLiteral{Token: tok, Elements: d.expressions(fields["elements"])}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: tok, Pairs: map[Expression]Expression{}, Keys: []Expression{}}
+		for _, data := range d.list(fields["pairs"]) {
+			pairFields := map[string]json.RawMessage{}
+			d.unmarshal(data, &pairFields)
+			key := d.expression(pairFields["key"])
+			hash.Pairs[key] = d.expression(pairFields["value"])
+			hash.Keys = append(hash.Keys, key)
+		}
+		node = hash
+	case "IfExpression":
+		node = &IfExpression{
+			Token:       tok,
+			Condition:   d.expression(fields["condition"]),
+			Consequence: d.block(fields["consequence"]),
+			Alternative: d.block(fields["alternative"]),
+		}
+	case "WhileExpression":
+		node = &WhileExpression{Token: tok, Condition: d.expression(fields["condition"]), Body: d.block(fields["body"])}
+	case "CallExpression":
+		node = &CallExpression{
+			Token:     tok,
+			Function:  d.expression(fields["function"]),
+			Arguments: d.expressions(fields["arguments"]),
+		}
+	case "IndexExpression":
+		node = &IndexExpression{Token: tok, Left: d.expression(fields["left"]), Index: d.expression(fields["index"])}
+	case "NamedType":
+		named := &NamedType{Token: tok}
+		d.unmarshal(fields["name"], &named.Name)
+		node = named
+	case "ArrayType":
+		node = &ArrayType{Token: tok, Element: d.typeExpr(fields["element"])}
+	case "HashType":
+		node = &HashType{Token: tok, Key: d.typeExpr(fields["key"]), Value: d.typeExpr(fields["value"])}
+	case "FunctionType":
+		function := &FunctionType{Token: tok, Parameters: []TypeExpr{}, Return: d.typeExpr(fields["return"])}
+		for _, parameter := range d.list(fields["parameters"]) {
+			function.Parameters = append(function.Parameters, d.typeExpr(parameter))
+		}
+		node = function
+	default:
+		d.fail("unknown AST node type %q", typeName)
+		return nil
+	}
+
+	if fields["comments"] != nil {
+		comments := []jsonComment{}
+		d.unmarshal(fields["comments"], &comments)
+		for _, comment := range comments {
+			d.comments[node] = append(d.comments[node], Comment(comment))
+		}
+	}
+	return node
+}
+
+func (d *jsonDecoder) list(data json.RawMessage) []json.RawMessage {
+	list := []json.RawMessage{}
+	d.unmarshal(data, &list)
+	return list
+}
+
+func (d *jsonDecoder) statements(data json.RawMessage) []Statement {
+	statements := []Statement{}
+	for _, item := range d.list(data) {
+		node := d.node(item)
+		statement, ok := node.(Statement)
+		if !ok && node != nil {
+			d.fail("expected a statement, got %s", nodeTypeName(node))
+		}
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+func (d *jsonDecoder) expressions(data json.RawMessage) []Expression {
+	expressions := []Expression{}
+	for _, item := range d.list(data) {
+		expressions = append(expressions, d.expression(item))
+	}
+	return expressions
+}
+
+func (d *jsonDecoder) patterns(data json.RawMessage) []Pattern {
+	patterns := []Pattern{}
+	for _, item := range d.list(data) {
+		patterns = append(patterns, d.pattern(item))
+	}
+	return patterns
+}
+
+func (d *jsonDecoder) expression(data json.RawMessage) Expression {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	expression, ok := node.(Expression)
+	if !ok {
+		d.fail("expected an expression, got %s", nodeTypeName(node))
+	}
+	return expression
+}
+
+func (d *jsonDecoder) pattern(data json.RawMessage) Pattern {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	pattern, ok := node.(Pattern)
+	if !ok {
+		d.fail("expected a pattern, got %s", nodeTypeName(node))
+	}
+	return pattern
+}
+
+func (d *jsonDecoder) typeExpr(data json.RawMessage) TypeExpr {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	typeExpr, ok := node.(TypeExpr)
+	if !ok {
+		d.fail("expected a type, got %s", nodeTypeName(node))
+	}
+	return typeExpr
+}
+
+func (d *jsonDecoder) identifier(data json.RawMessage) *Identifier {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	identifier, ok := node.(*Identifier)
+	if !ok {
+		d.fail("expected an Identifier, got %s", nodeTypeName(node))
+	}
+	return identifier
+}
+
+func (d *jsonDecoder) block(data json.RawMessage) *BlockStatement {
+	node := d.node(data)
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail("expected a BlockStatement, got %s", nodeTypeName(node))
+	}
+	return block
+}
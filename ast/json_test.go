@@ -0,0 +1,112 @@
+package ast_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/parser"
+	"monkey-pl/parser/parsertest"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	input := "# every node\n" + everyNode + "let h = {\"b\": 2, \"a\": 1}; # hash\nh[\"a\"]\n"
+	pars := parser.New(lexer.NewWithTrivia(input))
+	program := pars.ParseProgram()
+	if len(pars.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", pars.Errors())
+	}
+
+	encoded, err := ast.EncodeJSON(program)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+	decoded, err := ast.DecodeJSON(encoded)
+	if err != nil {
+		t.Fatalf("DecodeJSON returned error: %s", err)
+	}
+
+	decodedProgram, ok := decoded.(*ast.Program)
+	if !ok {
+		t.Fatalf("Expected *ast.Program. Got %T", decoded)
+	}
+	if decodedProgram.String() != program.String() {
+		t.Errorf("String() differs after round trip.\nExpected: %s\nGot: %s", program.String(), decodedProgram.String())
+	}
+	if decodedProgram.Source() != input {
+		t.Errorf("Source() differs after round trip. Got %q", decodedProgram.Source())
+	}
+	if len(decodedProgram.Comments) != len(program.Comments) {
+		t.Errorf("Expected comments on %d nodes. Got %d", len(program.Comments), len(decodedProgram.Comments))
+	}
+
+	reencoded, err := ast.EncodeJSON(decodedProgram)
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+	if !bytes.Equal(encoded, reencoded) {
+		t.Errorf("JSON differs after round trip.\nFirst: %s\nSecond: %s", encoded, reencoded)
+	}
+}
+
+func TestJSONShape(t *testing.T) {
+	program := parsertest.Parse(t, "let x = 5;")
+	encoded, err := ast.EncodeJSON(program.Statements[0])
+	if err != nil {
+		t.Fatalf("EncodeJSON returned error: %s", err)
+	}
+	if !strings.HasPrefix(string(encoded), `{"type":"LetStatement",`) {
+		t.Errorf("Expected the type field to come first. Got %s", encoded)
+	}
+
+	var decoded struct {
+		Type  string `json:"type"`
+		Token struct {
+			Type   string `json:"type"`
+			Line   int    `json:"line"`
+			Column int    `json:"column"`
+		} `json:"token"`
+		Value struct {
+			Type  string `json:"type"`
+			Value int64  `json:"value"`
+		} `json:"value"`
+		Pattern interface{} `json:"pattern"`
+	}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Could not unmarshal %s: %s", encoded, err)
+	}
+	if decoded.Token.Type != "LET" || decoded.Token.Line != 1 || decoded.Token.Column != 1 {
+		t.Errorf("Wrong token: %+v", decoded.Token)
+	}
+	if decoded.Value.Type != "IntegerLiteral" || decoded.Value.Value != 5 {
+		t.Errorf("Wrong value: %+v", decoded.Value)
+	}
+	if decoded.Pattern != nil {
+		t.Errorf("Expected pattern to be null. Got %v", decoded.Pattern)
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"type": "Nonsense"}`, `unknown AST node type "Nonsense"`},
+		{`{"type": "ExpressionStatement", "expression": {"type": "ReturnStatement"}}`, "expected an expression, got ReturnStatement"},
+		{`{"type": "Identifier", "value": 5}`, "invalid AST JSON"},
+		{`[1, 2]`, "invalid AST JSON"},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.DecodeJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("Expected an error decoding %s", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing %q. Got %q", tt.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Walks the AST depth first, replacing every node with the result of
+// calling modifier on it. Children are modified before their parents,
+// so the modifier sees a node whose children have already been replaced.
+//
+// Note: if the modifier returns a node of the wrong kind for its position
+// (e.g. a statement where an expression is expected) the field is set to
+// nil, the same as an unsuccessful type assertion would.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	case *ExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+	case *LetStatement:
+		if node.Name != nil {
+			node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		}
+		if node.Pattern != nil {
+			node.Pattern, _ = Modify(node.Pattern, modifier).(Pattern)
+		}
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *ReturnStatement:
+		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+	case *FunctionStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		node.Function, _ = Modify(node.Function, modifier).(*FunctionLiteral)
+	case *BlockStatement:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+	case *PrefixExpression:
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *InfixExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *IndexExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+	case *IfExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+	case *WhileExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i], _ = Modify(param, modifier).(Pattern)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *MacroLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i], _ = Modify(param, modifier).(*Identifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, arg := range node.Arguments {
+			node.Arguments[i], _ = Modify(arg, modifier).(Expression)
+		}
+	case *ArrayLiteral:
+		for i, element := range node.Elements {
+			node.Elements[i], _ = Modify(element, modifier).(Expression)
+		}
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression)
+		keys := []Expression{}
+		for _, key := range node.OrderedKeys() {
+			newKey, _ := Modify(key, modifier).(Expression)
+			newValue, _ := Modify(node.Pairs[key], modifier).(Expression)
+			pairs[newKey] = newValue
+			keys = append(keys, newKey)
+		}
+		node.Pairs = pairs
+		node.Keys = keys
+	case *SpreadElement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *AssignmentPattern:
+		node.Target, _ = Modify(node.Target, modifier).(Pattern)
+		node.Default, _ = Modify(node.Default, modifier).(Expression)
+	case *RestElement:
+		node.Target, _ = Modify(node.Target, modifier).(*Identifier)
+	case *ArrayPattern:
+		for i, element := range node.Elements {
+			node.Elements[i], _ = Modify(element, modifier).(Pattern)
+		}
+	case *HashPattern:
+		for _, property := range node.Properties {
+			property.Value, _ = Modify(property.Value, modifier).(Pattern)
+		}
+		if node.Rest != nil {
+			node.Rest, _ = Modify(node.Rest, modifier).(*RestElement)
+		}
+	}
+	// Identifiers and literals have no children so they fall through
+	// to here and are just handed to the modifier
+	return modifier(node)
+}
@@ -0,0 +1,209 @@
+package ast
+
+import (
+	"monkey-pl/token"
+	"reflect"
+	"testing"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		if integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&WhileExpression{
+				Condition: one(),
+				Body:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&WhileExpression{
+				Condition: two(),
+				Body:      &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&ReturnStatement{ReturnValue: one()},
+			&ReturnStatement{ReturnValue: two()},
+		},
+		{
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&FunctionLiteral{
+				Parameters: []Pattern{&AssignmentPattern{Target: &Identifier{Value: "a"}, Default: one()}},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&FunctionLiteral{
+				Parameters: []Pattern{&AssignmentPattern{Target: &Identifier{Value: "a"}, Default: two()}},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&FunctionStatement{
+				Name: &Identifier{Value: "f"},
+				Function: &FunctionLiteral{
+					Name:       "f",
+					Parameters: []Pattern{},
+					Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				},
+			},
+			&FunctionStatement{
+				Name: &Identifier{Value: "f"},
+				Function: &FunctionLiteral{
+					Name:       "f",
+					Parameters: []Pattern{},
+					Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				},
+			},
+		},
+		{
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&MacroLiteral{
+				Parameters: []*Identifier{},
+				Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), &SpreadElement{Value: one()}}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), &SpreadElement{Value: two()}}},
+		},
+		{
+			&ArrayLiteral{Elements: []Expression{one(), one()}},
+			&ArrayLiteral{Elements: []Expression{two(), two()}},
+		},
+		{
+			&LetStatement{
+				Pattern: &ArrayPattern{Elements: []Pattern{
+					&AssignmentPattern{Target: &Identifier{Value: "a"}, Default: one()},
+					&RestElement{Target: &Identifier{Value: "rest"}},
+				}},
+				Value: one(),
+			},
+			&LetStatement{
+				Pattern: &ArrayPattern{Elements: []Pattern{
+					&AssignmentPattern{Target: &Identifier{Value: "a"}, Default: two()},
+					&RestElement{Target: &Identifier{Value: "rest"}},
+				}},
+				Value: two(),
+			},
+		},
+		{
+			&HashPattern{Properties: []*HashPatternProperty{
+				{Key: "a", Value: &AssignmentPattern{Target: &Identifier{Value: "a"}, Default: one()}},
+			}},
+			&HashPattern{Properties: []*HashPatternProperty{
+				{Key: "a", Value: &AssignmentPattern{Target: &Identifier{Value: "a"}, Default: two()}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal. Got %#v, expected %#v", modified, tt.expected)
+		}
+	}
+
+	hashLiteral := &HashLiteral{
+		Pairs: map[Expression]Expression{
+			one(): one(),
+			one(): one(),
+		},
+	}
+	Modify(hashLiteral, turnOneIntoTwo)
+	for key, val := range hashLiteral.Pairs {
+		key, _ := key.(*IntegerLiteral)
+		if key.Value != 2 {
+			t.Errorf("value is %d, expected %d", key.Value, 2)
+		}
+		val, _ := val.(*IntegerLiteral)
+		if val.Value != 2 {
+			t.Errorf("value is %d, expected %d", val.Value, 2)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1} }
+	original := &Program{Statements: []Statement{
+		&ExpressionStatement{Expression: &InfixExpression{
+			Left:     one(),
+			Operator: "+",
+			Right:    &CallExpression{Function: &Identifier{Token: token.Token{Type: token.IDENT, Literal: "f"}, Value: "f"}, Arguments: []Expression{one()}},
+		}},
+		&LetStatement{Name: &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"}, Value: &HashLiteral{
+			Pairs: map[Expression]Expression{one(): one()},
+		}},
+	}}
+	expected := original.String()
+
+	copied := Copy(original)
+	if copied.String() != expected {
+		t.Fatalf("copy is not equal. Got %q, expected %q", copied.String(), expected)
+	}
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Token.Literal = "2"
+			integer.Value = 2
+		}
+		return node
+	})
+	if original.String() != expected {
+		t.Errorf("modifying the copy changed the original. Got %q, expected %q", original.String(), expected)
+	}
+	if copied.String() == expected {
+		t.Errorf("copy was not modified: %q", copied.String())
+	}
+}
@@ -0,0 +1,45 @@
+package ast
+
+import (
+	"bytes"
+	"monkey-pl/token"
+)
+
+// A comment the parser kept while parsing in trivia mode
+type Comment struct {
+	Text   string // includes the leading `#`
+	Line   int
+	Column int
+	Offset int
+	// OwnLine is false when the comment trails code on the same line
+	OwnLine bool
+}
+
+// Maps nodes to the comments attached to them. Comments go to the
+// nearest statement: a comment on the line(s) before a statement belongs
+// to that statement and a comment at the end of a line belongs to the
+// statement that ends there. Comments that aren't near any statement
+// (e.g. at the end of a file) are attached to the Program.
+type CommentMap map[Node][]Comment
+
+// Prints the program back out exactly as it was written. This only works
+// when the program was parsed from a lexer in trivia mode, since otherwise
+// we don't have the whitespace and comments. Returns "" if that isn't the case.
+func (p *Program) Source() string {
+	var out bytes.Buffer
+	for _, tok := range p.Tokens {
+		out.WriteString(tok.LeadingTrivia)
+		out.WriteString(tokenSource(tok))
+		out.WriteString(tok.TrailingTrivia)
+	}
+	return out.String()
+}
+
+// Most token literals are the source text but strings lose their quotes.
+// There are no escape sequences so adding the quotes back is enough.
+func tokenSource(tok token.Token) string {
+	if tok.Type == token.STRING {
+		return `"` + tok.Literal + `"`
+	}
+	return tok.Literal
+}
@@ -0,0 +1,90 @@
+package ast
+
+import (
+	"bytes"
+	"monkey-pl/token"
+	"strings"
+)
+
+// Type annotations are optional and the evaluator ignores them. They are
+// only used by the type checker. The syntax is:
+//
+//	int, string, bool, null, any   named types
+//	[int]                          an array of ints
+//	{string: int}                  a hash from strings to ints
+//	fn(int, string) -> bool        a function
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+type NamedType struct {
+	Token token.Token // IDENT token
+	Name  string
+}
+
+func (n *NamedType) typeNode() {}
+
+func (n *NamedType) TokenLiteral() string {
+	return n.Token.Literal
+}
+
+func (n *NamedType) String() string {
+	return n.Name
+}
+
+type ArrayType struct {
+	Token   token.Token // `[` token
+	Element TypeExpr
+}
+
+func (a *ArrayType) typeNode() {}
+
+func (a *ArrayType) TokenLiteral() string {
+	return a.Token.Literal
+}
+
+func (a *ArrayType) String() string {
+	return "[" + a.Element.String() + "]"
+}
+
+type HashType struct {
+	Token token.Token // `{` token
+	Key   TypeExpr
+	Value TypeExpr
+}
+
+func (h *HashType) typeNode() {}
+
+func (h *HashType) TokenLiteral() string {
+	return h.Token.Literal
+}
+
+func (h *HashType) String() string {
+	return "{" + h.Key.String() + ": " + h.Value.String() + "}"
+}
+
+type FunctionType struct {
+	Token      token.Token // `fn` token
+	Parameters []TypeExpr
+	Return     TypeExpr // nil when there's no `-> type`
+}
+
+func (f *FunctionType) typeNode() {}
+
+func (f *FunctionType) TokenLiteral() string {
+	return f.Token.Literal
+}
+
+func (f *FunctionType) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, param := range f.Parameters {
+		params = append(params, param.String())
+	}
+	out.WriteString("fn(" + strings.Join(params, ", ") + ")")
+	if f.Return != nil {
+		out.WriteString(" -> " + f.Return.String())
+	}
+	return out.String()
+}
@@ -0,0 +1,192 @@
+package ast
+
+import "reflect"
+
+// A Visitor's Enter method is called for every node encountered by Walk.
+// If the visitor w returned by Enter is not nil, Walk visits each of the
+// children of node with w and then calls w.Exit(node). Returning nil from
+// Enter skips the node's children (and its Exit).
+//
+// This is modelled after `go/ast` but with an explicit exit hook instead
+// of go/ast's convention of calling Visit(nil).
+type Visitor interface {
+	Enter(node Node) (w Visitor)
+	Exit(node Node)
+}
+
+// Traverses an AST in depth-first order, visiting children in the order
+// they appear in the source. Nil children (e.g. an if without an else)
+// are skipped. Unlike Modify, Walk never changes the tree.
+func Walk(node Node, v Visitor) {
+	if IsNil(node) {
+		return
+	}
+	w := v.Enter(node)
+	if w == nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		walkStatements(node.Statements, w)
+	case *ExpressionStatement:
+		walkExpression(node.Expression, w)
+	case *LetStatement:
+		if node.Name != nil {
+			Walk(node.Name, w)
+		}
+		if node.Pattern != nil {
+			Walk(node.Pattern, w)
+		}
+		walkExpression(node.Value, w)
+	case *ReturnStatement:
+		walkExpression(node.ReturnValue, w)
+	case *FunctionStatement:
+		Walk(node.Name, w)
+		if node.Function != nil {
+			Walk(node.Function, w)
+		}
+	case *BlockStatement:
+		walkStatements(node.Statements, w)
+	case *PrefixExpression:
+		walkExpression(node.Right, w)
+	case *InfixExpression:
+		walkExpression(node.Left, w)
+		walkExpression(node.Right, w)
+	case *IndexExpression:
+		walkExpression(node.Left, w)
+		walkExpression(node.Index, w)
+	case *IfExpression:
+		walkExpression(node.Condition, w)
+		if node.Consequence != nil {
+			Walk(node.Consequence, w)
+		}
+		if node.Alternative != nil {
+			Walk(node.Alternative, w)
+		}
+	case *WhileExpression:
+		walkExpression(node.Condition, w)
+		if node.Body != nil {
+			Walk(node.Body, w)
+		}
+	case *Identifier:
+		walkType(node.Type, w)
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			walkExpression(param, w)
+		}
+		walkType(node.ReturnType, w)
+		if node.Body != nil {
+			Walk(node.Body, w)
+		}
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			Walk(param, w)
+		}
+		if node.Body != nil {
+			Walk(node.Body, w)
+		}
+	case *CallExpression:
+		walkExpression(node.Function, w)
+		walkExpressions(node.Arguments, w)
+	case *ArrayLiteral:
+		walkExpressions(node.Elements, w)
+	case *HashLiteral:
+		for _, key := range node.OrderedKeys() {
+			walkExpression(key, w)
+			walkExpression(node.Pairs[key], w)
+		}
+	case *SpreadElement:
+		walkExpression(node.Value, w)
+	case *AssignmentPattern:
+		walkExpression(node.Target, w)
+		walkExpression(node.Default, w)
+	case *RestElement:
+		if node.Target != nil {
+			Walk(node.Target, w)
+		}
+	case *ArrayPattern:
+		for _, element := range node.Elements {
+			walkExpression(element, w)
+		}
+	case *HashPattern:
+		for _, property := range node.Properties {
+			walkExpression(property.Value, w)
+		}
+		if node.Rest != nil {
+			Walk(node.Rest, w)
+		}
+	case *ArrayType:
+		walkType(node.Element, w)
+	case *HashType:
+		walkType(node.Key, w)
+		walkType(node.Value, w)
+	case *FunctionType:
+		for _, param := range node.Parameters {
+			walkType(param, w)
+		}
+		walkType(node.Return, w)
+	}
+	// Literals and named types have no children
+
+	w.Exit(node)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Enter(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+func (f inspector) Exit(node Node) {
+	f(nil)
+}
+
+// Traverses an AST in depth-first order: It starts by calling f(node).
+// If f returns true, Inspect invokes f recursively for each of the
+// non-nil children of node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
+
+// Expressions and statements are interfaces so a nil child can show up
+// as a nil interface. Those get skipped here.
+func walkExpression(expr Expression, v Visitor) {
+	if expr != nil {
+		Walk(expr, v)
+	}
+}
+
+func walkType(typeExpr TypeExpr, v Visitor) {
+	if typeExpr != nil {
+		Walk(typeExpr, v)
+	}
+}
+
+func walkExpressions(exprs []Expression, v Visitor) {
+	for _, expr := range exprs {
+		walkExpression(expr, v)
+	}
+}
+
+func walkStatements(statements []Statement, v Visitor) {
+	for _, statement := range statements {
+		if statement != nil {
+			Walk(statement, v)
+		}
+	}
+}
+
+// Every node is a pointer so a nil *Identifier stored in an Expression
+// isn't == nil. Failed parses leave both kinds of nil in the tree, so this
+// catches both.
+func IsNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
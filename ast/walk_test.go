@@ -0,0 +1,187 @@
+package ast_test
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/parser"
+	"monkey-pl/parser/parsertest"
+	"reflect"
+	"testing"
+)
+
+// Uses every kind of node in the AST at least once
+const everyNode = `
+let x = 5;
+let [a, b = 2, ...rest] = [1, ...x];
+let {name, age: years = 1, ...others} = {"name": "Monkey", "age": 3};
+fn add(first, second = 1, ...more) { return first + second; }
+let f = fn([c, d], {e}) { -c * d[0] };
+let m = macro(q) { quote(unquote(q)) };
+if (x > 1) { x } else { !true };
+while (false) { "loop" };
+add(1, ...[2]);
+let typed: {string: [int]} = {};
+fn annotated(a: int, f: fn(int) -> bool) -> [int] { [a] }
+`
+
+// Finds every node reachable from node through struct fields, slices and
+// maps without knowing anything about the node types. This is what Walk
+// should agree with.
+func reachable(node ast.Node) []ast.Node {
+	nodes := []ast.Node{node}
+	var visit func(v reflect.Value)
+	visit = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface, reflect.Pointer:
+			if v.IsNil() {
+				return
+			}
+			if child, ok := v.Interface().(ast.Node); ok && v.Kind() == reflect.Pointer {
+				nodes = append(nodes, child)
+			}
+			visit(v.Elem())
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				visit(v.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				visit(v.Index(i))
+			}
+		case reflect.Map:
+			iter := v.MapRange()
+			for iter.Next() {
+				visit(iter.Key())
+				visit(iter.Value())
+			}
+		}
+	}
+	visit(reflect.ValueOf(node).Elem())
+	return nodes
+}
+
+func TestInspectReachesEveryChild(t *testing.T) {
+	program := parsertest.Parse(t, everyNode)
+
+	visited := map[ast.Node]int{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			visited[node]++
+		}
+		return true
+	})
+
+	// HashLiteral.Keys repeats the keys of Pairs, so reachable can
+	// return the same node more than once
+	expected := map[ast.Node]bool{}
+	for _, node := range reachable(program) {
+		expected[node] = true
+	}
+	for node := range expected {
+		if visited[node] != 1 {
+			t.Errorf("Expected %T (%s) to be visited once. Visited %d times", node, node, visited[node])
+		}
+	}
+	if len(visited) != len(expected) {
+		t.Errorf("Expected %d nodes to be visited. Got %d", len(expected), len(visited))
+	}
+
+	types := map[string]bool{}
+	for node := range visited {
+		types[fmt.Sprintf("%T", node)] = true
+	}
+	for _, name := range []string{
+		"*ast.Program", "*ast.ExpressionStatement", "*ast.LetStatement", "*ast.ReturnStatement",
+		"*ast.FunctionStatement", "*ast.BlockStatement", "*ast.Identifier", "*ast.IntegerLiteral",
+		"*ast.BooleanLiteral", "*ast.StringLiteral", "*ast.PrefixExpression", "*ast.InfixExpression",
+		"*ast.IndexExpression", "*ast.IfExpression", "*ast.WhileExpression", "*ast.FunctionLiteral",
+		"*ast.MacroLiteral", "*ast.CallExpression", "*ast.ArrayLiteral", "*ast.HashLiteral",
+		"*ast.SpreadElement", "*ast.AssignmentPattern", "*ast.RestElement", "*ast.ArrayPattern",
+		"*ast.HashPattern", "*ast.NamedType", "*ast.ArrayType", "*ast.HashType", "*ast.FunctionType",
+	} {
+		if !types[name] {
+			t.Errorf("Expected test input to contain a %s", name)
+		}
+	}
+}
+
+type recorder struct {
+	events *[]string
+}
+
+func (r recorder) Enter(node ast.Node) ast.Visitor {
+	*r.events = append(*r.events, "enter "+node.String())
+	return r
+}
+
+func (r recorder) Exit(node ast.Node) {
+	*r.events = append(*r.events, "exit "+node.String())
+}
+
+func TestWalkEnterAndExitOrder(t *testing.T) {
+	program := parsertest.Parse(t, `{"a": 1, "b": 2}; f(x)`)
+	events := []string{}
+	ast.Walk(program, recorder{events: &events})
+
+	expected := []string{
+		`enter {a:1, b:2}f(x)`,
+		`enter {a:1, b:2}`,
+		`enter {a:1, b:2}`,
+		`enter a`, `exit a`,
+		`enter 1`, `exit 1`,
+		`enter b`, `exit b`,
+		`enter 2`, `exit 2`,
+		`exit {a:1, b:2}`,
+		`exit {a:1, b:2}`,
+		`enter f(x)`,
+		`enter f(x)`,
+		`enter f`, `exit f`,
+		`enter x`, `exit x`,
+		`exit f(x)`,
+		`exit f(x)`,
+		`exit {a:1, b:2}f(x)`,
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Unexpected walk order.\nExpected: %q\nGot:      %q", expected, events)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parsertest.Parse(t, `let f = fn(x) { x + 1 }; f(2);`)
+	identifiers := []string{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if _, ok := node.(*ast.FunctionLiteral); ok {
+			return false
+		}
+		if ident, ok := node.(*ast.Identifier); ok {
+			identifiers = append(identifiers, ident.Value)
+		}
+		return true
+	})
+	expected := []string{"f", "f"}
+	if !reflect.DeepEqual(identifiers, expected) {
+		t.Errorf("Expected identifiers %v. Got %v", expected, identifiers)
+	}
+}
+
+// Failed parses leave nil statements and expressions behind. Walk should
+// skip them rather than hand them to the visitor.
+func TestInspectBrokenPrograms(t *testing.T) {
+	input := `let f = fn(a, b = 1, ...rest) { if (a > b) { [a, rest] } else { {"b": b}[a] } }; let [c, ...d] = f(1); -c;`
+	for i := range input {
+		program := parser.New(lexer.New(input[:i])).ParseProgram()
+		ast.Walk(program, nilChecker{t})
+	}
+}
+
+type nilChecker struct{ t *testing.T }
+
+func (c nilChecker) Enter(node ast.Node) ast.Visitor {
+	if ast.IsNil(node) {
+		c.t.Fatalf("Walk visited a nil %T", node)
+	}
+	return c
+}
+
+func (c nilChecker) Exit(node ast.Node) {}
@@ -0,0 +1,165 @@
+package checker
+
+import (
+	"monkey-pl/ast"
+	"monkey-pl/evaluator"
+)
+
+func isBuiltin(name string) bool {
+	_, ok := evaluator.LookupBuiltin(name)
+	return ok
+}
+
+// Builtins are checked by hand instead of being given a Function type
+// since a lot of them are generic (e.g. `first` returns the element type
+// of whatever array it gets).
+//
+// `first`, `rest` and `last` return null for arrays that are too short.
+// Treating that as `any` would make them pretty useless so the checker
+// pretends they always succeed.
+func (c *Checker) builtinCall(name string, call *ast.CallExpression) Type {
+	args := c.elements(call.Arguments)
+	info, _ := evaluator.LookupBuiltin(name)
+	for _, arg := range call.Arguments {
+		if _, ok := arg.(*ast.SpreadElement); ok {
+			// we don't know how many arguments there are
+			return Any
+		}
+	}
+	if len(args) < info.MinArgs || info.MaxArgs != -1 && len(args) > info.MaxArgs {
+		c.errorf(call.Function, "wrong number of arguments to %s: expected %d, got %d", name, info.MinArgs, len(args))
+		return Any
+	}
+
+	expect := func(i int, expected Type) {
+		if !assignable(args[i], expected) {
+			c.errorf(call.Arguments[i], "cannot use %s as %s in argument %d to %s", args[i], expected, i+1, name)
+		}
+	}
+	// returns the array argument or [any] when it isn't an array
+	array := func(i int) *Array {
+		expect(i, &Array{Any})
+		if array, ok := args[i].(*Array); ok {
+			return array
+		}
+		return &Array{Any}
+	}
+	// returns the callback's type, or nil if it isn't known
+	function := func(i int) *Function {
+		if function, ok := args[i].(*Function); ok {
+			return function
+		}
+		if args[i] != Any {
+			c.errorf(call.Arguments[i], "cannot use %s as function in argument %d to %s", args[i], i+1, name)
+		}
+		return nil
+	}
+	// returns what the callback in argument i returns
+	returns := func(i int) Type {
+		if function := function(i); function != nil {
+			return function.Return
+		}
+		return Any
+	}
+	// returns the hash argument or {any: any} when it isn't a hash
+	hash := func(i int) *Hash {
+		expect(i, &Hash{Any, Any})
+		if hash, ok := args[i].(*Hash); ok {
+			return hash
+		}
+		return &Hash{Any, Any}
+	}
+
+	switch name {
+	case "len":
+		if args[0] != Any && args[0] != String {
+			if _, ok := args[0].(*Array); !ok {
+				c.errorf(call.Arguments[0], "cannot use %s as string or array in argument 1 to len", args[0])
+			}
+		}
+		return Int
+	case "print":
+		return Null
+	case "first", "last":
+		return array(0).Element
+	case "rest":
+		return array(0)
+	case "push":
+		return &Array{unify(array(0).Element, args[1])}
+	case "join":
+		expect(0, &Array{String})
+		expect(1, String)
+		return String
+	case "split":
+		expect(0, String)
+		expect(1, String)
+		return &Array{String}
+	case "toUpperCase", "toLowerCase":
+		expect(0, String)
+		return String
+	case "keys":
+		return &Array{hash(0).Key}
+	case "values":
+		return &Array{hash(0).Value}
+	case "entries":
+		h := hash(0)
+		return &Array{&Array{unify(h.Key, h.Value)}}
+	case "fromEntries":
+		expect(0, &Array{&Array{Any}})
+		return &Hash{Any, Any}
+	case "has":
+		hash(0)
+		return Bool
+	case "delete":
+		return hash(0)
+	case "map":
+		array(0)
+		return &Array{returns(1)}
+	case "filter", "sort":
+		if len(args) > 1 {
+			function(1)
+		}
+		return array(0)
+	case "find":
+		function(1)
+		return array(0).Element
+	case "findIndex", "some", "every":
+		array(0)
+		function(1)
+		if name == "findIndex" {
+			return Int
+		}
+		return Bool
+	case "reduce":
+		array(0)
+		return returns(1)
+	case "reverse", "unique":
+		return array(0)
+	case "indexOf":
+		array(0)
+		return Int
+	case "flatten":
+		array(0)
+		if len(args) > 1 {
+			expect(1, Int)
+		}
+		return &Array{Any}
+	case "concat", "zip":
+		elements := []Type{}
+		for i := range args {
+			elements = append(elements, array(i).Element)
+		}
+		if name == "zip" {
+			return &Array{&Array{unifyAll(elements)}}
+		}
+		return &Array{unifyAll(elements)}
+	case "merge", "deepMerge":
+		merged := hash(0)
+		for i := 1; i < len(args); i++ {
+			h := hash(i)
+			merged = &Hash{unify(merged.Key, h.Key), unify(merged.Value, h.Value)}
+		}
+		return merged
+	}
+	return Any
+}
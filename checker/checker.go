@@ -0,0 +1,668 @@
+// Package checker is an optional, gradual type checker for Monkey. It uses
+// type annotations where there are some (`let x: int = 1`,
+// `fn(a: string) -> bool {}`) and works out types of literals, operators
+// and builtin calls locally. Anything it can't work out is `any`, so code
+// without annotations only gets errors that would happen at runtime anyway.
+//
+// The evaluator ignores annotations completely. The checker is meant to be
+// run before evaluating so mistakes are caught before any code runs.
+package checker
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/evaluator"
+	"monkey-pl/token"
+	"reflect"
+)
+
+type TypeError struct {
+	Line    int
+	Column  int
+	Offset  int
+	Message string
+}
+
+func (e TypeError) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// A Checker remembers the types of top level names between calls to
+// Check, which is what the REPL needs since each line is its own program
+type Checker struct {
+	env    *typeEnv
+	errors []TypeError
+	// The function whose body is being checked, nil at the top level
+	function *functionContext
+}
+
+type functionContext struct {
+	// nil when the function has no return type annotation
+	declared Type
+	// types of every return statement in the function
+	returns []Type
+}
+
+func New() *Checker {
+	return &Checker{env: newTypeEnv(nil, false)}
+}
+
+// Checks a single program with a fresh checker
+func Check(program *ast.Program) []TypeError {
+	return New().Check(program)
+}
+
+// Returns the type errors found in program. Names defined by the program
+// are remembered for later calls.
+func (c *Checker) Check(program *ast.Program) []TypeError {
+	c.errors = []TypeError{}
+	for _, statement := range program.Statements {
+		c.statement(statement)
+	}
+	return c.errors
+}
+
+// Works out the type of an expression using the names the checker already
+// knows about. Unlike Check, nothing is remembered afterwards.
+func (c *Checker) TypeOf(expression ast.Expression) (Type, []TypeError) {
+	c.errors = []TypeError{}
+	return c.expression(expression), c.errors
+}
+
+func (c *Checker) errorf(node ast.Node, format string, a ...interface{}) {
+	tok := tokenOf(node)
+	c.errors = append(c.errors, TypeError{
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Offset:  tok.Offset,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// Every node except Program has a Token field
+func tokenOf(node ast.Node) token.Token {
+	value := reflect.ValueOf(node)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return token.Token{}
+	}
+	field := value.Elem().FieldByName("Token")
+	if !field.IsValid() {
+		return token.Token{}
+	}
+	tok, _ := field.Interface().(token.Token)
+	return tok
+}
+
+// Converts an annotation into a Type
+func (c *Checker) annotation(typeExpr ast.TypeExpr) Type {
+	switch typeExpr := typeExpr.(type) {
+	case *ast.NamedType:
+		if t, ok := namedTypes[typeExpr.Name]; ok {
+			return t
+		}
+		c.errorf(typeExpr, "unknown type %s", typeExpr.Name)
+		return Any
+	case *ast.ArrayType:
+		return &Array{c.annotation(typeExpr.Element)}
+	case *ast.HashType:
+		return &Hash{c.annotation(typeExpr.Key), c.annotation(typeExpr.Value)}
+	case *ast.FunctionType:
+		function := &Function{Return: Any}
+		for _, param := range typeExpr.Parameters {
+			function.Params = append(function.Params, c.annotation(param))
+		}
+		function.MinArgs = len(function.Params)
+		if typeExpr.Return != nil {
+			function.Return = c.annotation(typeExpr.Return)
+		}
+		return function
+	default:
+		return Any
+	}
+}
+
+// Returns the declared type of an identifier or Any if it isn't annotated
+func (c *Checker) declaredType(ident *ast.Identifier) (Type, bool) {
+	if ident.Type == nil {
+		return Any, false
+	}
+	return c.annotation(ident.Type), true
+}
+
+func (c *Checker) statement(statement ast.Statement) Type {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		c.letStatement(statement)
+	case *ast.FunctionStatement:
+		if statement.Name == nil || statement.Function == nil {
+			return Any
+		}
+		previous, rebound := c.env.store[statement.Name.Value]
+		signature := c.signature(statement.Function)
+		c.env.set(statement.Name.Value, signature, false)
+		c.bindInferred(statement.Name.Value, c.functionLiteral(statement.Function, signature), previous, rebound)
+	case *ast.ReturnStatement:
+		returned := c.expression(statement.ReturnValue)
+		c.checkReturn(statement.ReturnValue, returned)
+		if c.function != nil {
+			c.function.returns = append(c.function.returns, returned)
+		}
+	case *ast.ExpressionStatement:
+		return c.expression(statement.Expression)
+	case *ast.BlockStatement:
+		return c.block(statement)
+	}
+	return Any
+}
+
+func (c *Checker) letStatement(statement *ast.LetStatement) {
+	if statement.Pattern != nil {
+		c.bindPattern(statement.Pattern, c.expression(statement.Value))
+		return
+	}
+	if statement.Name == nil {
+		return
+	}
+	name := statement.Name.Value
+	previous, rebound := c.env.store[name]
+
+	var value Type
+	if function, ok := statement.Value.(*ast.FunctionLiteral); ok {
+		// bind the signature first so the function can call itself
+		signature := c.signature(function)
+		c.env.set(name, signature, false)
+		value = c.functionLiteral(function, signature)
+	} else {
+		value = c.expression(statement.Value)
+	}
+
+	declared, annotated := c.declaredType(statement.Name)
+	if !annotated && rebound && previous.annotated {
+		// names that were annotated keep their type when they are rebound
+		declared, annotated = previous.typ, true
+	}
+	if !annotated {
+		c.bindInferred(name, value, previous, rebound)
+		return
+	}
+	if !assignable(value, declared) {
+		c.errorf(statement.Value, "cannot use %s as %s in let %s", value, declared, name)
+	}
+	c.env.set(name, declared, true)
+}
+
+// Sets the type of a name that isn't annotated. A let in an if or while
+// block might not run, so when it rebinds a name the name could still
+// have its old type after the block.
+func (c *Checker) bindInferred(name string, t Type, previous variable, rebound bool) {
+	if rebound && c.env.conditional > 0 {
+		t = unify(previous.typ, t)
+	}
+	c.env.set(name, t, false)
+}
+
+// Binds the names in a destructuring pattern (or parameter) given the type
+// of the value being destructured
+func (c *Checker) bindPattern(pattern ast.Pattern, value Type) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		declared, annotated := c.declaredType(pattern)
+		if !annotated {
+			previous, rebound := c.env.store[pattern.Value]
+			c.bindInferred(pattern.Value, value, previous, rebound)
+			return
+		}
+		if !assignable(value, declared) {
+			c.errorf(pattern, "cannot use %s as %s in %s", value, declared, pattern.Value)
+		}
+		c.env.set(pattern.Value, declared, true)
+	case *ast.AssignmentPattern:
+		// the default is only used when the value is missing
+		defaultType := c.expression(pattern.Default)
+		if value == Any {
+			c.bindPattern(pattern.Target, defaultType)
+		} else {
+			c.bindPattern(pattern.Target, unify(value, defaultType))
+		}
+	case *ast.RestElement:
+		if pattern.Target != nil {
+			c.bindPattern(pattern.Target, value)
+		}
+	case *ast.ArrayPattern:
+		element := Any
+		switch value := value.(type) {
+		case *Array:
+			element = value.Element
+		default:
+			if value != Any {
+				c.errorf(pattern, "cannot destructure %s as an array", value)
+				value = &Array{Any}
+			}
+		}
+		for _, e := range pattern.Elements {
+			if _, ok := e.(*ast.RestElement); ok {
+				c.bindPattern(e, value)
+			} else {
+				c.bindPattern(e, element)
+			}
+		}
+	case *ast.HashPattern:
+		valueType := Any
+		switch value := value.(type) {
+		case *Hash:
+			valueType = value.Value
+		default:
+			if value != Any {
+				c.errorf(pattern, "cannot destructure %s as a hash", value)
+				value = &Hash{Any, Any}
+			}
+		}
+		for _, property := range pattern.Properties {
+			c.bindPattern(property.Value, valueType)
+		}
+		if pattern.Rest != nil {
+			c.bindPattern(pattern.Rest, value)
+		}
+	}
+}
+
+// Works out a function's type from its annotations alone. Parameters
+// without annotations are Any and so is the return type if there isn't one.
+func (c *Checker) signature(function *ast.FunctionLiteral) *Function {
+	signature := &Function{Return: Any}
+	signature.MinArgs, _ = evaluator.FunctionArity(function.Parameters)
+	for _, param := range function.Parameters {
+		switch param := param.(type) {
+		case *ast.Identifier:
+			t, _ := c.declaredType(param)
+			signature.Params = append(signature.Params, t)
+		case *ast.AssignmentPattern:
+			t := Any
+			if ident, ok := param.Target.(*ast.Identifier); ok {
+				t, _ = c.declaredType(ident)
+			}
+			signature.Params = append(signature.Params, t)
+		case *ast.RestElement:
+			signature.Rest = Any
+			if param.Target != nil && param.Target.Type != nil {
+				if array, ok := c.annotation(param.Target.Type).(*Array); ok {
+					signature.Rest = array.Element
+				}
+			}
+		default:
+			signature.Params = append(signature.Params, Any)
+		}
+	}
+	if function.ReturnType != nil {
+		signature.Return = c.annotation(function.ReturnType)
+	}
+	return signature
+}
+
+// Checks a function's body and returns its type. When the return type
+// isn't annotated it is inferred from the body.
+func (c *Checker) functionLiteral(function *ast.FunctionLiteral, signature *Function) Type {
+	outerEnv, outerFunction := c.env, c.function
+	c.env = newTypeEnv(outerEnv, true)
+	c.function = &functionContext{}
+	if function.ReturnType != nil {
+		c.function.declared = signature.Return
+	}
+	defer func() {
+		c.env, c.function = outerEnv, outerFunction
+	}()
+
+	if function.Name != "" {
+		c.env.set(function.Name, signature, false)
+	}
+	for i, param := range function.Parameters {
+		switch param := param.(type) {
+		case *ast.RestElement:
+			if param.Target == nil {
+				continue
+			}
+			if param.Target.Type != nil {
+				declared := c.annotation(param.Target.Type)
+				if _, ok := declared.(*Array); !ok {
+					c.errorf(param.Target, "rest parameter %s must have an array type", param.Target.Value)
+				}
+				c.env.set(param.Target.Value, declared, true)
+				continue
+			}
+			c.env.set(param.Target.Value, &Array{signature.Rest}, false)
+		default:
+			c.bindPattern(param, signature.Params[i])
+		}
+	}
+
+	if function.Body == nil {
+		return signature
+	}
+	body := c.block(function.Body)
+	returns := c.function.returns
+	if endsWithExpression(function.Body) {
+		last := function.Body.Statements[len(function.Body.Statements)-1].(*ast.ExpressionStatement)
+		c.checkReturn(last.Expression, body)
+		returns = append(returns, body)
+	}
+
+	result := *signature
+	if function.ReturnType == nil {
+		result.Return = unifyAll(returns)
+	}
+	return &result
+}
+
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func (c *Checker) checkReturn(node ast.Node, returned Type) {
+	if c.function == nil || c.function.declared == nil {
+		return
+	}
+	if !assignable(returned, c.function.declared) {
+		c.errorf(node, "cannot return %s from a function that returns %s", returned, c.function.declared)
+	}
+}
+
+// Blocks don't have their own scope. Their type is the
+// type of their last statement if it's an expression.
+func (c *Checker) block(block *ast.BlockStatement) Type {
+	result := Any
+	for _, statement := range block.Statements {
+		result = c.statement(statement)
+	}
+	if !endsWithExpression(block) {
+		return Any
+	}
+	return result
+}
+
+// A block that might not run (the branches of an if and while bodies)
+func (c *Checker) conditionalBlock(block *ast.BlockStatement) Type {
+	env := c.env
+	env.conditional++
+	defer func() { env.conditional-- }()
+	return c.block(block)
+}
+
+func (c *Checker) expression(expression ast.Expression) Type {
+	switch expression := expression.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.StringLiteral:
+		return String
+	case *ast.BooleanLiteral:
+		return Bool
+	case *ast.Identifier:
+		return c.env.get(expression.Value)
+	case *ast.PrefixExpression:
+		return c.prefixExpression(expression)
+	case *ast.InfixExpression:
+		return c.infixExpression(expression)
+	case *ast.ArrayLiteral:
+		elements := c.elements(expression.Elements)
+		if len(elements) == 0 {
+			return &Array{Any}
+		}
+		return &Array{unifyAll(elements)}
+	case *ast.HashLiteral:
+		return c.hashLiteral(expression)
+	case *ast.IndexExpression:
+		return c.indexExpression(expression)
+	case *ast.IfExpression:
+		c.expression(expression.Condition)
+		consequence := c.conditionalBlock(expression.Consequence)
+		if expression.Alternative == nil {
+			// a false condition gives null
+			return unify(consequence, Null)
+		}
+		return unify(consequence, c.conditionalBlock(expression.Alternative))
+	case *ast.WhileExpression:
+		c.expression(expression.Condition)
+		c.conditionalBlock(expression.Body)
+		return Null
+	case *ast.FunctionLiteral:
+		return c.functionLiteral(expression, c.signature(expression))
+	case *ast.CallExpression:
+		return c.callExpression(expression)
+	case *ast.SpreadElement:
+		return c.expression(expression.Value)
+	default:
+		// macros are only checked once they've been expanded
+		return Any
+	}
+}
+
+func (c *Checker) prefixExpression(expression *ast.PrefixExpression) Type {
+	right := c.expression(expression.Right)
+	switch expression.Operator {
+	case "!":
+		return Bool
+	case "-":
+		// `-` reverses strings
+		if right == Int || right == String || right == Any {
+			return right
+		}
+		c.errorf(expression, "operator - is not supported for %s", right)
+	}
+	return Any
+}
+
+func (c *Checker) infixExpression(expression *ast.InfixExpression) Type {
+	left := c.expression(expression.Left)
+	right := c.expression(expression.Right)
+	operator := expression.Operator
+	known := left != Any && right != Any
+
+	switch operator {
+	case "==", "!=":
+		return Bool
+	case "<", ">":
+		if known && !isOrdered(left, right) {
+			c.errorf(expression, "cannot compare %s %s %s", left, operator, right)
+		}
+		return Bool
+	case "+":
+		if !known {
+			return Any
+		}
+		if left == right && (left == Int || left == String) {
+			return left
+		}
+	case "-", "*", "/":
+		if (left == Int || left == Any) && (right == Int || right == Any) {
+			return Int
+		}
+	}
+	c.errorf(expression, "operator %s is not supported for %s and %s", operator, left, right)
+	return Any
+}
+
+// Returns the types of a list of elements or arguments with
+// spread elements replaced by the type of their elements
+func (c *Checker) elements(expressions []ast.Expression) []Type {
+	types := []Type{}
+	for _, expression := range expressions {
+		t := c.expression(expression)
+		if _, ok := expression.(*ast.SpreadElement); !ok {
+			types = append(types, t)
+			continue
+		}
+		switch t := t.(type) {
+		case *Array:
+			types = append(types, t.Element)
+		default:
+			if t != Any {
+				c.errorf(expression, "cannot spread %s", t)
+			}
+			types = append(types, Any)
+		}
+	}
+	return types
+}
+
+func (c *Checker) hashLiteral(hash *ast.HashLiteral) Type {
+	keys, values := []Type{}, []Type{}
+	for _, key := range hash.OrderedKeys() {
+		keyType := c.expression(key)
+		if !isHashable(keyType) {
+			c.errorf(key, "unusable as hash key: %s", keyType)
+		}
+		keys = append(keys, keyType)
+		values = append(values, c.expression(hash.Pairs[key]))
+	}
+	return &Hash{unifyAll(keys), unifyAll(values)}
+}
+
+func isHashable(t Type) bool {
+	if array, ok := t.(*Array); ok {
+		return isHashable(array.Element)
+	}
+	return t == Int || t == String || t == Bool || t == Any
+}
+
+// Integers, strings and arrays of those can be compared with `<` and `>`
+func isOrdered(left Type, right Type) bool {
+	leftArray, leftIsArray := left.(*Array)
+	rightArray, rightIsArray := right.(*Array)
+	if leftIsArray && rightIsArray {
+		return leftArray.Element == Any || rightArray.Element == Any || isOrdered(leftArray.Element, rightArray.Element)
+	}
+	return left == Int && right == Int || left == String && right == String
+}
+
+func (c *Checker) indexExpression(expression *ast.IndexExpression) Type {
+	left := c.expression(expression.Left)
+	index := c.expression(expression.Index)
+	switch left := left.(type) {
+	case *Array:
+		if !assignable(index, Int) {
+			c.errorf(expression.Index, "array index must be int, got %s", index)
+		}
+		return left.Element
+	case *Hash:
+		if !assignable(index, left.Key) {
+			c.errorf(expression.Index, "cannot use %s as a key for %s", index, left)
+		}
+		return left.Value
+	default:
+		if left != Any {
+			c.errorf(expression, "index operator not supported for %s", left)
+		}
+		return Any
+	}
+}
+
+func (c *Checker) callExpression(call *ast.CallExpression) Type {
+	if ident, ok := call.Function.(*ast.Identifier); ok && !c.env.has(ident.Value) {
+		switch {
+		case ident.Value == "quote" || ident.Value == "unquote":
+			// quoted code isn't evaluated so there's nothing to check
+			return Any
+		case isBuiltin(ident.Value):
+			return c.builtinCall(ident.Value, call)
+		}
+	}
+
+	callee := c.expression(call.Function)
+	args := c.elements(call.Arguments)
+	switch callee := callee.(type) {
+	case *Function:
+		c.checkArguments(call, call.Function.String(), callee, args)
+		return callee.Return
+	default:
+		if callee != Any {
+			c.errorf(call.Function, "cannot call %s", callee)
+		}
+		return Any
+	}
+}
+
+// Checks the number and types of arguments in a call. Calls that spread an
+// array can't be checked since we don't know how long the array is.
+func (c *Checker) checkArguments(call *ast.CallExpression, name string, function *Function, args []Type) {
+	for _, arg := range call.Arguments {
+		if _, ok := arg.(*ast.SpreadElement); ok {
+			return
+		}
+	}
+	if len(args) < function.MinArgs || function.Rest == nil && len(args) > len(function.Params) {
+		c.errorf(call.Function, "wrong number of arguments to %s: %s", name, describeArgs(function, len(args)))
+		return
+	}
+	for i, arg := range args {
+		param := function.param(i)
+		if !assignable(arg, param) {
+			c.errorf(call.Arguments[i], "cannot use %s as %s in argument %d to %s", arg, param, i+1, name)
+		}
+	}
+}
+
+func describeArgs(function *Function, got int) string {
+	switch {
+	case function.Rest != nil:
+		return fmt.Sprintf("expected at least %d, got %d", function.MinArgs, got)
+	case function.MinArgs == len(function.Params):
+		return fmt.Sprintf("expected %d, got %d", function.MinArgs, got)
+	default:
+		return fmt.Sprintf("expected %d to %d, got %d", function.MinArgs, len(function.Params), got)
+	}
+}
+
+// Names are looked up through enclosing scopes. Inside a function we only
+// trust the inferred type of a variable from an outer scope if it is a
+// function, since the variable could be rebound with another type before
+// the function is called. Annotated variables can't change type so
+// they're always trusted.
+type typeEnv struct {
+	store map[string]variable
+	outer *typeEnv
+	// true for the scope of a function body
+	function bool
+	// how many if and while blocks we're inside of in this scope
+	conditional int
+}
+
+type variable struct {
+	typ       Type
+	annotated bool
+}
+
+func newTypeEnv(outer *typeEnv, function bool) *typeEnv {
+	return &typeEnv{store: map[string]variable{}, outer: outer, function: function}
+}
+
+func (e *typeEnv) set(name string, t Type, annotated bool) {
+	e.store[name] = variable{t, annotated}
+}
+
+func (e *typeEnv) has(name string) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *typeEnv) get(name string) Type {
+	crossedFunction := false
+	for env := e; env != nil; env = env.outer {
+		if v, ok := env.store[name]; ok {
+			if _, isFunction := v.typ.(*Function); crossedFunction && !v.annotated && !isFunction {
+				return Any
+			}
+			return v.typ
+		}
+		if env.function {
+			crossedFunction = true
+		}
+	}
+	return Any
+}
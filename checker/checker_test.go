@@ -0,0 +1,131 @@
+package checker
+
+import (
+	"monkey-pl/ast"
+	"monkey-pl/parser/parsertest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// unannotated code only fails where it would fail at runtime
+		{"let x = 5; let f = fn(a, b) { a + b }; f(x, 1); f(\"a\", \"b\");", []string{}},
+		{"let add = fn(a, b) { a + b }; add(1, 2) + \"three\";", []string{}},
+		{"1 + \"a\"", []string{"1:3: operator + is not supported for int and string"}},
+		{"-true", []string{"1:1: operator - is not supported for bool"}},
+		{"let x = 1; x()", []string{"1:12: cannot call int"}},
+		{"[1, 2][\"a\"]", []string{"1:8: array index must be int, got string"}},
+		{"{[1]: 2}", []string{}},
+		{"{[fn() { 1 }]: 2}", []string{"1:2: unusable as hash key: [fn() -> int]"}},
+		{"[1, 2] < [1, 3]; [\"a\"] > []", []string{}},
+		{"[1] < [\"a\"]", []string{"1:5: cannot compare [int] < [string]"}},
+		{"quote(1 + \"a\")", []string{}},
+		// let annotations
+		{"let x: int = 1; let y: string = \"a\"; let z: [int] = []; let h: {string: int} = {};", []string{}},
+		{"let x: int = \"one\";", []string{"1:14: cannot use string as int in let x"}},
+		{"let x: [string] = [1, 2];", []string{"1:19: cannot use [int] as [string] in let x"}},
+		{"let x: number = 1;", []string{"1:8: unknown type number"}},
+		{"let x: int = 1; let x = \"now a string\";", []string{"1:25: cannot use string as int in let x"}},
+		// function annotations
+		{"let f = fn(a: int, b: int) -> int { a + b }; f(1, 2) + 3;", []string{}},
+		{"let f = fn(a: int) -> int { a }; f(\"a\");", []string{"1:36: cannot use string as int in argument 1 to f"}},
+		{"let f = fn(a: int) -> string { a };", []string{"1:32: cannot return int from a function that returns string"}},
+		{"fn f(a: int) -> bool { if (a > 1) { return 1; } true }", []string{"1:44: cannot return int from a function that returns bool"}},
+		{"let f = fn(a: int) -> int { a }; f(1) + \"a\";", []string{"1:39: operator + is not supported for int and string"}},
+		{"let f = fn(a, b = 1) { a }; f(); f(1, 2, 3);", []string{
+			"1:29: wrong number of arguments to f: expected 1 to 2, got 0",
+			"1:34: wrong number of arguments to f: expected 1 to 2, got 3",
+		}},
+		{"fn f(...xs: [int]) { xs } f(1, 2, \"3\"); f(...[\"a\"]);", []string{"1:35: cannot use string as int in argument 3 to f"}},
+		{"fn f(...xs: int) { xs }", []string{"1:9: rest parameter xs must have an array type"}},
+		{"let apply = fn(f: fn(int) -> int, x: int) -> int { f(x) }; apply(fn(n) { n * 2 }, 1); apply(fn(s: string) { s }, 1);", []string{
+			"1:93: cannot use fn(string) -> string as fn(int) -> int in argument 1 to apply",
+		}},
+		// recursion can see the function's own signature
+		{"let fact = fn(n: int) -> int { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(\"5\");", []string{
+			"1:82: cannot use string as int in argument 1 to fact",
+		}},
+		// inferred return types
+		{"let f = fn() { \"a\" }; f() - 1;", []string{"1:27: operator - is not supported for string and int"}},
+		// destructuring
+		{"let [a, b] = [1, 2]; a + b;", []string{}},
+		{"let [a: string] = [1];", []string{"1:6: cannot use int as string in a"}},
+		{"let {name} = 5;", []string{"1:5: cannot destructure int as a hash"}},
+		// builtins
+		{"len(\"abc\") + len([1]); first([1]) + 1; join(split(\"a,b\", \",\"), \"-\");", []string{}},
+		{"len(1)", []string{"1:5: cannot use int as string or array in argument 1 to len"}},
+		{"len(1, 2)", []string{"1:1: wrong number of arguments to len: expected 1, got 2"}},
+		{"toUpperCase([1])", []string{"1:13: cannot use [int] as string in argument 1 to toUpperCase"}},
+		{"first([\"a\"]) * 2", []string{"1:14: operator * is not supported for string and int"}},
+		{"let len = fn(a, b) { a }; len(1, 2);", []string{}},
+		{"let h = {\"a\": 1}; first(keys(h)) + \"b\"; first(values(h)) + 1; has(h, \"a\") == true;", []string{}},
+		{"first(values(merge({\"a\": 1}, {\"b\": 2}))) + 1; delete({\"a\": 1}, \"a\")[\"b\"] * 2;", []string{}},
+		{"first(map([1, 2], fn(x) { x * 2 })) + 1; first(sort([\"b\", \"a\"])) + \"c\"; some([1], fn(x) { x > 0 }) == true;", []string{}},
+		{"map([1], 5)", []string{"1:10: cannot use int as function in argument 2 to map"}},
+		{"first(map([1], fn(x) { \"s\" })) * 2", []string{"1:32: operator * is not supported for string and int"}},
+		{"first(concat([1], [2])) + \"a\"", []string{"1:25: operator + is not supported for int and string"}},
+		{"keys([1])", []string{"1:6: cannot use [int] as {any: any} in argument 1 to keys"}},
+		{"first(values({\"a\": 1})) + \"b\"", []string{"1:25: operator + is not supported for int and string"}},
+		// outer variables could change type before a function runs so
+		// unannotated ones aren't trusted inside functions
+		{"let x = 1; let f = fn() { x + \"a\" }; let x = \"b\"; f();", []string{}},
+		// a let in an if or while block might not run, so the name could
+		// still have its old type afterwards
+		{"let c = false; let x = 1; if (c) { let x = \"a\"; }; x + 1", []string{}},
+		{"let x = 1; while (false) { let [x] = [\"a\"]; }; x + 1", []string{}},
+		{"let x = 1; if (true) { let x = 2; } else { let x = 3; }; x + \"a\"", []string{"1:60: operator + is not supported for int and string"}},
+		{"if (true) { let y = \"a\"; }; y * 2", []string{"1:31: operator * is not supported for string and int"}},
+	}
+
+	for _, tt := range tests {
+		errors := Check(parsertest.Parse(t, tt.input))
+		if len(errors) != len(tt.expected) {
+			t.Errorf("Check(%q) returned %d errors. Expected %d. Got %v", tt.input, len(errors), len(tt.expected), errors)
+			continue
+		}
+		for i, err := range errors {
+			if err.String() != tt.expected[i] {
+				t.Errorf("Check(%q)[%d] wrong. Expected %q. Got %q", tt.input, i, tt.expected[i], err.String())
+			}
+		}
+	}
+}
+
+func TestCheckerRemembersNames(t *testing.T) {
+	checker := New()
+	if errors := checker.Check(parsertest.Parse(t, "let f = fn(s: string) -> string { s };")); len(errors) != 0 {
+		t.Fatalf("Unexpected errors: %v", errors)
+	}
+	errors := checker.Check(parsertest.Parse(t, "f(1)"))
+	if len(errors) != 1 || errors[0].Message != "cannot use int as string in argument 1 to f" {
+		t.Errorf("Expected f's signature to be remembered. Got %v", errors)
+	}
+}
+
+func TestTypeOf(t *testing.T) {
+	checker := New()
+	checker.Check(parsertest.Parse(t, "let greet = fn(name: string) -> string { \"hi \" + name }; let xs: [int] = [];"))
+	tests := []struct {
+		input    string
+		expected string
+		errors   int
+	}{
+		{"1 + 2", "int", 0},
+		{"greet", "fn(string) -> string", 0},
+		{"greet(\"bob\")", "string", 0},
+		{"xs", "[int]", 0},
+		{"{\"a\": true}", "{string: bool}", 0},
+		{"greet(1)", "string", 1},
+		{"unknown", "any", 0},
+	}
+	for _, tt := range tests {
+		statement := parsertest.Parse(t, tt.input).Statements[0].(*ast.ExpressionStatement)
+		got, errors := checker.TypeOf(statement.Expression)
+		if got.String() != tt.expected || len(errors) != tt.errors {
+			t.Errorf("TypeOf(%s): expected %s with %d errors. got=%s with %v", tt.input, tt.expected, tt.errors, got, errors)
+		}
+	}
+}
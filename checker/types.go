@@ -0,0 +1,145 @@
+package checker
+
+import "strings"
+
+// The types the checker knows about. Types are compared by their String()
+// form since that already spells out their whole structure.
+type Type interface {
+	String() string
+}
+
+type basicType string
+
+func (b basicType) String() string {
+	return string(b)
+}
+
+var (
+	Int    Type = basicType("int")
+	String Type = basicType("string")
+	Bool   Type = basicType("bool")
+	Null   Type = basicType("null")
+	// Any is used for anything we can't (or don't try to) work out.
+	// It is compatible with every other type so it never causes errors.
+	Any Type = basicType("any")
+)
+
+var namedTypes = map[string]Type{
+	"int":    Int,
+	"string": String,
+	"bool":   Bool,
+	"null":   Null,
+	"any":    Any,
+}
+
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string {
+	return "[" + a.Element.String() + "]"
+}
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string {
+	return "{" + h.Key.String() + ": " + h.Value.String() + "}"
+}
+
+type Function struct {
+	Params []Type
+	// Parameters after MinArgs have defaults
+	MinArgs int
+	// The type of the rest parameter's elements or nil if there isn't one
+	Rest   Type
+	Return Type
+}
+
+func (f *Function) String() string {
+	params := []string{}
+	for _, param := range f.Params {
+		params = append(params, param.String())
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+(&Array{f.Rest}).String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// Returns the type of the i-th argument to f or nil if it takes fewer arguments
+func (f *Function) param(i int) Type {
+	if i < len(f.Params) {
+		return f.Params[i]
+	}
+	return f.Rest
+}
+
+func equal(a Type, b Type) bool {
+	return a.String() == b.String()
+}
+
+// Reports whether a value of type from can be used where type to is
+// expected. Any is compatible with everything in both directions.
+func assignable(from Type, to Type) bool {
+	if from == Any || to == Any {
+		return true
+	}
+	switch to := to.(type) {
+	case *Array:
+		from, ok := from.(*Array)
+		return ok && assignable(from.Element, to.Element)
+	case *Hash:
+		from, ok := from.(*Hash)
+		return ok && assignable(from.Key, to.Key) && assignable(from.Value, to.Value)
+	case *Function:
+		from, ok := from.(*Function)
+		if !ok {
+			return false
+		}
+		// from has to accept every argument list that to accepts
+		if len(to.Params) < from.MinArgs || from.Rest == nil && len(to.Params) > len(from.Params) {
+			return false
+		}
+		for i, param := range to.Params {
+			if !assignable(param, from.param(i)) {
+				return false
+			}
+		}
+		return assignable(from.Return, to.Return)
+	default:
+		return equal(from, to)
+	}
+}
+
+// Returns a type that covers both a and b. Since there are no union types
+// this is Any unless they are the same (or arrays / hashes of the same).
+func unify(a Type, b Type) Type {
+	if equal(a, b) {
+		return a
+	}
+	switch a := a.(type) {
+	case *Array:
+		if b, ok := b.(*Array); ok {
+			return &Array{unify(a.Element, b.Element)}
+		}
+	case *Hash:
+		if b, ok := b.(*Hash); ok {
+			return &Hash{unify(a.Key, b.Key), unify(a.Value, b.Value)}
+		}
+	}
+	return Any
+}
+
+func unifyAll(types []Type) Type {
+	if len(types) == 0 {
+		return Any
+	}
+	result := types[0]
+	for _, t := range types[1:] {
+		result = unify(result, t)
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/parser"
+	"monkey-pl/render"
+	"os"
+	"strings"
+)
+
+// `monkey ast` parses a file (or stdin) and prints its AST as JSON (the
+// default), an indented tree (-tree) or a Graphviz graph (-dot).
+func runAst(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey ast [-json | -tree | -dot] [file]\n")
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the AST as JSON (see ast/schema.md). This is the default")
+	asTree := flags.Bool("tree", false, "print the AST as an indented tree")
+	asDot := flags.Bool("dot", false, "print the AST as a Graphviz DOT graph")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if countTrue(*asJSON, *asTree, *asDot) > 1 {
+		fmt.Fprintln(os.Stderr, "monkey ast: only one of -json, -tree and -dot can be used")
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	name, source, err := readSource(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+	program, errs := parseWithTrivia(source)
+	if len(errs) != 0 {
+		fmt.Fprintf(os.Stderr, "monkey ast: %s: could not parse source:\n\t%s\n", name, strings.Join(errs, "\n\t"))
+		return 1
+	}
+
+	switch {
+	case *asTree:
+		fmt.Print(render.Tree(program))
+		return 0
+	case *asDot:
+		fmt.Print(render.Dot(program))
+		return 0
+	}
+
+	encoded, err := ast.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey ast: %s\n", err)
+		return 1
+	}
+	var out bytes.Buffer
+	json.Indent(&out, encoded, "", "  ")
+	out.WriteString("\n")
+	os.Stdout.Write(out.Bytes())
+	return 0
+}
+
+func countTrue(flags ...bool) int {
+	count := 0
+	for _, flag := range flags {
+		if flag {
+			count++
+		}
+	}
+	return count
+}
+
+// Reads the named file, or stdin when path is empty
+func readSource(path string) (string, string, error) {
+	if path == "" {
+		source, err := io.ReadAll(os.Stdin)
+		return "<standard input>", string(source), err
+	}
+	source, err := os.ReadFile(path)
+	return path, string(source), err
+}
+
+// Tooling wants comments and positions so we parse in trivia mode
+func parseWithTrivia(source string) (*ast.Program, []string) {
+	pars := parser.New(lexer.NewWithTrivia(source))
+	program := pars.ParseProgram()
+	return program, pars.Errors()
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"monkey-pl/formatter"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Monkey source files use the .mk extension
+const sourceExtension = ".mk"
+
+type fmtOptions struct {
+	write bool
+	list  bool
+	diff  bool
+}
+
+// `monkey fmt` works like gofmt: with no files it formats stdin to stdout,
+// otherwise each file (or every .mk file in a directory) is formatted.
+// Returns the exit code.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey fmt [-w] [-l] [-d] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	options := fmtOptions{}
+	flags.BoolVar(&options.write, "w", false, "write result to (source) file instead of stdout")
+	flags.BoolVar(&options.list, "l", false, "list files whose formatting differs from monkey fmt's")
+	flags.BoolVar(&options.diff, "d", false, "display diffs instead of rewriting files")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if options.write {
+			fmt.Fprintln(os.Stderr, "monkey fmt: cannot use -w with standard input")
+			return 2
+		}
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			return 1
+		}
+		if err := formatSource("<standard input>", source, options); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	exitCode := 0
+	for _, path := range flags.Args() {
+		if err := formatPath(path, options); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+func formatPath(path string, options fmtOptions) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return formatFile(path, options)
+	}
+
+	// Errors in one file shouldn't stop the rest of the directory
+	// from being formatted, so we only report the first one at the end
+	var firstErr error
+	walkErr := filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !strings.HasSuffix(file, sourceExtension) {
+			return nil
+		}
+		if err := formatFile(file, options); err != nil {
+			fmt.Fprintf(os.Stderr, "monkey fmt: %s\n", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return walkErr
+	}
+	if firstErr != nil {
+		return fmt.Errorf("%s: some files could not be formatted", path)
+	}
+	return nil
+}
+
+func formatFile(path string, options fmtOptions) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return formatSource(path, source, options)
+}
+
+func formatSource(name string, source []byte, options fmtOptions) error {
+	formatted, err := formatter.Format(string(source))
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+
+	changed := formatted != string(source)
+	if options.list && changed {
+		fmt.Println(name)
+	}
+	if options.diff && changed {
+		fmt.Print(unifiedDiff(name, string(source), formatted))
+	}
+	if options.write && changed {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, []byte(formatted), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if !options.list && !options.diff && !options.write {
+		fmt.Print(formatted)
+	}
+	return nil
+}
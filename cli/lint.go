@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"monkey-pl/lexer"
+	"monkey-pl/lint"
+	"monkey-pl/parser"
+	"os"
+)
+
+// The code used for parser errors
+const syntaxCode = "syntax"
+
+type fileDiagnostic struct {
+	File string `json:"file"`
+	lint.Diagnostic
+}
+
+// `monkey lint` checks files (or stdin) for likely mistakes. The exit
+// code is 1 if anything was found so it can be used in CI.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey lint [-json] [file ...]\n")
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print diagnostics as a JSON array")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		// readSource reads stdin for an empty path
+		paths = []string{""}
+	}
+
+	diagnostics := []fileDiagnostic{}
+	for _, path := range paths {
+		name, source, err := readSource(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "monkey lint: %s\n", err)
+			return 1
+		}
+		diagnostics = append(diagnostics, lintSource(name, source)...)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(diagnostics)
+	} else {
+		for _, diagnostic := range diagnostics {
+			fmt.Printf("%s:%s\n", diagnostic.File, diagnostic.Diagnostic)
+		}
+	}
+	if len(diagnostics) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func lintSource(name string, source string) []fileDiagnostic {
+	pars := parser.New(lexer.New(source))
+	program := pars.ParseProgram()
+	diagnostics := []fileDiagnostic{}
+	if len(pars.Errors()) != 0 {
+		for _, err := range pars.ParseErrors() {
+			diagnostics = append(diagnostics, fileDiagnostic{name, lint.Diagnostic{
+				Line:     err.Token.Line,
+				Column:   err.Token.Column,
+				Offset:   err.Token.Offset,
+				Severity: lint.Error,
+				Code:     syntaxCode,
+				Message:  err.Message,
+			}})
+		}
+		return diagnostics
+	}
+	for _, diagnostic := range lint.Lint(program) {
+		diagnostics = append(diagnostics, fileDiagnostic{name, diagnostic})
+	}
+	return diagnostics
+}
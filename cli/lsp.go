@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"monkey-pl/lsp"
+	"os"
+)
+
+// `monkey lsp` runs the language server over stdin and stdout.
+// Editors start it themselves so it doesn't take any arguments.
+func runLsp(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "usage: monkey lsp\n")
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey lsp: %s\n", err)
+		return 1
+	}
+	return 0
+}
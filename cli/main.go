@@ -2,20 +2,54 @@ package main
 
 import (
 	"fmt"
-	"monkey-pl/repl"
 	"os"
-	"os/user"
+	"strings"
 )
 
+const usage = `usage: monkey [command] [arguments]
+
+Commands:
+  run [file] [args ...]    run a script (or stdin). monkey file.mk also works
+  eval -e <code> [args]    evaluate code and print the result
+  repl                     start the repl (the default)
+  fmt [-w] [-l] [-d]       format source files
+  ast [-json|-tree|-dot]   print a file's AST
+  lint [-json]             check files for likely mistakes
+  lsp                      start the language server
+`
+
 func main() {
-	user, err := user.Current()
+	if len(os.Args) < 2 {
+		os.Exit(runRepl(nil))
+	}
 
-	if err != nil {
-		fmt.Println("🙊 Oh no! There was an error! See below: 🙊")
-		panic(err)
+	switch os.Args[1] {
+	case "run":
+		os.Exit(runRun(os.Args[2:]))
+	case "eval":
+		os.Exit(runEval(os.Args[2:]))
+	case "repl":
+		os.Exit(runRepl(os.Args[2:]))
+	case "fmt":
+		os.Exit(runFmt(os.Args[2:]))
+	case "ast":
+		os.Exit(runAst(os.Args[2:]))
+	case "lint":
+		os.Exit(runLint(os.Args[2:]))
+	case "lsp":
+		os.Exit(runLsp(os.Args[2:]))
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		os.Exit(0)
 	}
 
-	fmt.Printf("🐵 Hello %s! Welcome to the Monkey programming language 🐵\n", user.Username)
-	fmt.Printf("🐵🍌 Try out some commands! Use :exit to exit the repl 🍌🐵\n\n")
-	repl.Start(os.Stdin, os.Stdout)
+	// `monkey script.mk args...` is the same as `monkey run script.mk args...`
+	// which is what makes shebang lines work
+	if !strings.HasPrefix(os.Args[1], "-") {
+		if _, err := os.Stat(os.Args[1]); err == nil {
+			os.Exit(runRun(os.Args[1:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "monkey: unknown command %q\n\n%s", os.Args[1], usage)
+	os.Exit(2)
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"monkey-pl/repl"
+	"os"
+	"os/user"
+)
+
+// `monkey repl` (or just `monkey`) greets the user and starts the repl
+func runRepl(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey repl")
+		return 2
+	}
+
+	user, err := user.Current()
+
+	if err != nil {
+		fmt.Println("🙊 Oh no! There was an error! See below: 🙊")
+		panic(err)
+	}
+
+	fmt.Printf("🐵 Hello %s! Welcome to the Monkey programming language 🐵\n", user.Username)
+	fmt.Printf("🐵🍌 Try out some commands! Use :help to see meta-commands and :exit to exit the repl 🍌🐵\n\n")
+	repl.Start(os.Stdin, os.Stdout)
+	return 0
+}
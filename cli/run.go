@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/checker"
+	"monkey-pl/evaluator"
+	"monkey-pl/lexer"
+	"monkey-pl/object"
+	"monkey-pl/optimize"
+	"monkey-pl/parser"
+	"os"
+)
+
+// `monkey run file.mk [args ...]` runs a script. With no file (or `-`)
+// the script is read from stdin. Scripts can start with a shebang line
+// like `#!/usr/bin/env monkey` since `#` starts a comment anyway.
+func runRun(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey run [file | -] [args ...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	path := flags.Arg(0)
+	if path == "-" {
+		path = ""
+	}
+	name, source, err := readSource(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey run: %s\n", err)
+		return 1
+	}
+	scriptArgs := []string{}
+	if flags.NArg() > 1 {
+		scriptArgs = flags.Args()[1:]
+	}
+	_, exitCode := execute(name, source, scriptArgs)
+	return exitCode
+}
+
+// `monkey eval -e <code> [args ...]` runs code from the command line and
+// prints what it evaluates to
+func runEval(args []string) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: monkey eval -e <code> [args ...]\n")
+		flags.PrintDefaults()
+	}
+	code := flags.String("e", "", "the code to evaluate")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *code == "" {
+		flags.Usage()
+		return 2
+	}
+
+	result, exitCode := execute("-e", *code, flags.Args())
+	if exitCode == 0 && result != nil && result != evaluator.NULL {
+		fmt.Println(result.Inspect())
+	}
+	return exitCode
+}
+
+// Runs a whole program the same way the repl runs a line: parse, check
+// types, expand macros and evaluate. Errors go to stderr and give an exit
+// code of 1. Since the program runs in a fresh environment it's also safe
+// to optimize it first.
+func execute(name string, source string, args []string) (object.Object, int) {
+	pars := parser.New(lexer.New(source))
+	program := pars.ParseProgram()
+	if len(pars.ParseErrors()) != 0 {
+		for _, err := range pars.ParseErrors() {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", name, err.Token.Line, err.Token.Column, err.Message)
+		}
+		return nil, 1
+	}
+	if typeErrors := checker.Check(program); len(typeErrors) != 0 {
+		for _, typeError := range typeErrors {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", name, typeError)
+		}
+		return nil, 1
+	}
+
+	env := object.NewEnvironment()
+	env.Set("args", scriptArguments(args))
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, macroEnv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, err)
+		return nil, 1
+	}
+	if program, ok := expanded.(*ast.Program); ok {
+		expanded = optimize.Optimize(program)
+	}
+
+	result := evaluator.Eval(expanded, env)
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: error: %s\n", name, err.Message)
+		return result, 1
+	}
+	return result, 0
+}
+
+// The arguments after the script's name are available to it as `args`
+func scriptArguments(args []string) *object.Array {
+	elements := []object.Object{}
+	for _, arg := range args {
+		elements = append(elements, &object.String{Value: arg})
+	}
+	return &object.Array{Elements: elements}
+}
@@ -84,3 +84,144 @@ func join(args ...object.Object) object.Object {
 	}
 	return &object.String{Value: strings.Join(stringArr, separator.Value)}
 }
+
+func reverse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. Expected 1. Got %d.", len(args))
+	}
+	arr, err := arrayArgument("reverse", args, 0)
+	if err != nil {
+		return err
+	}
+	reversed := make([]object.Object, len(arr.Elements))
+	for i, element := range arr.Elements {
+		reversed[len(arr.Elements)-1-i] = element
+	}
+	return &object.Array{Elements: reversed}
+}
+
+func concat(args ...object.Object) object.Object {
+	elements := []object.Object{}
+	for i := range args {
+		arr, err := arrayArgument("concat", args, i)
+		if err != nil {
+			return err
+		}
+		elements = append(elements, arr.Elements...)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// `flatten(array, depth)` takes the elements out of nested arrays, depth
+// levels deep. depth defaults to 1.
+func flatten(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. Expected 1 or 2. Got %d.", len(args))
+	}
+	arr, err := arrayArgument("flatten", args, 0)
+	if err != nil {
+		return err
+	}
+	depth := int64(1)
+	if len(args) == 2 {
+		integer, ok := args[1].(*object.Integer)
+		if !ok || integer.Value < 0 {
+			return newError("argument 2 to `flatten` should be a depth of 0 or more. received %s", args[1].Inspect())
+		}
+		depth = integer.Value
+	}
+	return &object.Array{Elements: flattenElements(arr.Elements, depth)}
+}
+
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+	flattened := []object.Object{}
+	for _, element := range elements {
+		if inner, ok := element.(*object.Array); ok && depth > 0 {
+			flattened = append(flattened, flattenElements(inner.Elements, depth-1)...)
+		} else {
+			flattened = append(flattened, element)
+		}
+	}
+	return flattened
+}
+
+// `zip([1, 2], ["a", "b"])` is `[[1, "a"], [2, "b"]]`. It stops
+// at the end of the shortest array.
+func zip(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. Expected at least 1. Got 0.")
+	}
+	arrays := []*object.Array{}
+	shortest := -1
+	for i := range args {
+		arr, err := arrayArgument("zip", args, i)
+		if err != nil {
+			return err
+		}
+		arrays = append(arrays, arr)
+		if shortest == -1 || len(arr.Elements) < shortest {
+			shortest = len(arr.Elements)
+		}
+	}
+	zipped := make([]object.Object, 0, shortest)
+	for i := 0; i < shortest; i++ {
+		tuple := make([]object.Object, 0, len(arrays))
+		for _, arr := range arrays {
+			tuple = append(tuple, arr.Elements[i])
+		}
+		zipped = append(zipped, &object.Array{Elements: tuple})
+	}
+	return &object.Array{Elements: zipped}
+}
+
+// Keeps the first of each group of equal elements. Values that can be hash
+// keys are looked up in a hash. Anything else (like functions) has to be
+// compared with every other one of its kind.
+func unique(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. Expected 1. Got %d.", len(args))
+	}
+	arr, err := arrayArgument("unique", args, 0)
+	if err != nil {
+		return err
+	}
+	seen := object.NewHash()
+	unhashable := []object.Object{}
+	kept := []object.Object{}
+	for _, element := range arr.Elements {
+		if key, ok := object.AsHashable(element); ok {
+			if _, found := seen.Get(key); found {
+				continue
+			}
+			seen.Set(key, TRUE)
+		} else {
+			if indexOf(unhashable, element) != -1 {
+				continue
+			}
+			unhashable = append(unhashable, element)
+		}
+		kept = append(kept, element)
+	}
+	return &object.Array{Elements: kept}
+}
+
+func indexOfElement(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. Expected 2. Got %d.", len(args))
+	}
+	arr, err := arrayArgument("indexOf", args, 0)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(indexOf(arr.Elements, args[1]))}
+}
+
+// Returns the index of the first element equal to value, or -1
+func indexOf(elements []object.Object, value object.Object) int {
+	for i, element := range elements {
+		if object.Equal(element, value) {
+			return i
+		}
+	}
+	return -1
+}
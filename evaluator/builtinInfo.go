@@ -0,0 +1,66 @@
+package evaluator
+
+import "sort"
+
+// Describes a builtin's signature for tools that don't run code (like
+// the linter). The builtins themselves only check their arguments when
+// they are called so this has to be kept in sync with builtins by hand.
+type BuiltinInfo struct {
+	Name   string
+	Params []string
+	// MaxArgs is -1 for builtins that take any number of arguments
+	MinArgs int
+	MaxArgs int
+	// A short description for editors to show
+	Doc string
+}
+
+var builtinInfo = map[string]BuiltinInfo{
+	"len":         {Name: "len", Params: []string{"value"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns the length of a string or array."},
+	"print":       {Name: "print", Params: []string{"...values"}, MinArgs: 0, MaxArgs: -1, Doc: "Prints each value on its own line and returns null."},
+	"first":       {Name: "first", Params: []string{"array"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns the first element of an array, or null if it is empty."},
+	"rest":        {Name: "rest", Params: []string{"array"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns a new array with every element but the first, or null if there are fewer than two."},
+	"last":        {Name: "last", Params: []string{"array"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns the last element of an array, or null if it is empty."},
+	"push":        {Name: "push", Params: []string{"array", "value"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns a new array with value added to the end."},
+	"join":        {Name: "join", Params: []string{"array", "separator"}, MinArgs: 2, MaxArgs: 2, Doc: "Joins an array of strings into one string with separator between each element."},
+	"toUpperCase": {Name: "toUpperCase", Params: []string{"string"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns string in upper case."},
+	"toLowerCase": {Name: "toLowerCase", Params: []string{"string"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns string in lower case."},
+	"split":       {Name: "split", Params: []string{"string", "separator"}, MinArgs: 2, MaxArgs: 2, Doc: "Splits string into an array of strings at each separator."},
+	"keys":        {Name: "keys", Params: []string{"hash"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns an array of a hash's keys in the order they were added."},
+	"values":      {Name: "values", Params: []string{"hash"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns an array of a hash's values in the order their keys were added."},
+	"entries":     {Name: "entries", Params: []string{"hash"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns an array of [key, value] arrays for each pair in a hash."},
+	"fromEntries": {Name: "fromEntries", Params: []string{"entries"}, MinArgs: 1, MaxArgs: 1, Doc: "Makes a hash from an array of [key, value] arrays."},
+	"has":         {Name: "has", Params: []string{"hash", "key"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns whether a hash has a key."},
+	"delete":      {Name: "delete", Params: []string{"hash", "key"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns a new hash without key."},
+	"merge":       {Name: "merge", Params: []string{"...hashes"}, MinArgs: 1, MaxArgs: -1, Doc: "Returns a new hash with the pairs of every hash. Later hashes win when keys repeat."},
+	"deepMerge":   {Name: "deepMerge", Params: []string{"...hashes"}, MinArgs: 1, MaxArgs: -1, Doc: "Like merge, but hashes at the same key are merged too."},
+	"map":         {Name: "map", Params: []string{"array", "fn"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns a new array with the result of calling fn(element, index) on each element."},
+	"filter":      {Name: "filter", Params: []string{"array", "fn"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns a new array with the elements fn(element, index) is truthy for."},
+	"reduce":      {Name: "reduce", Params: []string{"array", "fn", "initial"}, MinArgs: 2, MaxArgs: 3, Doc: "Combines the elements into one value with fn(total, element, index). Starts from initial or the first element."},
+	"find":        {Name: "find", Params: []string{"array", "fn"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns the first element fn(element, index) is truthy for, or null."},
+	"findIndex":   {Name: "findIndex", Params: []string{"array", "fn"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns the index of the first element fn(element, index) is truthy for, or -1."},
+	"some":        {Name: "some", Params: []string{"array", "fn"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns whether fn(element, index) is truthy for any element."},
+	"every":       {Name: "every", Params: []string{"array", "fn"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns whether fn(element, index) is truthy for every element."},
+	"sort":        {Name: "sort", Params: []string{"array", "comparator"}, MinArgs: 1, MaxArgs: 2, Doc: "Returns a sorted copy of an array. comparator(a, b) returns a negative number if a goes first, a positive one if b does or 0."},
+	"reverse":     {Name: "reverse", Params: []string{"array"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns a new array with the elements in reverse order."},
+	"concat":      {Name: "concat", Params: []string{"...arrays"}, MinArgs: 0, MaxArgs: -1, Doc: "Returns a new array with the elements of every array."},
+	"flatten":     {Name: "flatten", Params: []string{"array", "depth"}, MinArgs: 1, MaxArgs: 2, Doc: "Takes the elements out of nested arrays, depth (default 1) levels deep."},
+	"zip":         {Name: "zip", Params: []string{"...arrays"}, MinArgs: 1, MaxArgs: -1, Doc: "Pairs up the elements of arrays by index. Stops at the end of the shortest one."},
+	"unique":      {Name: "unique", Params: []string{"array"}, MinArgs: 1, MaxArgs: 1, Doc: "Returns a new array without repeated elements."},
+	"indexOf":     {Name: "indexOf", Params: []string{"array", "value"}, MinArgs: 2, MaxArgs: 2, Doc: "Returns the index of the first element equal to value, or -1."},
+}
+
+func LookupBuiltin(name string) (BuiltinInfo, bool) {
+	info, ok := builtinInfo[name]
+	return info, ok
+}
+
+// Returns the names of every builtin in alphabetical order
+func BuiltinNames() []string {
+	names := []string{}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
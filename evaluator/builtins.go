@@ -30,6 +30,20 @@ var builtins = map[string]*object.Builtin{
 	"toUpperCase": {Fn: toUpperCase},
 	"toLowerCase": {Fn: toLowerCase},
 	"split":       {Fn: split},
+	"keys":        {Fn: keys},
+	"values":      {Fn: values},
+	"entries":     {Fn: entries},
+	"fromEntries": {Fn: fromEntries},
+	"has":         {Fn: has},
+	"delete":      {Fn: deleteKey},
+	"merge":       {Fn: merge},
+	"deepMerge":   {Fn: deepMerge},
+	"reverse":     {Fn: reverse},
+	"concat":      {Fn: concat},
+	"flatten":     {Fn: flatten},
+	"zip":         {Fn: zip},
+	"unique":      {Fn: unique},
+	"indexOf":     {Fn: indexOfElement},
 }
 
 // Called `puts` in the book.
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"cmp"
 	"fmt"
 	"monkey-pl/ast"
 	"monkey-pl/object"
@@ -49,9 +50,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return evalFunctionLiteral(node, env)
+	case *ast.MacroLiteral:
+		// Macro definitions are removed by DefineMacros before evaluation
+		return newError("macros can only be defined with a top level let statement")
+	case *ast.FunctionStatement:
+		function := evalFunctionLiteral(node.Function, env)
+		env.Set(node.Name.Value, function)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.PrefixExpression:
@@ -81,19 +86,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalIndexExpression(left, index)
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(function, args)
+		return evalCallExpression(node, env, false)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, false)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, false)
 	case *ast.WhileExpression:
 		return evalWhileExpression(node, env)
 	case *ast.ReturnStatement:
@@ -107,6 +104,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(value) {
 			return value
 		}
+		if node.Pattern != nil {
+			if err := bindPattern(node.Pattern, value, env); err != nil {
+				return err
+			}
+			return nil
+		}
+		// `let f = fn(x) {...}` names the function `f` so that error
+		// messages have something more useful to point to
+		if fn, ok := value.(*object.Function); ok && fn.Name == "" && isFunctionLiteral(node.Value) {
+			fn.Name = node.Name.Value
+		}
 		env.Set(node.Name.Value, value)
 	}
 	return nil
@@ -126,9 +134,15 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+// When tail is true the block is the body of a function (or a branch
+// of an if in tail position) and its last statement is evaluated in
+// tail position. See evalTailPosition.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, tail bool) object.Object {
 	var result object.Object
-	for _, statement := range block.Statements {
+	for i, statement := range block.Statements {
+		if tail && i == len(block.Statements)-1 {
+			return evalTailPosition(statement, env)
+		}
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
@@ -140,6 +154,52 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// Evaluates the last thing a function does. Calls to Monkey functions in
+// tail position aren't made here. Instead a TailCall is handed back to
+// applyFunction which runs it in a loop (a trampoline) so that tail
+// recursive functions run in constant stack space.
+func evalTailPosition(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return evalTailPosition(node.Expression, env)
+	case *ast.ReturnStatement:
+		value := evalTailPosition(node.ReturnValue, env)
+		if isError(value) || isTailCall(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env, true)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env, true)
+	case *ast.CallExpression:
+		return evalCallExpression(node, env, true)
+	default:
+		return Eval(node, env)
+	}
+}
+
+func evalCallExpression(node *ast.CallExpression, env *object.Environment, tail bool) object.Object {
+	if isQuoteCall(node) {
+		if len(node.Arguments) != 1 {
+			return newError("wrong number of arguments. Expected 1. Got %d.", len(node.Arguments))
+		}
+		return quote(node.Arguments[0], env)
+	}
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	if fn, ok := function.(*object.Function); ok && tail {
+		return &object.TailCall{Fn: fn, Args: args}
+	}
+	return applyFunction(function, args)
+}
+
 func evalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
@@ -159,10 +219,13 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	// but if we add more datatypes (e.g. strings) we may need to change this
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		return evalArrayInfixExpression(operator, left, right)
+	// Everything else is compared by value, so `{"a": [1]} == {"a": [1]}`
 	case operator == "==":
-		return objectFromBool(left == right)
+		return objectFromBool(object.Equal(left, right))
 	case operator == "!=":
-		return objectFromBool(left != right)
+		return objectFromBool(!object.Equal(left, right))
 	// This check takes place after equality check because equality checking
 	// two objects of different types is legal (but always false).
 	case left.Type() != right.Type():
@@ -206,19 +269,64 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	switch operator {
 	case "+":
 		return &object.String{Value: lval + rval}
+	// These have to be the TRUE and FALSE singletons since
+	// isTruthy compares against them
 	case "==":
-		return &object.Boolean{Value: strings.Compare(lval, rval) == 0}
+		return objectFromBool(lval == rval)
 	case "!=":
-		return &object.Boolean{Value: strings.Compare(lval, rval) != 0}
+		return objectFromBool(lval != rval)
 	case "<":
-		return &object.Boolean{Value: strings.Compare(lval, rval) == -1}
+		return objectFromBool(strings.Compare(lval, rval) == -1)
 	case ">":
-		return &object.Boolean{Value: strings.Compare(lval, rval) == 1}
+		return objectFromBool(strings.Compare(lval, rval) == 1)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
+func evalArrayInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "==":
+		return objectFromBool(object.Equal(left, right))
+	case "!=":
+		return objectFromBool(!object.Equal(left, right))
+	case "<", ">":
+		order, err := compareObjects(left, right)
+		if err != nil {
+			return err
+		}
+		if operator == "<" {
+			return objectFromBool(order < 0)
+		}
+		return objectFromBool(order > 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// Arrays are ordered like strings are: element by element, and if one
+// runs out first it's the smaller one. Only integers, strings and arrays
+// of those can be ordered.
+func compareObjects(left, right object.Object) (int, *object.Error) {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return cmp.Compare(left.(*object.Integer).Value, right.(*object.Integer).Value), nil
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return strings.Compare(left.(*object.String).Value, right.(*object.String).Value), nil
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ:
+		leftElements := left.(*object.Array).Elements
+		rightElements := right.(*object.Array).Elements
+		for i := 0; i < len(leftElements) && i < len(rightElements); i++ {
+			if order, err := compareObjects(leftElements[i], rightElements[i]); err != nil || order != 0 {
+				return order, err
+			}
+		}
+		return cmp.Compare(len(leftElements), len(rightElements)), nil
+	default:
+		return 0, newError("cannot compare %s and %s", left.Type(), right.Type())
+	}
+}
+
 func evalIndexExpression(left object.Object, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
@@ -241,27 +349,28 @@ func evalArrayIndexExpression(left *object.Array, index *object.Integer) object.
 
 func evalHashIndexExpression(left, index object.Object) object.Object {
 	hashObject := left.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
-	return pair.Value
+	return value
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.OrderedKeys() {
+		valueNode := node.Pairs[keyNode]
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
 			return newError("unhashable object used as a hash key: %s", key.Type())
 		}
@@ -272,21 +381,36 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
+}
+
+func evalFunctionLiteral(node *ast.FunctionLiteral, env *object.Environment) *object.Function {
+	fn := &object.Function{Name: node.Name, Parameters: node.Parameters, Env: env, Body: node.Body}
+	// Named function literals can always refer to themselves, even when
+	// they are used as expressions (e.g. `let f = fn fact(n) { fact(n - 1) }`)
+	if node.Name != "" {
+		fn.Env = object.NewEnclosedEnvironment(env)
+		fn.Env.Set(node.Name, fn)
+	}
+	return fn
+}
+
+func isFunctionLiteral(node ast.Expression) bool {
+	_, ok := node.(*ast.FunctionLiteral)
+	return ok
 }
 
-func evalIfExpression(expr *ast.IfExpression, env *object.Environment) object.Object {
+func evalIfExpression(expr *ast.IfExpression, env *object.Environment, tail bool) object.Object {
 	condition := Eval(expr.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(expr.Consequence, env)
+		return evalBlockStatement(expr.Consequence, env, tail)
 	} else if expr.Alternative != nil {
-		return Eval(expr.Alternative, env)
+		return evalBlockStatement(expr.Alternative, env, tail)
 	} else {
 		return NULL
 	}
@@ -331,6 +455,14 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 	for _, e := range exprs {
+		if spread, ok := e.(*ast.SpreadElement); ok {
+			elements := evalSpreadElement(spread, env)
+			if len(elements) == 1 && isError(elements[0]) {
+				return elements
+			}
+			result = append(result, elements...)
+			continue
+		}
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -340,19 +472,35 @@ func evalExpressions(exprs []ast.Expression, env *object.Environment) []object.O
 	return result
 }
 
+func evalSpreadElement(spread *ast.SpreadElement, env *object.Environment) []object.Object {
+	value := Eval(spread.Value, env)
+	if isError(value) {
+		return []object.Object{value}
+	}
+	arr, ok := value.(*object.Array)
+	if !ok {
+		return []object.Object{newError("spread operator can only be used on an array. received ...%s", value.Type())}
+	}
+	return arr.Elements
+}
+
+const maxTailCalls = 100000
+
 func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		if len(fn.Parameters) != len(args) {
-			return newError("function was called with an incorrect number of arguments: expected %d", len(fn.Parameters))
-		}
-		defer decrementStackDepth()
-		extendedEnv := extendFunctionEnv(fn, args)
-		if stackDepth > 150 {
-			return newError("maximum stack depth exceeded")
+		// Tail calls come back as TailCall objects instead of growing the
+		// stack, so we keep calling until we get an actual value. They
+		// still get a (much bigger) limit so infinite recursion can't hang.
+		for calls := 0; calls < maxTailCalls; calls++ {
+			result := callFunction(fn, args)
+			tailCall, ok := result.(*object.TailCall)
+			if !ok {
+				return result
+			}
+			fn, args = tailCall.Fn, tailCall.Args
 		}
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		return newError("maximum stack depth exceeded")
 	case *object.Builtin:
 		return fn.Fn(args...)
 	default:
@@ -360,13 +508,176 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+func callFunction(fn *object.Function, args []object.Object) object.Object {
+	if err := checkArity(fn, len(args)); err != nil {
+		return err
+	}
+	defer decrementStackDepth()
+	extendedEnv, err := extendFunctionEnv(fn, args)
+	if err != nil {
+		return err
+	}
+	if stackDepth > 150 {
+		return newError("maximum stack depth exceeded")
+	}
+	evaluated := evalBlockStatement(fn.Body, extendedEnv, true)
+	return unwrapReturnValue(evaluated)
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "function"
+	}
+	return fmt.Sprintf("function `%s`", fn.Name)
+}
+
+// Returns the smallest and largest number of arguments a function
+// accepts. max is -1 when the function has a rest parameter.
+func FunctionArity(params []ast.Pattern) (min int, max int) {
+	for _, param := range params {
+		switch param.(type) {
+		case *ast.RestElement:
+			return min, -1
+		case *ast.AssignmentPattern:
+			max++
+		default:
+			min++
+			max++
+		}
+	}
+	return min, max
+}
+
+func checkArity(fn *object.Function, argCount int) *object.Error {
+	min, max := FunctionArity(fn.Parameters)
+	if argCount >= min && (max == -1 || argCount <= max) {
+		return nil
+	}
+	var expected string
+	switch {
+	case max == -1:
+		expected = fmt.Sprintf("at least %d", min)
+	case min == max:
+		expected = fmt.Sprintf("%d", min)
+	default:
+		expected = fmt.Sprintf("%d to %d", min, max)
+	}
+	return newError("%s was called with an incorrect number of arguments: expected %s, got %d", functionName(fn), expected, argCount)
+}
+
+// Arity is checked before this is called so there are always enough
+// arguments for the required parameters
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
 	incrementStackDepth()
 	env := object.NewEnclosedEnvironment(fn.Env)
 	for i, param := range fn.Parameters {
-		env.Set(param.Value, args[i])
+		if rest, ok := param.(*ast.RestElement); ok {
+			bindRestElement(rest, args[min(i, len(args)):], env)
+			continue
+		}
+		// Missing arguments are nil so that defaults kick in. Defaults are
+		// evaluated in the function's environment so they can refer to the
+		// parameters that came before them
+		var arg object.Object
+		if i < len(args) {
+			arg = args[i]
+		}
+		if err := bindPattern(param, arg, env); err != nil {
+			return nil, err
+		}
 	}
-	return env
+	return env, nil
+}
+
+// Binds value to every name in pattern. A nil value means that nothing
+// was supplied (e.g. a missing argument or an array that is too short),
+// which is when an AssignmentPattern falls back to its default.
+func bindPattern(pattern ast.Pattern, value object.Object, env *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if value == nil {
+			value = NULL
+		}
+		env.Set(pattern.Value, value)
+		return nil
+	case *ast.AssignmentPattern:
+		if value == nil {
+			value = Eval(pattern.Default, env)
+			if isError(value) {
+				return value.(*object.Error)
+			}
+		}
+		return bindPattern(pattern.Target, value, env)
+	case *ast.ArrayPattern:
+		return bindArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return bindHashPattern(pattern, value, env)
+	default:
+		return newError("cannot bind a value to %s", pattern.String())
+	}
+}
+
+func bindArrayPattern(pattern *ast.ArrayPattern, value object.Object, env *object.Environment) *object.Error {
+	arr, ok := value.(*object.Array)
+	if !ok {
+		return newError("cannot destructure %s as an array", typeOf(value))
+	}
+	for i, element := range pattern.Elements {
+		if rest, ok := element.(*ast.RestElement); ok {
+			bindRestElement(rest, arr.Elements[min(i, len(arr.Elements)):], env)
+			continue
+		}
+		var item object.Object
+		if i < len(arr.Elements) {
+			item = arr.Elements[i]
+		}
+		if err := bindPattern(element, item, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bindHashPattern(pattern *ast.HashPattern, value object.Object, env *object.Environment) *object.Error {
+	hash, ok := value.(*object.Hash)
+	if !ok {
+		return newError("cannot destructure %s as a hash", typeOf(value))
+	}
+	// property names are always strings
+	used := map[string]bool{}
+	for _, property := range pattern.Properties {
+		key := &object.String{Value: property.Key}
+		used[property.Key] = true
+		item, _ := hash.Get(key)
+		if err := bindPattern(property.Value, item, env); err != nil {
+			return err
+		}
+	}
+	if pattern.Rest != nil {
+		rest := object.NewHash()
+		for _, pair := range hash.Pairs() {
+			if key, ok := pair.Key.(*object.String); !ok || !used[key.Value] {
+				rest.Set(pair.Key.(object.Hashable), pair.Value)
+			}
+		}
+		env.Set(pattern.Rest.Target.Value, rest)
+	}
+	return nil
+}
+
+// Rest elements always get a fresh array so that they don't share
+// a backing array with the value they were taken from
+func bindRestElement(rest *ast.RestElement, values []object.Object, env *object.Environment) {
+	elements := make([]object.Object, len(values))
+	copy(elements, values)
+	env.Set(rest.Target.Value, &object.Array{Elements: elements})
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -420,6 +731,11 @@ func newError(format string, a ...interface{}) *object.Error {
 	}
 }
 
+func isTailCall(obj object.Object) bool {
+	_, ok := obj.(*object.TailCall)
+	return ok
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.ERROR_OBJ
@@ -4,6 +4,7 @@ import (
 	"monkey-pl/lexer"
 	"monkey-pl/object"
 	"monkey-pl/parser"
+	"strings"
 	"testing"
 )
 
@@ -70,6 +71,41 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, 2] != [1, 2]", false},
+		{"[1, 2] == [2, 1]", false},
+		{"[1, [2, [3]]] == [1, [2, [3]]]", true},
+		{`[1, "1"] == [1, 1]`, false},
+		{"[] == []", true},
+		{"[1] == 1", false},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"{} == {}", true},
+		{`{[1, 2]: "x"} == {[1, 2]: "x"}`, true},
+		{"let f = fn(x) { x }; f == f", true},
+		{"fn(x) { x } == fn(x) { x }", false},
+		{"let make = fn() { fn() { 1 } }; make() == make()", false},
+		{"len == len", true},
+		{"len == first", false},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] < [1, 2, 0]", true},
+		{"[2] > [1, 5]", true},
+		{`["b"] > ["a", "z"]`, true},
+		{"[[1, 2]] < [[1, 1]]", false},
+		{"[] < []", false},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		testBooleanObject(t, evaluated, tc.expected)
+	}
+}
+
 func TestEvalStringExpression(t *testing.T) {
 	input := `"Hello, World!"`
 	evaluated := testEval(input)
@@ -107,6 +143,8 @@ func TestBooleanOperator(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		{`!("a" == "b")`, true},
+		{`!("a" < "b")`, false},
 	}
 	for _, tc := range tests {
 		evaluated := testEval(tc.input)
@@ -226,6 +264,22 @@ func TestErrorHandling(t *testing.T) {
 			`let f = fn(x) { x; }; { f: "Monkey" };`,
 			"unhashable object used as a hash key: FUNCTION",
 		},
+		{
+			`{[1, fn(x) { x }]: "Monkey"};`,
+			"unhashable object used as a hash key: ARRAY",
+		},
+		{
+			`[1, 2] < [1, "2"]`,
+			"cannot compare INTEGER and STRING",
+		},
+		{
+			"[1] + [2]",
+			"unknown operator: ARRAY + ARRAY",
+		},
+		{
+			`let func = fn(x) { 1 + func(x + 1); }; func(1);`,
+			"maximum stack depth exceeded",
+		},
 		{
 			`let func = fn(x) { func(x + 1); }; func(1);`,
 			"maximum stack depth exceeded",
@@ -304,6 +358,261 @@ func TestFunctionCall(t *testing.T) {
 	}
 }
 
+func TestFunctionStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"fn add(x, y) { x + y }; add(2, 3);", 5},
+		{"fn add(x, y) { x + y } add(2, 3);", 5},
+		{"fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(5);", 120},
+		{"let f = fn fact(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; f(4);", 24},
+		{"fn outer() { fn inner() { 3 }; inner() }; outer();", 3},
+	}
+	for _, tc := range tests {
+		testIntegerObject(t, testEval(tc.input), tc.expected)
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn add(x, y) { x + y }; add;", "add"},
+		{"let add = fn(x, y) { x + y }; add;", "add"},
+		{"let f = fn g() { 1 }; f;", "g"},
+		{"fn(x) { x };", ""},
+		{"let f = fn() { fn() { 1 } }; let g = f(); g;", ""},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		fn, ok := evaluated.(*object.Function)
+		if !ok {
+			t.Errorf("Expected a function object to be returned. Got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if fn.Name != tc.expected {
+			t.Errorf("Expected function name to be %q. Got %q", tc.expected, fn.Name)
+		}
+	}
+}
+
+func TestFunctionArityErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"fn add(x, y) { x + y }; add(1);",
+			"function `add` was called with an incorrect number of arguments: expected 2, got 1",
+		},
+		{
+			"let add = fn(x, y) { x + y }; add(1, 2, 3);",
+			"function `add` was called with an incorrect number of arguments: expected 2, got 3",
+		},
+		{
+			"fn(x) { x }();",
+			"function was called with an incorrect number of arguments: expected 1, got 0",
+		},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Expected an error object to be returned. Got %T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tc.expected {
+			t.Errorf("Expected error message to be %q. Got %q", tc.expected, errObj.Message)
+		}
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 10) { a + b }; f(1);", "11"},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2);", "3"},
+		{"let f = fn(a, b = a * 2) { a + b }; f(3);", "9"},
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3);", "[2, 3]"},
+		{"let f = fn(a, ...rest) { rest }; f(1);", "[]"},
+		{"let f = fn(a = 1, ...rest) { [a, rest] }; f();", "[1, []]"},
+		{"let f = fn(a, b) { a + b }; f(...[1, 2]);", "3"},
+		{"let f = fn(...xs) { len(xs) }; f(1, ...[2, 3], 4);", "4"},
+		{"let a = [1, 2]; let b = [3]; [...a, ...b, 4];", "[1, 2, 3, 4]"},
+		{"[...[]];", "[]"},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Expected %q to evaluate to %s. Got %s", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2]; a + b;", "3"},
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; rest;", "[3, 4]"},
+		{"let [a, b = 10] = [1]; b;", "10"},
+		{"let [a, b] = [1]; b;", "null"},
+		{"let [[a, b], c] = [[1, 2], 3]; [a, b, c];", "[1, 2, 3]"},
+		{`let {name, age: years} = {"name": "Monkey", "age": 3}; [name, years];`, `["Monkey", 3]`},
+		{`let {name = "anon", age = 1} = {"age": 3}; [name, age];`, `["anon", 3]`},
+		{`let {a, ...others} = {"a": 1, "b": 2}; others["b"];`, "2"},
+		{`let {pets: [first]} = {"pets": ["cat", "dog"]}; first;`, "cat"},
+		{"let f = fn([a, b]) { a * b }; f([3, 4]);", "12"},
+		{`fn greet({name}, greeting = "hi") { greeting + " " + name }; greet({"name": "Monkey"});`, "hi Monkey"},
+		{"let f = fn([a, b] = [1, 2]) { a + b }; f();", "3"},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Expected %q to evaluate to %s. Got %s", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+// Annotations are only for the checker so a wrong one makes no difference here
+func TestTypeAnnotationsAreIgnored(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5; x;", "5"},
+		{"let x: string = 5; x;", "5"},
+		{"let f = fn(a: int, b: [int] = [1]) -> [int] { push(b, a) }; f(2);", "[1, 2]"},
+		{"let f = fn(s: int) -> bool { s }; f(\"not an int\");", "not an int"},
+		{"fn g(...xs: [any]) -> int { len(xs) } g(1, 2);", "2"},
+		{"let [a: int, b: string] = [1, 2]; a + b;", "3"},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Expected %q to evaluate to %s. Got %s", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a] = 5;", "cannot destructure INTEGER as an array"},
+		{"let {a} = [1];", "cannot destructure ARRAY as a hash"},
+		{"let f = fn([a]) { a }; f(1);", "cannot destructure INTEGER as an array"},
+		{"let [[a]] = [];", "cannot destructure NULL as an array"},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Expected an error object to be returned. Got %T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tc.expected {
+			t.Errorf("Expected error message to be %q. Got %q", tc.expected, errObj.Message)
+		}
+	}
+}
+
+func TestArityRangeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"fn f(a, b = 1) { a }; f();",
+			"function `f` was called with an incorrect number of arguments: expected 1 to 2, got 0",
+		},
+		{
+			"fn f(a, b = 1) { a }; f(1, 2, 3);",
+			"function `f` was called with an incorrect number of arguments: expected 1 to 2, got 3",
+		},
+		{
+			"fn f(a, b, ...c) { a }; f(1);",
+			"function `f` was called with an incorrect number of arguments: expected at least 2, got 1",
+		},
+		{
+			"fn f(a) { a }; f(...[1, 2]);",
+			"function `f` was called with an incorrect number of arguments: expected 1, got 2",
+		},
+		{
+			"[...5]",
+			"spread operator can only be used on an array. received ...INTEGER",
+		},
+		{
+			"fn f(a = x) { a }; f();",
+			"identifier not found: x",
+		},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Expected an error object to be returned. Got %T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tc.expected {
+			t.Errorf("Expected error message to be %q. Got %q", tc.expected, errObj.Message)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } };
+			count(10000, 0);`,
+			10000,
+		},
+		{
+			`fn count(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }
+			count(10000, 0);`,
+			10000,
+		},
+		{
+			`fn isEven(n) { if (n == 0) { 1 } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { 0 } else { isEven(n - 1) } }
+			isEven(5001);`,
+			0,
+		},
+		{
+			`let reduce = fn(arr, initial, f) {
+				let iter = fn(i, result) {
+					if (i == len(arr)) {
+						result
+					} else {
+						iter(i + 1, f(result, arr[i]));
+					}
+				};
+				iter(0, initial);
+			};
+			let sum = fn(arr) { reduce(arr, 0, fn(acc, x) { acc + x }) };
+			sum([1, 2, 3, 4, 5]);`,
+			15,
+		},
+		{
+			`fn sumTo(n, acc = 0) { if (n == 0) { acc } else { sumTo(n - 1, acc + n) } }
+			sumTo(1000);`,
+			500500,
+		},
+	}
+	for _, tc := range tests {
+		testIntegerObject(t, testEval(tc.input), tc.expected)
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -346,6 +655,120 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`keys({"b": 1, "a": 2})`, `["b", "a"]`},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`entries({"b": 1, [1]: true})`, `[["b", 1], [[1], true]]`},
+		{"keys({})", "[]"},
+		{`fromEntries([["a", 1], [2, "b"]])`, `{"a": 1, 2: "b"}`},
+		{`fromEntries(entries({"x": [1], "y": 2}))`, `{"x": [1], "y": 2}`},
+		{`fromEntries([["a", 1], ["a", 2]])`, `{"a": 2}`},
+		{`has({"a": false}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({[1, 2]: 1}, [1, 2])`, "true"},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, `{"a": 1, "c": 3}`},
+		{`delete({"a": 1}, "z")`, `{"a": 1}`},
+		// the original hash isn't changed
+		{`let h = {"a": 1}; let d = delete(h, "a"); [h, d]`, `[{"a": 1}, {}]`},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, `{"a": 1, "b": 3, "c": 4}`},
+		{`merge({"a": 1})`, `{"a": 1}`},
+		{`merge({"a": {"x": 1}}, {"a": {"y": 2}})`, `{"a": {"y": 2}}`},
+		{`deepMerge({"a": {"x": 1, "z": 0}, "b": 1}, {"a": {"y": 2, "z": {"deep": true}}}, {"b": 5})`, `{"a": {"x": 1, "z": {"deep": true}, "y": 2}, "b": 5}`},
+		{`deepMerge({"a": {"x": 1}}, {"a": 2})`, `{"a": 2}`},
+		{`let h = {"a": {"x": 1}}; deepMerge(h, {"a": {"y": 2}}); h`, `{"a": {"x": 1}}`},
+		// argument errors
+		{"keys([1])", "Error: argument 1 to `keys` should be a hash. received ARRAY"},
+		{`keys({}, {})`, "Error: wrong number of arguments. Expected 1. Got 2."},
+		{`has({}, fn() { 1 })`, "Error: unusable as hash key: FUNCTION"},
+		{`delete(1, "a")`, "Error: argument 1 to `delete` should be a hash. received INTEGER"},
+		{`merge({}, [])`, "Error: argument 2 to `merge` should be a hash. received ARRAY"},
+		{`merge()`, "Error: wrong number of arguments. Expected at least 1. Got 0."},
+		{`fromEntries({})`, "Error: argument 1 to `fromEntries` should be an array. received HASH"},
+		{`fromEntries([["a", 1], ["b"]])`, `Error: ` + "`fromEntries`" + ` expects [key, value] arrays. received ["b"] at index 1`},
+		{`fromEntries([[{}, 1]])`, "Error: unusable as hash key: HASH"},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Expected %q to evaluate to %s. Got %s", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestArrayLibrary(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"map([1, 2, 3], fn(x, i) { x * i })", "[0, 2, 6]"},
+		{`map(["a", "bc"], len)`, "[1, 2]"},
+		{"map([1, 2], fn(...xs) { xs })", "[[1, 0], [2, 1]]"},
+		{"map([], fn(x) { x })", "[]"},
+		{"map([1], fn(x) { let y = x; })", "[null]"},
+		{"filter([1, 2, 3, 4], fn(x) { x > 2 })", "[3, 4]"},
+		{"filter([5, 6, 7], fn(x, i) { i != 1 })", "[5, 7]"},
+		{"reduce([1, 2, 3, 4], fn(total, x) { total + x }, 0)", "10"},
+		{"reduce([1, 2, 3, 4], fn(total, x) { total * x })", "24"},
+		{"reduce([10, 20], fn(total, x, i) { total + x * i }, 0)", "20"},
+		{"reduce([], fn(total, x) { total + x }, 5)", "5"},
+		{"find([1, 2, 3], fn(x) { x > 1 })", "2"},
+		{"find([1, 2, 3], fn(x) { x > 5 })", "null"},
+		{"findIndex([1, 2, 3], fn(x) { x > 1 })", "1"},
+		{"findIndex([1, 2, 3], fn(x) { x > 5 })", "-1"},
+		{"some([1, 2, 3], fn(x) { x == 2 })", "true"},
+		{"some([], fn(x) { true })", "false"},
+		{"every([1, 2, 3], fn(x) { x > 0 })", "true"},
+		{"every([1, 2, 3], fn(x) { x > 1 })", "false"},
+		{"every([], fn(x) { false })", "true"},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, `["a", "b", "c"]`},
+		{"sort([[2, 1], [1, 5], [1]])", "[[1], [1, 5], [2, 1]]"},
+		{"sort([3, 1, 2], fn(a, b) { b - a })", "[3, 2, 1]"},
+		// stable, so equal elements keep their order
+		{`sort([[2, "a"], [1, "b"], [2, "c"]], fn(a, b) { a[0] - b[0] })`, `[[1, "b"], [2, "a"], [2, "c"]]`},
+		{"let xs = [2, 1]; sort(xs); xs", "[2, 1]"},
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{"reverse([])", "[]"},
+		{"concat([1], [2, 3], [])", "[1, 2, 3]"},
+		{"concat()", "[]"},
+		{"flatten([1, [2, [3, [4]]]])", "[1, 2, [3, [4]]]"},
+		{"flatten([1, [2, [3, [4]]]], 2)", "[1, 2, 3, [4]]"},
+		{"flatten([1, [2]], 0)", "[1, [2]]"},
+		{`zip([1, 2, 3], ["a", "b"])`, `[[1, "a"], [2, "b"]]`},
+		{"zip([1], [2], [3])", "[[1, 2, 3]]"},
+		{`unique([1, "1", 1, [1], [1], 2])`, `[1, "1", [1], 2]`},
+		{"let f = fn() { 1 }; len(unique([f, f, {}, {}]))", "2"},
+		{`indexOf([1, [2], "3"], [2])`, "1"},
+		{"indexOf([1, 2], 3)", "-1"},
+		// callbacks run in constant stack space, unlike a recursive map written in Monkey
+		{"len(filter(split(\"" + strings.Repeat("ab", 300) + "\", \"\"), fn(x) { x == \"a\" }))", "300"},
+		// errors
+		{"map([1], 5)", "Error: argument 2 to `map` should be a function. received INTEGER"},
+		{"map(1, fn(x) { x })", "Error: argument 1 to `map` should be an array. received INTEGER"},
+		{"map([1])", "Error: wrong number of arguments. Expected 2. Got 1."},
+		{"map([1, 0], fn(x) { 1 / x })", "Error: illegal operation: divide by zero"},
+		{"filter([1], fn(a, b, c) { a })", "Error: function was called with an incorrect number of arguments: expected 3, got 2"},
+		{"reduce([], fn(total, x) { total + x })", "Error: `reduce` of an empty array needs an initial value"},
+		{"reduce([1], fn(total, x) { x }, 0, 1)", "Error: wrong number of arguments. Expected 2 or 3. Got 4."},
+		{`sort([1, "a"])`, "Error: cannot compare STRING and INTEGER"},
+		{`sort([1, 2], fn(a, b) { "no" })`, "Error: comparator passed to `sort` should return an integer. received STRING"},
+		{"flatten([1], -1)", "Error: argument 2 to `flatten` should be a depth of 0 or more. received -1"},
+		{"concat([1], 2)", "Error: argument 2 to `concat` should be an array. received INTEGER"},
+		{"zip()", "Error: wrong number of arguments. Expected at least 1. Got 0."},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Expected %q to evaluate to %s. Got %s", tc.input, tc.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	evaluated := testEval(input)
@@ -436,22 +859,47 @@ func TestHashLiterals(t *testing.T) {
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. Got %T (%+v)", evaluated, evaluated)
 	}
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	// pairs should come out in the order they were written
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Expected hash to have 6 pairs. Got %d", len(result.Pairs))
+	pairs := result.Pairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("Expected hash to have 6 pairs. Got %d", len(pairs))
 	}
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
-			testIntegerObject(t, pair.Value, expectedValue)
+	for i, pair := range pairs {
+		if pair.Key.(object.Hashable).HashKey() != expected[i].key.HashKey() {
+			t.Errorf("Expected key %d to be %s. Got %s", i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+		testIntegerObject(t, pair.Value, expected[i].value)
+		if value, ok := result.Get(expected[i].key); !ok || value != pair.Value {
+			t.Errorf("no pair for key %s", expected[i].key.Inspect())
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, "c": 3}`, `{"b": 1, "a": 2, "c": 3}`},
+		// a repeated key keeps its first position but gets the last value
+		{`{"b": 1, "a": 2, "b": 3}`, `{"b": 3, "a": 2}`},
+		{`let {a, ...rest} = {"z": 1, "a": 2, "y": 3}; rest`, `{"z": 1, "y": 3}`},
+	}
+	for _, tc := range tests {
+		evaluated := testEval(tc.input)
+		if evaluated.Inspect() != tc.expected {
+			t.Errorf("Expected %q to evaluate to %s. Got %s", tc.input, tc.expected, evaluated.Inspect())
 		}
 	}
 }
@@ -489,6 +937,22 @@ func TestHashIndexExpressions(t *testing.T) {
 			`{false: 5}[false]`,
 			5,
 		},
+		{
+			`{[1, "a"]: 5}[[1, "a"]]`,
+			5,
+		},
+		{
+			`let point = [1, 2]; {[1, 2]: 5}[point]`,
+			5,
+		},
+		{
+			`{[1, "a"]: 5}[[1, "b"]]`,
+			nil,
+		},
+		{
+			`{[1]: 5}[1]`,
+			nil,
+		},
 	}
 
 	for _, tc := range tests {
@@ -560,3 +1024,38 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+func TestBuiltinInfo(t *testing.T) {
+	if len(BuiltinNames()) != len(builtins) {
+		t.Fatalf("Expected %d builtin names. Got %d", len(builtins), len(BuiltinNames()))
+	}
+	for _, name := range BuiltinNames() {
+		info, ok := LookupBuiltin(name)
+		if !ok {
+			t.Errorf("No BuiltinInfo for builtin %s", name)
+			continue
+		}
+		if info.Name != name {
+			t.Errorf("Expected BuiltinInfo name to be %s. Got %s", name, info.Name)
+		}
+
+		// The info should agree with the argument checks in the builtin itself
+		wrongCounts := []int{}
+		if info.MinArgs > 0 {
+			wrongCounts = append(wrongCounts, info.MinArgs-1)
+		}
+		if info.MaxArgs != -1 {
+			wrongCounts = append(wrongCounts, info.MaxArgs+1)
+		}
+		for _, count := range wrongCounts {
+			args := make([]object.Object, count)
+			for i := range args {
+				args[i] = NULL
+			}
+			result, ok := builtins[name].Fn(args...).(*object.Error)
+			if !ok || !strings.Contains(result.Message, "wrong number of arguments") {
+				t.Errorf("Expected %s to reject %d arguments. Got %v", name, count, result)
+			}
+		}
+	}
+}
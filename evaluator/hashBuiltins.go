@@ -0,0 +1,173 @@
+package evaluator
+
+import "monkey-pl/object"
+
+// Like push, none of these change the hash they're given. The ones that
+// make changes return a new hash instead.
+
+// Returns the hash in args[i] or an error naming the builtin
+func hashArgument(name string, args []object.Object, i int) (*object.Hash, *object.Error) {
+	hash, ok := args[i].(*object.Hash)
+	if !ok {
+		return nil, newError("argument %d to `%s` should be a hash. received %s", i+1, name, args[i].Type())
+	}
+	return hash, nil
+}
+
+func keyArgument(key object.Object) (object.Hashable, *object.Error) {
+	hashable, ok := object.AsHashable(key)
+	if !ok {
+		return nil, newError("unusable as hash key: %s", key.Type())
+	}
+	return hashable, nil
+}
+
+func copyHash(hash *object.Hash) *object.Hash {
+	copied := object.NewHash()
+	for _, pair := range hash.Pairs() {
+		copied.Set(pair.Key.(object.Hashable), pair.Value)
+	}
+	return copied
+}
+
+func keys(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. Expected 1. Got %d.", len(args))
+	}
+	hash, err := hashArgument("keys", args, 0)
+	if err != nil {
+		return err
+	}
+	elements := []object.Object{}
+	for _, pair := range hash.Pairs() {
+		elements = append(elements, pair.Key)
+	}
+	return &object.Array{Elements: elements}
+}
+
+func values(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. Expected 1. Got %d.", len(args))
+	}
+	hash, err := hashArgument("values", args, 0)
+	if err != nil {
+		return err
+	}
+	elements := []object.Object{}
+	for _, pair := range hash.Pairs() {
+		elements = append(elements, pair.Value)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// Returns `[key, value]` arrays, which is what fromEntries takes
+func entries(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. Expected 1. Got %d.", len(args))
+	}
+	hash, err := hashArgument("entries", args, 0)
+	if err != nil {
+		return err
+	}
+	elements := []object.Object{}
+	for _, pair := range hash.Pairs() {
+		elements = append(elements, &object.Array{Elements: []object.Object{pair.Key, pair.Value}})
+	}
+	return &object.Array{Elements: elements}
+}
+
+func fromEntries(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. Expected 1. Got %d.", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument 1 to `fromEntries` should be an array. received %s", args[0].Type())
+	}
+	hash := object.NewHash()
+	for i, element := range arr.Elements {
+		entry, ok := element.(*object.Array)
+		if !ok || len(entry.Elements) != 2 {
+			return newError("`fromEntries` expects [key, value] arrays. received %s at index %d", element.Inspect(), i)
+		}
+		key, err := keyArgument(entry.Elements[0])
+		if err != nil {
+			return err
+		}
+		hash.Set(key, entry.Elements[1])
+	}
+	return hash
+}
+
+func has(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. Expected 2. Got %d.", len(args))
+	}
+	hash, err := hashArgument("has", args, 0)
+	if err != nil {
+		return err
+	}
+	key, err := keyArgument(args[1])
+	if err != nil {
+		return err
+	}
+	_, ok := hash.Get(key)
+	return objectFromBool(ok)
+}
+
+// Returns a new hash without key. It's fine if key isn't there.
+func deleteKey(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. Expected 2. Got %d.", len(args))
+	}
+	hash, err := hashArgument("delete", args, 0)
+	if err != nil {
+		return err
+	}
+	key, err := keyArgument(args[1])
+	if err != nil {
+		return err
+	}
+	deleted := copyHash(hash)
+	deleted.Delete(key)
+	return deleted
+}
+
+// Combines hashes into a new one. When more than one has a key, the value
+// from the last one wins, but the key stays where it first appeared.
+func merge(args ...object.Object) object.Object {
+	return mergeHashes("merge", args, false)
+}
+
+// Like merge, but when two hashes both have a hash at the same key
+// those are merged too instead of the last one replacing the other
+func deepMerge(args ...object.Object) object.Object {
+	return mergeHashes("deepMerge", args, true)
+}
+
+func mergeHashes(name string, args []object.Object, deep bool) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. Expected at least 1. Got %d.", len(args))
+	}
+	merged := object.NewHash()
+	for i := range args {
+		hash, err := hashArgument(name, args, i)
+		if err != nil {
+			return err
+		}
+		for _, pair := range hash.Pairs() {
+			key := pair.Key.(object.Hashable)
+			value := pair.Value
+			if deep {
+				existing, _ := merged.Get(key)
+				existingHash, leftOk := existing.(*object.Hash)
+				valueHash, rightOk := value.(*object.Hash)
+				if leftOk && rightOk {
+					value = mergeHashes(name, []object.Object{existingHash, valueHash}, true)
+				}
+			}
+			merged.Set(key, value)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,255 @@
+package evaluator
+
+import (
+	"monkey-pl/object"
+	"sort"
+)
+
+// These call back into Monkey functions with applyFunction. That makes
+// them part of an initialization cycle (builtins -> applyFunction -> Eval
+// -> builtins) if they're put straight into the builtins map, so they're
+// added here instead.
+func init() {
+	builtins["map"] = &object.Builtin{Fn: mapArray}
+	builtins["filter"] = &object.Builtin{Fn: filter}
+	builtins["reduce"] = &object.Builtin{Fn: reduce}
+	builtins["find"] = &object.Builtin{Fn: find}
+	builtins["findIndex"] = &object.Builtin{Fn: findIndex}
+	builtins["some"] = &object.Builtin{Fn: some}
+	builtins["every"] = &object.Builtin{Fn: every}
+	builtins["sort"] = &object.Builtin{Fn: sortArray}
+}
+
+// Returns the array in args[i] or an error naming the builtin
+func arrayArgument(name string, args []object.Object, i int) (*object.Array, *object.Error) {
+	arr, ok := args[i].(*object.Array)
+	if !ok {
+		return nil, newError("argument %d to `%s` should be an array. received %s", i+1, name, args[i].Type())
+	}
+	return arr, nil
+}
+
+func functionArgument(name string, args []object.Object, i int) (object.Object, *object.Error) {
+	switch args[i].(type) {
+	case *object.Function, *object.Builtin:
+		return args[i], nil
+	default:
+		return nil, newError("argument %d to `%s` should be a function. received %s", i+1, name, args[i].Type())
+	}
+}
+
+// Checks the (array, function) arguments most of these take
+func arrayAndFunction(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. Expected 2. Got %d.", len(args))
+	}
+	arr, err := arrayArgument(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fn, err := functionArgument(name, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return arr, fn, nil
+}
+
+// Calls fn with the first `required` args and as many of the rest as it
+// takes. That way callbacks can be `fn(x)` or `fn(x, i)` like they can in
+// JavaScript, without running into arity errors.
+func callback(fn object.Object, required int, args ...object.Object) object.Object {
+	count := required
+	if fn, ok := fn.(*object.Function); ok {
+		if _, max := FunctionArity(fn.Parameters); max == -1 || max > count {
+			count = max
+		}
+	}
+	if count == -1 || count > len(args) {
+		count = len(args)
+	}
+	return applyFunction(fn, args[:count])
+}
+
+func mapArray(args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunction("map", args)
+	if err != nil {
+		return err
+	}
+	mapped := make([]object.Object, 0, len(arr.Elements))
+	for i, element := range arr.Elements {
+		result := callback(fn, 1, element, &object.Integer{Value: int64(i)})
+		if isError(result) {
+			return result
+		}
+		if result == nil {
+			result = NULL
+		}
+		mapped = append(mapped, result)
+	}
+	return &object.Array{Elements: mapped}
+}
+
+func filter(args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunction("filter", args)
+	if err != nil {
+		return err
+	}
+	kept := []object.Object{}
+	for i, element := range arr.Elements {
+		result := callback(fn, 1, element, &object.Integer{Value: int64(i)})
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			kept = append(kept, element)
+		}
+	}
+	return &object.Array{Elements: kept}
+}
+
+// `reduce(array, fn(total, x, i) {}, initial)`. Without an initial value
+// the first element is used and the function starts at the second.
+func reduce(args ...object.Object) object.Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. Expected 2 or 3. Got %d.", len(args))
+	}
+	arr, fn, err := arrayAndFunction("reduce", args[:2])
+	if err != nil {
+		return err
+	}
+	elements := arr.Elements
+	start := 0
+	var total object.Object
+	if len(args) == 3 {
+		total = args[2]
+	} else if len(elements) == 0 {
+		return newError("`reduce` of an empty array needs an initial value")
+	} else {
+		total, start = elements[0], 1
+	}
+	for i := start; i < len(elements); i++ {
+		total = callback(fn, 2, total, elements[i], &object.Integer{Value: int64(i)})
+		if isError(total) {
+			return total
+		}
+		if total == nil {
+			total = NULL
+		}
+	}
+	return total
+}
+
+// Returns the index of the first element fn returns something truthy
+// for, or -1. find, findIndex and some are all built on this.
+func search(name string, args []object.Object) (int, object.Object) {
+	arr, fn, err := arrayAndFunction(name, args)
+	if err != nil {
+		return -1, err
+	}
+	for i, element := range arr.Elements {
+		result := callback(fn, 1, element, &object.Integer{Value: int64(i)})
+		if isError(result) {
+			return -1, result
+		}
+		if isTruthy(result) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func find(args ...object.Object) object.Object {
+	i, err := search("find", args)
+	if err != nil {
+		return err
+	}
+	if i == -1 {
+		return NULL
+	}
+	return args[0].(*object.Array).Elements[i]
+}
+
+func findIndex(args ...object.Object) object.Object {
+	i, err := search("findIndex", args)
+	if err != nil {
+		return err
+	}
+	return &object.Integer{Value: int64(i)}
+}
+
+func some(args ...object.Object) object.Object {
+	i, err := search("some", args)
+	if err != nil {
+		return err
+	}
+	return objectFromBool(i != -1)
+}
+
+func every(args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunction("every", args)
+	if err != nil {
+		return err
+	}
+	for i, element := range arr.Elements {
+		result := callback(fn, 1, element, &object.Integer{Value: int64(i)})
+		if isError(result) {
+			return result
+		}
+		if !isTruthy(result) {
+			return FALSE
+		}
+	}
+	return TRUE
+}
+
+// Returns a sorted copy. Without a comparator, integers, strings and
+// arrays of those are sorted in the order `<` uses. A comparator is
+// called with two elements and returns a negative number if the first
+// goes first, a positive one if the second does or 0 if it doesn't matter.
+// The sort is stable so elements that compare equal keep their order.
+func sortArray(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. Expected 1 or 2. Got %d.", len(args))
+	}
+	arr, err := arrayArgument("sort", args, 0)
+	if err != nil {
+		return err
+	}
+	var comparator object.Object
+	if len(args) == 2 {
+		if comparator, err = functionArgument("sort", args, 1); err != nil {
+			return err
+		}
+	}
+
+	sorted := append([]object.Object{}, arr.Elements...)
+	// the first error stops the comparisons from mattering
+	var sortErr object.Object
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortErr != nil {
+			return false
+		}
+		if comparator == nil {
+			order, err := compareObjects(sorted[i], sorted[j])
+			if err != nil {
+				sortErr = err
+			}
+			return order < 0
+		}
+		result := callback(comparator, 2, sorted[i], sorted[j])
+		order, ok := result.(*object.Integer)
+		switch {
+		case isError(result):
+			sortErr = result
+		case !ok:
+			sortErr = newError("comparator passed to `sort` should return an integer. received %s", typeOf(result))
+		default:
+			return order.Value < 0
+		}
+		return false
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	return &object.Array{Elements: sorted}
+}
@@ -0,0 +1,115 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/object"
+)
+
+// Macro expansion happens between parsing and evaluation. DefineMacros
+// pulls every top level `let name = macro(...) {...};` out of the program
+// and into env, then ExpandMacros replaces calls to those macros with the
+// code they return.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	definitions := []int{}
+	for i, statement := range program.Statements {
+		if isMacroDefinition(statement) {
+			addMacro(statement, env)
+			definitions = append(definitions, i)
+		}
+	}
+	// Going backwards so that removing a statement doesn't shift
+	// the indices of the ones we still need to remove
+	for i := len(definitions) - 1; i >= 0; i-- {
+		index := definitions[i]
+		program.Statements = append(program.Statements[:index], program.Statements[index+1:]...)
+	}
+}
+
+func isMacroDefinition(node ast.Statement) bool {
+	letStatement, ok := node.(*ast.LetStatement)
+	if !ok || letStatement.Name == nil {
+		return false
+	}
+	_, ok = letStatement.Value.(*ast.MacroLiteral)
+	return ok
+}
+
+func addMacro(statement ast.Statement, env *object.Environment) {
+	letStatement := statement.(*ast.LetStatement)
+	macroLiteral := letStatement.Value.(*ast.MacroLiteral)
+	macro := &object.Macro{
+		Parameters: macroLiteral.Parameters,
+		Env:        env,
+		Body:       macroLiteral.Body,
+	}
+	env.Set(letStatement.Name.Value, macro)
+}
+
+// Expansion stops replacing calls after the first error, which is returned
+// along with the partially expanded program.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var expansionErr error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if expansionErr != nil {
+			return node
+		}
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+		if len(call.Arguments) != len(macro.Parameters) {
+			expansionErr = fmt.Errorf("macro %s was called with an incorrect number of arguments: expected %d, got %d", call.Function.String(), len(macro.Parameters), len(call.Arguments))
+			return node
+		}
+
+		args := quoteArgs(call)
+		evalEnv := extendMacroEnv(macro, args)
+		evaluated := Eval(macro.Body, evalEnv)
+		if isError(evaluated) {
+			expansionErr = fmt.Errorf("error expanding macro %s: %s", call.Function.String(), evaluated.(*object.Error).Message)
+			return node
+		}
+
+		quote, ok := unwrapReturnValue(evaluated).(*object.Quote)
+		if !ok {
+			expansionErr = fmt.Errorf("macro %s must return quoted code. received %s", call.Function.String(), typeOf(evaluated))
+			return node
+		}
+		return quote.Node
+	})
+	return expanded, expansionErr
+}
+
+func isMacroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	identifier, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+	obj, ok := env.Get(identifier.Value)
+	if !ok {
+		return nil, false
+	}
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func quoteArgs(call *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+	for _, arg := range call.Arguments {
+		args = append(args, &object.Quote{Node: arg})
+	}
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		extended.Set(param.Value, args[i])
+	}
+	return extended
+}
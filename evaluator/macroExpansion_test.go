@@ -0,0 +1,192 @@
+package evaluator
+
+import (
+	"monkey-pl/object"
+	"monkey-pl/parser/parsertest"
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+	for _, tc := range tests {
+		testQuoteObject(t, testEval(tc.input), tc.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quoted = quote(4 + 4); quote(unquote(4 + 4) + unquote(quoted))`, `(8 + (4 + 4))`},
+		{`quote(unquote("a" + "b"))`, `ab`},
+		{`quote(unquote([1, 2]))`, `[1, 2]`},
+	}
+	for _, tc := range tests {
+		testQuoteObject(t, testEval(tc.input), tc.expected)
+	}
+}
+
+func TestQuoteDoesNotChangeFunctionBody(t *testing.T) {
+	input := `let f = fn(x) { quote(unquote(x) + 1) }; [f(1), f(2)]`
+	evaluated := testEval(input)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("Expected *object.Array. Got %T (%+v)", evaluated, evaluated)
+	}
+	if len(array.Elements) != 2 {
+		t.Fatalf("Expected 2 elements. Got %d", len(array.Elements))
+	}
+	testQuoteObject(t, array.Elements[0], `(1 + 1)`)
+	testQuoteObject(t, array.Elements[1], `(2 + 1)`)
+}
+
+func testQuoteObject(t *testing.T, evaluated object.Object, expected string) {
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		t.Errorf("Expected *object.Quote. Got %T (%+v)", evaluated, evaluated)
+		return
+	}
+	if quote.Node == nil {
+		t.Errorf("quote.Node is nil")
+		return
+	}
+	if quote.Node.String() != expected {
+		t.Errorf("Expected quoted node to be %q. Got %q", expected, quote.Node.String())
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+	env := object.NewEnvironment()
+	program := parsertest.Parse(t, input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected 2 statements to be left in the program. Got %d", len(program.Statements))
+	}
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("Expected object to be a Macro. Got %T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("Expected macro to have 2 parameters. Got %d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("Unexpected macro parameters %v", macro.Parameters)
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("Expected macro body to be %q. Got %q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, print("not greater"), print("greater"));`,
+			`if (!(10 > 5)) { print("not greater") } else { print("greater") }`,
+		},
+	}
+	for _, tc := range tests {
+		expected := parsertest.Parse(t, tc.expected)
+		program := parsertest.Parse(t, tc.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Errorf("unexpected error expanding macros: %s", err)
+			continue
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("Expected %q. Got %q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let m = macro(x) { quote(x) }; m(1, 2);`,
+			"macro m was called with an incorrect number of arguments: expected 1, got 2",
+		},
+		{
+			`let m = macro() { 5 }; m();`,
+			"macro m must return quoted code. received INTEGER",
+		},
+		{
+			`let m = macro() { nope }; m();`,
+			"error expanding macro m: identifier not found: nope",
+		},
+	}
+	for _, tc := range tests {
+		program := parsertest.Parse(t, tc.input)
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Errorf("Expected an error expanding %q", tc.input)
+			continue
+		}
+		if err.Error() != tc.expected {
+			t.Errorf("Expected error %q. Got %q", tc.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/object"
+	"monkey-pl/token"
+)
+
+// `quote` isn't a builtin because its argument can't be evaluated before
+// it's called. It's handled as a special case in evalCallExpression.
+func quote(node ast.Node, env *object.Environment) object.Object {
+	// The quoted node belongs to the function body it was written in, so
+	// we have to copy it before filling in unquotes. Otherwise calling the
+	// same function twice would give back whatever the first call put there.
+	node = evalUnquoteCalls(ast.Copy(node), env)
+	return &object.Quote{Node: node}
+}
+
+// Replaces `unquote(x)` calls inside a quote with the AST for the value of x
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
+	return ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if !isUnquoteCall(node) {
+			return node
+		}
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			return node
+		}
+		unquoted := Eval(call.Arguments[0], env)
+		converted := convertObjectToASTNode(unquoted)
+		// Anything we can't turn back into code is left as an unquote
+		// call. It fails with "identifier not found" if it is evaluated.
+		if converted == nil {
+			return node
+		}
+		return converted
+	})
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+	return call.Function.TokenLiteral() == "unquote"
+}
+
+func isQuoteCall(node *ast.CallExpression) bool {
+	return node.Function.TokenLiteral() == "quote"
+}
+
+func convertObjectToASTNode(obj object.Object) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprintf("%d", obj.Value)}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.BooleanLiteral{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Array:
+		elements := []ast.Expression{}
+		for _, el := range obj.Elements {
+			element, ok := convertObjectToASTNode(el).(ast.Expression)
+			if !ok {
+				return nil
+			}
+			elements = append(elements, element)
+		}
+		return &ast.ArrayLiteral{Token: token.Token{Type: token.LBRACKET, Literal: "["}, Elements: elements}
+	case *object.Quote:
+		return obj.Node
+	default:
+		return nil
+	}
+}
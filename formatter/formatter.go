@@ -0,0 +1,583 @@
+// Package formatter prints Monkey programs in a single canonical style,
+// in the spirit of gofmt. Unlike the String() methods on AST nodes (which
+// are meant for debugging) the output is meant to be read and committed,
+// so comments are kept and parentheses are only used where they're needed.
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/parser"
+	"monkey-pl/token"
+	"strconv"
+	"strings"
+)
+
+const (
+	indentWidth = 2
+	// Lists (arrays, hashes, arguments and parameters) that would go past
+	// this column are broken up with one element per line
+	maxWidth = 80
+)
+
+// Formats Monkey source code. Formatting is idempotent: formatting the
+// output again gives back the same output. Source with syntax errors is
+// rejected rather than formatted since we can't know what it means.
+func Format(source string) (string, error) {
+	lex := lexer.New(source)
+	pars := parser.New(lex)
+	program := pars.ParseProgram()
+	if len(pars.Errors()) != 0 {
+		return "", fmt.Errorf("could not parse source:\n\t%s", strings.Join(pars.Errors(), "\n\t"))
+	}
+
+	p := &printer{
+		source:   source,
+		comments: lex.Comments(),
+		brackets: matchBrackets(source),
+	}
+	var out bytes.Buffer
+	p.statements(&out, program.Statements, 0, len(source)+1)
+	return out.String(), nil
+}
+
+// The AST doesn't keep track of where blocks and lists end, but we need to
+// know that so comments at the end of a block or list stay inside of it.
+// This maps the offset of every `{`, `[` and `(` to the offset of the
+// bracket that closes it.
+func matchBrackets(source string) map[int]int {
+	brackets := make(map[int]int)
+	open := []int{}
+	lex := lexer.New(source)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LBRACE, token.LBRACKET, token.LPAREN:
+			open = append(open, tok.Offset)
+		case token.RBRACE, token.RBRACKET, token.RPAREN:
+			if len(open) > 0 {
+				brackets[open[len(open)-1]] = tok.Offset
+				open = open[:len(open)-1]
+			}
+		}
+	}
+	return brackets
+}
+
+type printer struct {
+	source   string
+	comments []lexer.Comment
+	// index of the next comment that hasn't been printed yet
+	next     int
+	brackets map[int]int
+	// a named function literal that starts an expression statement. It has
+	// to keep its parentheses or it would be parsed as a function statement.
+	leadingFunction *ast.FunctionLiteral
+}
+
+// Prints a list of statements, one per line, followed by a newline. Any
+// comments before end that haven't been printed yet are printed too.
+func (p *printer) statements(out *bytes.Buffer, statements []ast.Statement, indent int, end int) {
+	first := true
+	for i, statement := range statements {
+		start := statementStart(statement)
+		p.commentsBefore(out, start, indent, &first)
+		if !first && p.blankLineBefore(start) {
+			out.WriteString("\n")
+		}
+		var next ast.Statement
+		if i+1 < len(statements) {
+			next = statements[i+1]
+		}
+		out.WriteString(pad(indent))
+		out.WriteString(p.statement(statement, next, indent))
+		out.WriteString("\n")
+		first = false
+	}
+	p.commentsBefore(out, end, indent, &first)
+}
+
+// Comments that had code before them on their line are put back at the
+// end of the last line that was printed. Everything else gets its own line.
+func (p *printer) commentsBefore(out *bytes.Buffer, offset int, indent int, first *bool) {
+	for p.next < len(p.comments) && p.comments[p.next].Offset < offset {
+		comment := p.comments[p.next]
+		p.next++
+		if !comment.OwnLine && out.Len() > 0 {
+			out.Truncate(out.Len() - 1)
+			out.WriteString(" " + comment.Text + "\n")
+			continue
+		}
+		if !*first && p.blankLineBefore(comment.Offset) {
+			out.WriteString("\n")
+		}
+		out.WriteString(pad(indent) + comment.Text + "\n")
+		*first = false
+	}
+}
+
+// Blank lines between statements are kept (but runs of them are
+// collapsed into one), so we look back from offset to see if there
+// was an empty line right before it.
+func (p *printer) blankLineBefore(offset int) bool {
+	newlines := 0
+	for i := offset - 1; i >= 0; i-- {
+		switch p.source[i] {
+		case '\n':
+			newlines++
+		case ' ', '\t', '\r':
+		default:
+			return newlines >= 2
+		}
+	}
+	return false
+}
+
+func statementStart(statement ast.Statement) int {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token.Offset
+	case *ast.ReturnStatement:
+		return statement.Token.Offset
+	case *ast.FunctionStatement:
+		return statement.Token.Offset
+	case *ast.ExpressionStatement:
+		return statement.Token.Offset
+	case *ast.BlockStatement:
+		return statement.Token.Offset
+	default:
+		return 0
+	}
+}
+
+func (p *printer) statement(statement ast.Statement, next ast.Statement, indent int) string {
+	col := indent * indentWidth
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		var target string
+		if statement.Pattern != nil {
+			target = p.pattern(statement.Pattern, indent, col+len("let "))
+		} else {
+			target = statement.Name.String()
+		}
+		prefix := "let " + target + " = "
+		return prefix + p.expression(statement.Value, indent, col+len(lastLine(prefix))) + ";"
+	case *ast.ReturnStatement:
+		if statement.ReturnValue == nil {
+			return "return;"
+		}
+		return "return " + p.expression(statement.ReturnValue, indent, col+len("return ")) + ";"
+	case *ast.FunctionStatement:
+		return p.function(statement.Function, indent, col)
+	case *ast.ExpressionStatement:
+		p.leadingFunction = leadingNamedFunction(statement.Expression)
+		expression := p.expression(statement.Expression, indent, col)
+		if needsSemicolon(statement, next) {
+			expression += ";"
+		}
+		return expression
+	case *ast.BlockStatement:
+		return p.block(statement, indent)
+	default:
+		return statement.String()
+	}
+}
+
+// Finds the named function literal an expression starts with, if there is
+// one. e.g. `(fn fact(n) { ... })(5)` starts with `fn fact`.
+func leadingNamedFunction(expression ast.Expression) *ast.FunctionLiteral {
+	for {
+		switch node := expression.(type) {
+		case *ast.InfixExpression:
+			expression = node.Left
+		case *ast.CallExpression:
+			expression = node.Function
+		case *ast.IndexExpression:
+			expression = node.Left
+		case *ast.FunctionLiteral:
+			if node.Name == "" {
+				return nil
+			}
+			return node
+		default:
+			return nil
+		}
+	}
+}
+
+// Statements ending in a block (ifs and whiles) don't get a semicolon,
+// unless the next statement could be read as continuing the expression
+// (e.g. `if (x) { 1 }` followed by `-1` would parse as a subtraction).
+func needsSemicolon(statement *ast.ExpressionStatement, next ast.Statement) bool {
+	switch statement.Expression.(type) {
+	case *ast.IfExpression, *ast.WhileExpression:
+	default:
+		return true
+	}
+	nextExpression, ok := next.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	switch nextExpression.Token.Type {
+	case token.MINUS, token.LPAREN, token.LBRACKET:
+		return true
+	default:
+		return false
+	}
+}
+
+// Blocks always span multiple lines unless they are completely empty
+func (p *printer) block(block *ast.BlockStatement, indent int) string {
+	end, ok := p.brackets[block.Token.Offset]
+	if !ok {
+		end = block.Token.Offset
+	}
+	if len(block.Statements) == 0 && !p.hasCommentsBefore(end) {
+		return "{}"
+	}
+	var out bytes.Buffer
+	out.WriteString("{\n")
+	p.statements(&out, block.Statements, indent+1, end)
+	out.WriteString(pad(indent) + "}")
+	return out.String()
+}
+
+func (p *printer) hasCommentsBefore(offset int) bool {
+	return p.next < len(p.comments) && p.comments[p.next].Offset < offset
+}
+
+// Expressions are printed starting at column col. Anything that spans
+// multiple lines is indented relative to indent.
+func (p *printer) expression(expression ast.Expression, indent int, col int) string {
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		return expression.Value
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(expression.Value, 10)
+	case *ast.BooleanLiteral:
+		return strconv.FormatBool(expression.Value)
+	case *ast.StringLiteral:
+		return `"` + expression.Value + `"`
+	case *ast.PrefixExpression:
+		right := p.operand(expression.Right, parser.PREFIX, indent, col+len(expression.Operator))
+		return expression.Operator + right
+	case *ast.InfixExpression:
+		precedence := parser.Precedence(expression.Token.Type)
+		left := p.operand(expression.Left, precedence, indent, col)
+		operator := " " + expression.Operator + " "
+		// The parser is left associative so an operand on the right with
+		// the same precedence needs parentheses: `a - (b - c)`
+		right := p.operand(expression.Right, precedence+1, indent, col+len(lastLine(left))+len(operator))
+		return left + operator + right
+	case *ast.CallExpression:
+		function := p.operand(expression.Function, parser.CALL, indent, col)
+		items := []listItem{}
+		for _, arg := range expression.Arguments {
+			items = append(items, p.expressionItem(arg))
+		}
+		end := p.closing(expression.Token.Offset)
+		return function + p.list("(", ")", items, end, indent, col+len(lastLine(function)))
+	case *ast.IndexExpression:
+		left := p.operand(expression.Left, parser.CALL, indent, col)
+		index := p.expression(expression.Index, indent, col+len(lastLine(left))+1)
+		return left + "[" + index + "]"
+	case *ast.ArrayLiteral:
+		items := []listItem{}
+		for _, element := range expression.Elements {
+			items = append(items, p.expressionItem(element))
+		}
+		return p.list("[", "]", items, p.closing(expression.Token.Offset), indent, col)
+	case *ast.HashLiteral:
+		items := []listItem{}
+		for _, key := range expression.OrderedKeys() {
+			key, value := key, expression.Pairs[key]
+			items = append(items, listItem{startOffset(key), func(indent int, col int) string {
+				k := p.expression(key, indent, col) + ": "
+				return k + p.expression(value, indent, col+len(lastLine(k)))
+			}})
+		}
+		return p.list("{", "}", items, p.closing(expression.Token.Offset), indent, col)
+	case *ast.SpreadElement:
+		return "..." + p.operand(expression.Value, parser.PREFIX, indent, col+3)
+	case *ast.FunctionLiteral:
+		if expression == p.leadingFunction {
+			return "(" + p.function(expression, indent, col+1) + ")"
+		}
+		return p.function(expression, indent, col)
+	case *ast.MacroLiteral:
+		params := []ast.Pattern{}
+		for _, param := range expression.Parameters {
+			params = append(params, param)
+		}
+		return p.callable(expression.Token, "macro", params, nil, expression.Body, indent, col)
+	case *ast.IfExpression:
+		condition := p.expression(expression.Condition, indent, col+len("if ("))
+		out := "if (" + condition + ") " + p.block(expression.Consequence, indent)
+		if expression.Alternative != nil {
+			out += " else " + p.block(expression.Alternative, indent)
+		}
+		return out
+	case *ast.WhileExpression:
+		condition := p.expression(expression.Condition, indent, col+len("while ("))
+		return "while (" + condition + ") " + p.block(expression.Body, indent)
+	case ast.Pattern:
+		return p.pattern(expression, indent, col)
+	default:
+		return expression.String()
+	}
+}
+
+func (p *printer) expressionItem(expression ast.Expression) listItem {
+	return listItem{startOffset(expression), func(indent int, col int) string {
+		return p.expression(expression, indent, col)
+	}}
+}
+
+// Prints an operand of an operator that binds with the given precedence,
+// adding parentheses if the operand binds more loosely than that.
+func (p *printer) operand(expression ast.Expression, precedence int, indent int, col int) string {
+	if expressionPrecedence(expression) < precedence {
+		return "(" + p.expression(expression, indent, col+1) + ")"
+	}
+	return p.expression(expression, indent, col)
+}
+
+func expressionPrecedence(expression ast.Expression) int {
+	switch expression := expression.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(expression.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	default:
+		// Literals, identifiers, calls and index expressions
+		// never need to be wrapped in parentheses
+		return parser.INDEX + 1
+	}
+}
+
+func (p *printer) function(function *ast.FunctionLiteral, indent int, col int) string {
+	keyword := "fn"
+	if function.Name != "" {
+		keyword += " " + function.Name
+	}
+	return p.callable(function.Token, keyword, function.Parameters, function.ReturnType, function.Body, indent, col)
+}
+
+// keywordToken is the `fn` or `macro` token. The parameter list is the
+// first `(` after it.
+func (p *printer) callable(keywordToken token.Token, keyword string, params []ast.Pattern, returnType ast.TypeExpr, body *ast.BlockStatement, indent int, col int) string {
+	items := []listItem{}
+	for _, param := range params {
+		param := param
+		items = append(items, listItem{startOffset(param), func(indent int, col int) string {
+			return p.pattern(param, indent, col)
+		}})
+	}
+	end := keywordToken.Offset
+	if paren := strings.IndexByte(p.source[keywordToken.Offset:], '('); paren >= 0 {
+		end = p.closing(keywordToken.Offset + paren)
+	}
+	header := keyword + p.list("(", ")", items, end, indent, col+len(keyword)) + " "
+	// type annotations are always printed on one line
+	if returnType != nil {
+		header += "-> " + returnType.String() + " "
+	}
+	return header + p.block(body, indent)
+}
+
+func (p *printer) pattern(pattern ast.Pattern, indent int, col int) string {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return pattern.String()
+	case *ast.AssignmentPattern:
+		target := p.pattern(pattern.Target, indent, col) + " = "
+		return target + p.expression(pattern.Default, indent, col+len(lastLine(target)))
+	case *ast.RestElement:
+		return "..." + pattern.Target.String()
+	case *ast.ArrayPattern:
+		items := []listItem{}
+		for _, element := range pattern.Elements {
+			element := element
+			items = append(items, listItem{startOffset(element), func(indent int, col int) string {
+				return p.pattern(element, indent, col)
+			}})
+		}
+		return p.list("[", "]", items, p.closing(pattern.Token.Offset), indent, col)
+	case *ast.HashPattern:
+		items := []listItem{}
+		for _, property := range pattern.Properties {
+			property := property
+			items = append(items, listItem{property.Token.Offset, func(indent int, col int) string {
+				return p.hashPatternProperty(property, indent, col)
+			}})
+		}
+		if pattern.Rest != nil {
+			rest := "..." + pattern.Rest.Target.String()
+			items = append(items, listItem{pattern.Rest.Token.Offset, func(int, int) string { return rest }})
+		}
+		return p.list("{", "}", items, p.closing(pattern.Token.Offset), indent, col)
+	default:
+		return pattern.String()
+	}
+}
+
+func (p *printer) hashPatternProperty(property *ast.HashPatternProperty, indent int, col int) string {
+	target := property.Value
+	if assignment, ok := target.(*ast.AssignmentPattern); ok {
+		target = assignment.Target
+	}
+	if ident, ok := target.(*ast.Identifier); ok && ident.Value == property.Key && ident.Type == nil && property.Token.Type == token.IDENT {
+		return p.pattern(property.Value, indent, col)
+	}
+	key := property.Key
+	if property.Token.Type == token.STRING {
+		key = `"` + key + `"`
+	}
+	key += ": "
+	return key + p.pattern(property.Value, indent, col+len(key))
+}
+
+// An item in a comma separated list. offset is where the item starts in
+// the source, which tells us which comments in the list come after it.
+type listItem struct {
+	offset int
+	print  func(indent int, col int) string
+}
+
+// Prints a comma separated list on one line if it fits. The last item is
+// allowed to span several lines so that things like `map(arr, fn(x) {`
+// stay on one line. Otherwise every item goes on its own line.
+//
+// end is the offset of the closing bracket. Comments inside of the list
+// can't go on one line with it so they force it to be broken up, and each
+// comment is printed after the item it follows.
+func (p *printer) list(open string, close string, items []listItem, end int, indent int, col int) string {
+	if len(items) == 0 && !p.hasCommentsBefore(end) {
+		return open + close
+	}
+
+	mark := p.next
+	parts := []string{}
+	itemCol := col + len(open)
+	for _, item := range items {
+		part := item.print(indent, itemCol)
+		parts = append(parts, part)
+		itemCol += len(lastLine(part)) + len(", ")
+	}
+	// Comments in nested lists have been printed by now, so any that are
+	// left before end belong to this list
+	if len(parts) != 0 && !p.hasCommentsBefore(end) {
+		flat := open + strings.Join(parts, ", ") + close
+		if fitsOnLine(flat, parts, col) {
+			return flat
+		}
+	}
+
+	// Printing the items may have printed comments so they need to
+	// be put back before we try again
+	p.next = mark
+	var out bytes.Buffer
+	out.WriteString(open)
+	for i, item := range items {
+		p.listComments(&out, item.offset, indent+1)
+		out.WriteString("\n" + pad(indent+1))
+		out.WriteString(item.print(indent+1, (indent+1)*indentWidth))
+		if i < len(items)-1 {
+			out.WriteString(",")
+		}
+	}
+	p.listComments(&out, end, indent+1)
+	out.WriteString("\n" + pad(indent) + close)
+	return out.String()
+}
+
+// Prints the comments before offset in a broken up list. Comments that
+// trailed code stay on the end of the current line.
+func (p *printer) listComments(out *bytes.Buffer, offset int, indent int) {
+	for p.hasCommentsBefore(offset) {
+		comment := p.comments[p.next]
+		p.next++
+		if comment.OwnLine {
+			out.WriteString("\n" + pad(indent) + comment.Text)
+		} else {
+			out.WriteString(" " + comment.Text)
+		}
+	}
+}
+
+// Returns the offset of the bracket closing the one at open
+func (p *printer) closing(open int) int {
+	if end, ok := p.brackets[open]; ok {
+		return end
+	}
+	return open
+}
+
+// Where a list item starts in the source. Most nodes start with their own
+// token but infix, call and index expressions start with their left side.
+func startOffset(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		return startOffset(node.Left)
+	case *ast.CallExpression:
+		return startOffset(node.Function)
+	case *ast.IndexExpression:
+		return startOffset(node.Left)
+	case *ast.AssignmentPattern:
+		return startOffset(node.Target)
+	case *ast.Identifier:
+		return node.Token.Offset
+	case *ast.IntegerLiteral:
+		return node.Token.Offset
+	case *ast.BooleanLiteral:
+		return node.Token.Offset
+	case *ast.StringLiteral:
+		return node.Token.Offset
+	case *ast.PrefixExpression:
+		return node.Token.Offset
+	case *ast.FunctionLiteral:
+		return node.Token.Offset
+	case *ast.MacroLiteral:
+		return node.Token.Offset
+	case *ast.ArrayLiteral:
+		return node.Token.Offset
+	case *ast.HashLiteral:
+		return node.Token.Offset
+	case *ast.IfExpression:
+		return node.Token.Offset
+	case *ast.WhileExpression:
+		return node.Token.Offset
+	case *ast.SpreadElement:
+		return node.Token.Offset
+	case *ast.RestElement:
+		return node.Token.Offset
+	case *ast.ArrayPattern:
+		return node.Token.Offset
+	case *ast.HashPattern:
+		return node.Token.Offset
+	default:
+		return 0
+	}
+}
+
+func fitsOnLine(flat string, parts []string, col int) bool {
+	for _, part := range parts[:len(parts)-1] {
+		if strings.Contains(part, "\n") {
+			return false
+		}
+	}
+	firstLine, _, _ := strings.Cut(flat, "\n")
+	return col+len(firstLine) <= maxWidth
+}
+
+func lastLine(s string) string {
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+func pad(indent int) string {
+	return strings.Repeat(" ", indent*indentWidth)
+}
@@ -0,0 +1,159 @@
+package formatter
+
+import (
+	"monkey-pl/evaluator"
+	"monkey-pl/object"
+	"monkey-pl/parser/parsertest"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let   x=5", "let x = 5;\n"},
+		{"1+2*3; (1+2)*3; 1-(2-3); (1-2)-3;", "1 + 2 * 3;\n(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(a+b); (-a)[0]; -a[0]; !(a==b); (fn(x){x})(1)", "-(a + b);\n(-a)[0];\n-a[0];\n!(a == b);\nfn(x) {\n  x;\n}(1);\n"},
+		{"let add = fn(a,b){a+b}", "let add = fn(a, b) {\n  a + b;\n};\n"},
+		{"fn add(a, b = 1, ...rest) { return a + b }", "fn add(a, b = 1, ...rest) {\n  return a + b;\n}\n"},
+		{"fn noop() {}", "fn noop() {}\n"},
+		{"(fn fact(n) { n * 2 })(5);", "(fn fact(n) {\n  n * 2;\n})(5);\n"},
+		{"if(x>1){x}else{y}", "if (x > 1) {\n  x;\n} else {\n  y;\n}\n"},
+		{"if (x) { 1 }\n-1", "if (x) {\n  1;\n} - 1;\n"},
+		{"while(x<3){ let x = x + 1; }", "while (x < 3) {\n  let x = x + 1;\n}\n"},
+		{"let [a,...b]=arr; let {a, \"b\": c = 2, d: e, ...r} = h;", "let [a, ...b] = arr;\nlet {a, \"b\": c = 2, d: e, ...r} = h;\n"},
+		{`{"b": 1, "a": [1,2]}`, "{\"b\": 1, \"a\": [1, 2]};\n"},
+		{"f(...args, [...a, 1])", "f(...args, [...a, 1]);\n"},
+		{"let m = macro(a, b) { quote(unquote(a)) }", "let m = macro(a, b) {\n  quote(unquote(a));\n};\n"},
+		{"let x:int=1; fn f(a:[int], ...b:[any])->{string:fn(int)->bool} { {} }", "let x: int = 1;\nfn f(a: [int], ...b: [any]) -> {string: fn(int) -> bool} {\n  {};\n}\n"},
+		{"let {a: a: int} = h;", "let {a: a: int} = h;\n"},
+		{"map([1, 2], fn(x) { x * 2 })", "map([1, 2], fn(x) {\n  x * 2;\n});\n"},
+		{
+			`let words = ["aaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbb", "cccccccccccccccccccc", "ddddddddd"];`,
+			"let words = [\n  \"aaaaaaaaaaaaaaaa\",\n  \"bbbbbbbbbbbbbbbbbbbbb\",\n  \"cccccccccccccccccccc\",\n  \"ddddddddd\"\n];\n",
+		},
+		// comments inside of lists stay after the item they follow
+		{"{\"a\": 1, # trailing a\n \"b\": 2}; # after", "{\n  \"a\": 1, # trailing a\n  \"b\": 2\n}; # after\n"},
+		{"[1, # one\n 2]", "[\n  1, # one\n  2\n];\n"},
+		{"[ # first\n 1,\n # before two\n 2 # last\n]", "[ # first\n  1,\n  # before two\n  2 # last\n];\n"},
+		{"f(a, [1, # one\n 2])", "f(a, [\n  1, # one\n  2\n]);\n"},
+		{"let f = fn(a, # first\n b) { a };", "let f = fn(\n  a, # first\n  b\n) {\n  a;\n};\n"},
+		// blank lines are kept, but collapsed into one
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"fn f() {\n\n  1;\n\n  2;\n}", "fn f() {\n  1;\n\n  2;\n}\n"},
+	}
+
+	for _, tt := range tests {
+		actual, err := Format(tt.input)
+		if err != nil {
+			t.Errorf("Format(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if actual != tt.expected {
+			t.Errorf("Format(%q) wrong.\nExpected:\n%s\nGot:\n%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `# header
+let x = 5;   # five
+
+
+let f = fn(a) { # opening
+  # inside
+  a
+  # before the end
+}
+if (x) {
+  # only a comment
+}
+# at the end`
+
+	expected := `# header
+let x = 5; # five
+
+let f = fn(a) { # opening
+  # inside
+  a;
+  # before the end
+};
+if (x) {
+  # only a comment
+}
+# at the end
+`
+	actual, err := Format(input)
+	if err != nil {
+		t.Fatalf("Format returned error: %s", err)
+	}
+	if actual != expected {
+		t.Errorf("Format wrong.\nExpected:\n%s\nGot:\n%s", expected, actual)
+	}
+}
+
+func TestFormatIsIdempotent(t *testing.T) {
+	inputs := []string{
+		"let x=5; # five\n\n\nx",
+		"let f = fn(a, b) { if (a > b) { a } else { # comment\n b } }\n\n# done",
+		`let words = ["aaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbb", "cccccccccccccccccccc", fn(x) { x }, 1];`,
+		"let h = {\"a\": fn() { 1 }, \"b\": [1, 2, 3]}; h[\"a\"]()",
+		"if (x) { 1 };\n[1, 2]",
+		"while (x) { # loop\n\n  x }\n",
+		"{\"a\": 1, # trailing a\n \"b\": [2, # two\n 3]}; # after",
+	}
+
+	for _, input := range inputs {
+		once, err := Format(input)
+		if err != nil {
+			t.Fatalf("Format(%q) returned error: %s", input, err)
+		}
+		twice, err := Format(once)
+		if err != nil {
+			t.Fatalf("Format(%q) returned error: %s", once, err)
+		}
+		if once != twice {
+			t.Errorf("Format is not idempotent for %q.\nFirst:\n%s\nSecond:\n%s", input, once, twice)
+		}
+	}
+}
+
+// Formatting shouldn't change what a program does
+func TestFormatKeepsMeaning(t *testing.T) {
+	inputs := []string{
+		"(fn fact(n) { n * 2 })(5);",
+		"(fn f(x) { [x] })(1)[0] + 1",
+		"let f = fn g(n) { if (n < 1) { 0 } else { n + g(n - 1) } }; f(3)",
+		"1 - (2 - 3) * -(4 + 5)",
+		"let a = [1]; if (true) { 1 }; -a[0]",
+	}
+
+	for _, input := range inputs {
+		formatted, err := Format(input)
+		if err != nil {
+			t.Fatalf("Format(%q) returned error: %s", input, err)
+		}
+		expected := eval(t, input)
+		actual := eval(t, formatted)
+		if actual != expected {
+			t.Errorf("Formatting %q changed its result.\nFormatted:\n%s\nExpected %s. Got %s", input, formatted, expected, actual)
+		}
+	}
+}
+
+func eval(t *testing.T, input string) string {
+	program := parsertest.Parse(t, input)
+	return evaluator.Eval(program, object.NewEnvironment()).Inspect()
+}
+
+func TestFormatParseErrors(t *testing.T) {
+	_, err := Format("let = 5;")
+	if err == nil {
+		t.Fatalf("Expected an error for invalid source")
+	}
+	if !strings.Contains(err.Error(), "could not parse source") {
+		t.Errorf("Unexpected error message: %s", err)
+	}
+}
@@ -0,0 +1,194 @@
+// Package highlight splits Monkey source into classified spans for syntax
+// highlighting and renders them as ANSI colored text or HTML.
+//
+// Highlighting only looks at tokens. It doesn't parse, so it works on
+// incomplete code (like a line being typed into the repl) and it can't
+// tell when a builtin has been shadowed.
+package highlight
+
+import (
+	"html"
+	"monkey-pl/evaluator"
+	"monkey-pl/lexer"
+	"monkey-pl/token"
+	"sort"
+	"strings"
+)
+
+type Kind string
+
+const (
+	Keyword     Kind = "keyword"
+	Builtin     Kind = "builtin"
+	Identifier  Kind = "identifier"
+	Number      Kind = "number"
+	String      Kind = "string"
+	Comment     Kind = "comment"
+	Operator    Kind = "operator"
+	Punctuation Kind = "punctuation"
+	// Characters the lexer doesn't understand (like `@`)
+	Invalid Kind = "invalid"
+)
+
+// A piece of source code. Lines and columns start at 1 and Offset is the
+// byte offset from the start of the source, just like token positions.
+// Whitespace between spans isn't included.
+type Span struct {
+	Kind   Kind   `json:"kind"`
+	Text   string `json:"text"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// quote and unquote aren't in the builtins table but they act like builtins
+var builtinNames = map[string]bool{"quote": true, "unquote": true}
+
+func init() {
+	for _, name := range evaluator.BuiltinNames() {
+		builtinNames[name] = true
+	}
+}
+
+// Splits source into spans in the order they appear
+func Tokenize(source string) []Span {
+	lex := lexer.New(source)
+	spans := []Span{}
+	for {
+		tok := lex.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		kind := classify(tok)
+		// The lexer works on bytes so characters outside of ASCII become
+		// one ILLEGAL token per byte. Those are joined back together.
+		if last := len(spans) - 1; kind == Invalid && last >= 0 && spans[last].Kind == Invalid &&
+			spans[last].Offset+len(spans[last].Text) == tok.Offset {
+			spans[last].Text += source[tok.Offset:tokenEnd(source, tok)]
+			continue
+		}
+		spans = append(spans, Span{
+			Kind:   kind,
+			Text:   source[tok.Offset:tokenEnd(source, tok)],
+			Line:   tok.Line,
+			Column: tok.Column,
+			Offset: tok.Offset,
+		})
+	}
+	// comments are skipped by the lexer, so they're merged in afterwards
+	for _, comment := range lex.Comments() {
+		spans = append(spans, Span{
+			Kind:   Comment,
+			Text:   comment.Text,
+			Line:   comment.Line,
+			Column: comment.Column,
+			Offset: comment.Offset,
+		})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Offset < spans[j].Offset })
+	return spans
+}
+
+func classify(tok token.Token) Kind {
+	switch tok.Type {
+	case token.FUNCTION, token.LET, token.TRUE, token.FALSE, token.IF,
+		token.ELSE, token.WHILE, token.RETURN, token.MACRO:
+		return Keyword
+	case token.IDENT:
+		if builtinNames[tok.Literal] {
+			return Builtin
+		}
+		return Identifier
+	case token.INT:
+		return Number
+	case token.STRING:
+		return String
+	case token.COMMA, token.SEMICOLON, token.COLON, token.LPAREN, token.RPAREN,
+		token.LBRACE, token.RBRACE, token.LBRACKET, token.RBRACKET:
+		return Punctuation
+	case token.ILLEGAL:
+		if isUnterminatedString(tok) {
+			return String
+		}
+		return Invalid
+	default:
+		return Operator
+	}
+}
+
+// String tokens don't include their quotes. Other ILLEGAL tokens are
+// always one byte (their literal is that byte converted to a rune).
+func tokenEnd(source string, tok token.Token) int {
+	switch {
+	case tok.Type == token.STRING:
+		return tok.Offset + len(tok.Literal) + 2
+	case tok.Type == token.ILLEGAL && !isUnterminatedString(tok):
+		return tok.Offset + 1
+	default:
+		return tok.Offset + len(tok.Literal)
+	}
+}
+
+// The lexer gives back unterminated strings as ILLEGAL tokens that
+// include the opening quote
+func isUnterminatedString(tok token.Token) bool {
+	return tok.Type == token.ILLEGAL && strings.HasPrefix(tok.Literal, `"`)
+}
+
+// Calls write for each span and for the text between spans (with a
+// nil span) so that the output contains all of source
+func render(source string, write func(span *Span, text string)) {
+	position := 0
+	for _, span := range Tokenize(source) {
+		if span.Offset > position {
+			write(nil, source[position:span.Offset])
+		}
+		span := span
+		write(&span, span.Text)
+		position = span.Offset + len(span.Text)
+	}
+	if position < len(source) {
+		write(nil, source[position:])
+	}
+}
+
+var ansiColors = map[Kind]string{
+	Keyword:  "\033[35m", // magenta
+	Builtin:  "\033[36m", // cyan
+	Number:   "\033[34m", // blue
+	String:   "\033[32m", // green
+	Comment:  "\033[90m", // gray
+	Operator: "\033[1m",  // bold
+	Invalid:  "\033[31m", // red
+}
+
+const ansiReset = "\033[0m"
+
+// Returns source with ANSI color codes around each span.
+// Identifiers and punctuation are left in the default color.
+func ANSI(source string) string {
+	out := &strings.Builder{}
+	render(source, func(span *Span, text string) {
+		if span == nil || ansiColors[span.Kind] == "" {
+			out.WriteString(text)
+			return
+		}
+		out.WriteString(ansiColors[span.Kind] + text + ansiReset)
+	})
+	return out.String()
+}
+
+// Returns source as HTML with each span wrapped in
+// `<span class="mk-<kind>">`. Everything is escaped so the result can be
+// put straight inside of a `<pre>` element.
+func HTML(source string) string {
+	out := &strings.Builder{}
+	render(source, func(span *Span, text string) {
+		if span == nil {
+			out.WriteString(html.EscapeString(text))
+			return
+		}
+		out.WriteString(`<span class="mk-` + string(span.Kind) + `">` + html.EscapeString(text) + "</span>")
+	})
+	return out.String()
+}
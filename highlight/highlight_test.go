@@ -0,0 +1,84 @@
+package highlight
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	input := "let add = fn(a, b) { a + b }; # adds\nlen(\"hi\") != 2;\n@é"
+	expected := []Span{
+		{Keyword, "let", 1, 1, 0},
+		{Identifier, "add", 1, 5, 4},
+		{Operator, "=", 1, 9, 8},
+		{Keyword, "fn", 1, 11, 10},
+		{Punctuation, "(", 1, 13, 12},
+		{Identifier, "a", 1, 14, 13},
+		{Punctuation, ",", 1, 15, 14},
+		{Identifier, "b", 1, 17, 16},
+		{Punctuation, ")", 1, 18, 17},
+		{Punctuation, "{", 1, 20, 19},
+		{Identifier, "a", 1, 22, 21},
+		{Operator, "+", 1, 24, 23},
+		{Identifier, "b", 1, 26, 25},
+		{Punctuation, "}", 1, 28, 27},
+		{Punctuation, ";", 1, 29, 28},
+		{Comment, "# adds", 1, 31, 30},
+		{Builtin, "len", 2, 1, 37},
+		{Punctuation, "(", 2, 4, 40},
+		{String, `"hi"`, 2, 5, 41},
+		{Punctuation, ")", 2, 9, 45},
+		{Operator, "!=", 2, 11, 47},
+		{Number, "2", 2, 14, 50},
+		{Punctuation, ";", 2, 15, 51},
+		{Invalid, "@é", 3, 1, 53},
+	}
+	spans := Tokenize(input)
+	if !reflect.DeepEqual(spans, expected) {
+		t.Errorf("Wrong spans.\nExpected: %v\nGot:      %v", expected, spans)
+	}
+}
+
+func TestTokenizeIncompleteInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Span
+	}{
+		{`"unterminated`, []Span{{String, `"unterminated`, 1, 1, 0}}},
+		{"fn(x: int) -> ", []Span{
+			{Keyword, "fn", 1, 1, 0},
+			{Punctuation, "(", 1, 3, 2},
+			{Identifier, "x", 1, 4, 3},
+			{Punctuation, ":", 1, 5, 4},
+			{Identifier, "int", 1, 7, 6},
+			{Punctuation, ")", 1, 10, 9},
+			{Operator, "->", 1, 12, 11},
+		}},
+	}
+	for _, tt := range tests {
+		if spans := Tokenize(tt.input); !reflect.DeepEqual(spans, tt.expected) {
+			t.Errorf("Wrong spans for %q.\nExpected: %v\nGot:      %v", tt.input, tt.expected, spans)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	got := ANSI(`let s = "x"; # hi`)
+	expected := "\033[35mlet\033[0m s \033[1m=\033[0m \033[32m\"x\"\033[0m; \033[90m# hi\033[0m"
+	if got != expected {
+		t.Errorf("Wrong ANSI output.\nExpected: %q\nGot:      %q", expected, got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	got := HTML("if (a < 1) {\n  \"<b>\"\n}")
+	expected := `<span class="mk-keyword">if</span> <span class="mk-punctuation">(</span>` +
+		`<span class="mk-identifier">a</span> <span class="mk-operator">&lt;</span> ` +
+		`<span class="mk-number">1</span><span class="mk-punctuation">)</span> ` +
+		`<span class="mk-punctuation">{</span>` + "\n  " +
+		`<span class="mk-string">&#34;&lt;b&gt;&#34;</span>` + "\n" +
+		`<span class="mk-punctuation">}</span>`
+	if got != expected {
+		t.Errorf("Wrong HTML output.\nExpected: %q\nGot:      %q", expected, got)
+	}
+}
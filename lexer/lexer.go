@@ -3,6 +3,7 @@ package lexer
 import (
 	"errors"
 	"monkey-pl/token"
+	"strings"
 )
 
 /*
@@ -21,18 +22,59 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+	// line is the current line number and lineStart is the position
+	// of the first character on that line. Together they let us
+	// work out the line and column of each token.
+	line      int
+	lineStart int
+	comments  []Comment
+	// In trivia mode tokens hold on to the whitespace and
+	// comments around them (see NewWithTrivia)
+	trivia bool
+}
+
+// Comments don't make it into the token stream but tools like the
+// formatter need to put them back, so the lexer holds on to them.
+type Comment struct {
+	Text   string // includes the leading `#`
+	Line   int
+	Column int
+	Offset int
+	// OwnLine is true when there is nothing but whitespace before the
+	// comment on its line. Otherwise it trails some code.
+	OwnLine bool
 }
 
 func New(input string) *Lexer {
-	lex := &Lexer{input: input}
+	lex := &Lexer{input: input, line: 1}
 	// This accomplishes initializing the other vars
 	lex.readChar()
 	return lex
 }
 
-func (lex *Lexer) NextToken() token.Token {
-	var tok token.Token
+// Creates a lexer in trivia mode. Tokens from this lexer remember the
+// whitespace and comments around them so that a program can be printed
+// back exactly as it was written. This is opt in because most callers
+// (like the evaluator) don't care and it costs a bit of extra work.
+func NewWithTrivia(input string) *Lexer {
+	lex := New(input)
+	lex.trivia = true
+	return lex
+}
+
+// Reports whether the lexer was created with NewWithTrivia
+func (lex *Lexer) KeepsTrivia() bool {
+	return lex.trivia
+}
+
+// Returns the comments the lexer has skipped over so far
+func (lex *Lexer) Comments() []Comment {
+	return lex.comments
+}
 
+func (lex *Lexer) NextToken() token.Token {
+	// position can run one past the end of the input once we hit EOF
+	triviaStart := min(lex.position, len(lex.input))
 	lex.skipWhitespace()
 
 	// Need to skip comments outside of the switch statement so
@@ -43,6 +85,36 @@ func (lex *Lexer) NextToken() token.Token {
 		lex.skipWhitespace()
 	}
 
+	line, column, offset := lex.line, lex.position-lex.lineStart+1, lex.position
+	tok := lex.readToken()
+	tok.Line, tok.Column, tok.Offset = line, column, offset
+	if lex.trivia {
+		tok.LeadingTrivia = lex.input[triviaStart:min(offset, len(lex.input))]
+		if tok.Type != token.EOF {
+			tok.TrailingTrivia = lex.readTrailingTrivia()
+		}
+	}
+	return tok
+}
+
+// Trailing trivia stops at the end of the line. The newline and anything
+// after it belong to the next token's leading trivia.
+func (lex *Lexer) readTrailingTrivia() string {
+	// an unterminated string reads past the end of the input, so position
+	// can be more than one past the end here
+	start := min(lex.position, len(lex.input))
+	for lex.ch == ' ' || lex.ch == '\t' {
+		lex.readChar()
+	}
+	if lex.ch == '#' {
+		lex.skipComment()
+	}
+	return lex.input[start:min(lex.position, len(lex.input))]
+}
+
+func (lex *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch lex.ch {
 	case '=':
 		tok = newToken(token.ASSIGN, lex.ch)
@@ -55,6 +127,11 @@ func (lex *Lexer) NextToken() token.Token {
 		tok = newToken(token.PLUS, lex.ch)
 	case '-':
 		tok = newToken(token.MINUS, lex.ch)
+		if lex.peekChar() == '>' {
+			tok.Type = token.ARROW
+			tok.Literal = "->"
+			lex.readChar()
+		}
 	case '!':
 		tok = newToken(token.BANG, lex.ch)
 		if lex.peekChar() == '=' {
@@ -88,10 +165,25 @@ func (lex *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACKET, lex.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, lex.ch)
+	case '.':
+		// `.` isn't used on its own anywhere in the language (yet),
+		// so only `...` is a valid token
+		if lex.peekChar() == '.' && lex.peekCharAt(2) == '.' {
+			lex.readChar()
+			lex.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.ILLEGAL, lex.ch)
+		}
 	case '"':
+		start := lex.position
 		literal, err := lex.readString()
 		if err != nil {
-			tok = newToken(token.ILLEGAL, lex.ch)
+			// The literal is everything from the opening quote so
+			// that tools can tell this was an unterminated string
+			tok.Type = token.ILLEGAL
+			tok.Literal = lex.input[start:]
+			break
 		}
 		tok.Type = token.STRING
 		tok.Literal = literal
@@ -117,6 +209,10 @@ func (lex *Lexer) NextToken() token.Token {
 
 // Non-exported methods
 func (lex *Lexer) readChar() {
+	if lex.ch == '\n' {
+		lex.line++
+		lex.lineStart = lex.readPosition
+	}
 	if lex.readPosition >= len(lex.input) {
 		// ASCII code for "NUL"
 		lex.ch = 0
@@ -135,6 +231,15 @@ func (lex *Lexer) peekChar() byte {
 	}
 }
 
+// Like peekChar but looks `n` characters ahead instead of 1
+func (lex *Lexer) peekCharAt(n int) byte {
+	position := lex.position + n
+	if position >= len(lex.input) {
+		return 0
+	}
+	return lex.input[position]
+}
+
 func (lex *Lexer) readIdentifier() string {
 	startPosition := lex.position
 	for isAsciiLetter(lex.ch) {
@@ -168,9 +273,17 @@ func (lex *Lexer) readNumber() string {
 // Skips comments. All comments are single line, but the logic
 // for a multiline syntax would be almost identical
 func (lex *Lexer) skipComment() {
+	comment := Comment{
+		Line:    lex.line,
+		Column:  lex.position - lex.lineStart + 1,
+		Offset:  lex.position,
+		OwnLine: strings.TrimSpace(lex.input[lex.lineStart:lex.position]) == "",
+	}
 	for lex.ch != '\n' && lex.ch != 0 {
 		lex.readChar()
 	}
+	comment.Text = strings.TrimRight(lex.input[comment.Offset:lex.position], " \t\r")
+	lex.comments = append(lex.comments, comment)
 }
 
 func (lex *Lexer) skipWhitespace() {
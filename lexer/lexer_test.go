@@ -68,6 +68,9 @@ func TestNextToken(t *testing.T) {
 	[1, 2];
 	{"foo": "bar"}
 	while (x < 5) { x; }
+	fn(...rest) { [...rest] }
+	macro(x) { x }
+	fn(x: int) -> int
 	# Will a comment work at the end??
 	`
 	tests := []struct {
@@ -190,6 +193,35 @@ func TestNextToken(t *testing.T) {
 		{token.IDENT, "x"},
 		{token.SEMICOLON, ";"},
 		{token.RBRACE, "}"},
+		// fn(...rest) { [...rest] }
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.LBRACKET, "["},
+		{token.ELLIPSIS, "..."},
+		{token.IDENT, "rest"},
+		{token.RBRACKET, "]"},
+		{token.RBRACE, "}"},
+		// macro(x) { x }
+		{token.MACRO, "macro"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "x"},
+		{token.RBRACE, "}"},
+		// fn(x: int) -> int
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "x"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "->"},
+		{token.IDENT, "int"},
 		// EOF
 		{token.EOF, ""},
 	}
@@ -209,3 +241,116 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x + \"ab\";"
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+		expectedOffset int
+	}{
+		{token.LET, 1, 1, 0},
+		{token.IDENT, 1, 5, 4},
+		{token.ASSIGN, 1, 7, 6},
+		{token.INT, 1, 9, 8},
+		{token.SEMICOLON, 1, 10, 9},
+		{token.IDENT, 2, 3, 13},
+		{token.PLUS, 2, 5, 15},
+		{token.STRING, 2, 7, 17},
+		{token.SEMICOLON, 2, 11, 21},
+		{token.EOF, 2, 12, 22},
+	}
+
+	lex := New(input)
+	for i, tt := range tests {
+		tok := lex.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. Expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn || tok.Offset != tt.expectedOffset {
+			t.Errorf("tests[%d] - position wrong. Expected=%d:%d (%d), got=%d:%d (%d)", i,
+				tt.expectedLine, tt.expectedColumn, tt.expectedOffset, tok.Line, tok.Column, tok.Offset)
+		}
+	}
+}
+
+func TestUnterminatedString(t *testing.T) {
+	lex := New(`let s = "abc`)
+	for i := 0; i < 3; i++ {
+		lex.NextToken()
+	}
+	tok := lex.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != `"abc` || tok.Offset != 8 {
+		t.Errorf("Expected ILLEGAL token with the raw string at offset 8. Got %+v", tok)
+	}
+	if tok := lex.NextToken(); tok.Type != token.EOF {
+		t.Errorf("Expected EOF after unterminated string. Got %+v", tok)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "# header\nlet x = 5; # trailing   \n\t# indented\nx"
+	lex := New(input)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+	}
+
+	expected := []Comment{
+		{Text: "# header", Line: 1, Column: 1, Offset: 0, OwnLine: true},
+		{Text: "# trailing", Line: 2, Column: 12, Offset: 20, OwnLine: false},
+		{Text: "# indented", Line: 3, Column: 2, Offset: 35, OwnLine: true},
+	}
+	comments := lex.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("Expected %d comments. Got %d (%+v)", len(expected), len(comments), comments)
+	}
+	for i, comment := range comments {
+		if comment != expected[i] {
+			t.Errorf("comments[%d] - Expected %+v. Got %+v", i, expected[i], comment)
+		}
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	input := "# header\nlet x = 5; # five\n\n  x  "
+	tests := []struct {
+		expectedType     token.TokenType
+		expectedLeading  string
+		expectedTrailing string
+	}{
+		{token.LET, "# header\n", " "},
+		{token.IDENT, "", " "},
+		{token.ASSIGN, "", " "},
+		{token.INT, "", ""},
+		{token.SEMICOLON, "", " # five"},
+		{token.IDENT, "\n\n  ", "  "},
+		{token.EOF, "", ""},
+	}
+
+	lex := NewWithTrivia(input)
+	for i, tt := range tests {
+		tok := lex.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. Expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.LeadingTrivia != tt.expectedLeading {
+			t.Errorf("tests[%d] - leading trivia wrong. Expected=%q, got=%q", i, tt.expectedLeading, tok.LeadingTrivia)
+		}
+		if tok.TrailingTrivia != tt.expectedTrailing {
+			t.Errorf("tests[%d] - trailing trivia wrong. Expected=%q, got=%q", i, tt.expectedTrailing, tok.TrailingTrivia)
+		}
+	}
+
+	// An unterminated string runs to the end of the input
+	lex = NewWithTrivia(`let s = "abc`)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		if tok.Type == token.ILLEGAL && tok.TrailingTrivia != "" {
+			t.Errorf("Expected no trailing trivia after an unterminated string. Got %q", tok.TrailingTrivia)
+		}
+	}
+
+	plain := New(input).NextToken()
+	if plain.LeadingTrivia != "" || plain.TrailingTrivia != "" {
+		t.Errorf("Expected no trivia outside of trivia mode. Got %q and %q", plain.LeadingTrivia, plain.TrailingTrivia)
+	}
+}
@@ -0,0 +1,491 @@
+// Package lineedit is a small line editor for the repl, in the spirit of
+// linenoise. It supports moving the cursor, history that's saved between
+// sessions, reverse search (Ctrl-R), tab completion and highlighting the
+// line as it's typed.
+//
+// It only uses the standard library. Raw mode is set up with ioctl system
+// calls, so on platforms without them (like Windows) or when the input
+// isn't a terminal it falls back to reading plain lines.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// The terminal's file descriptor or -1 if we aren't reading from
+	// and writing to a terminal
+	fd int
+	// Columns in the terminal. Only used while editing.
+	width int
+
+	History *History
+	// Returns every word that can be completed. The editor picks the
+	// ones that start with the word before the cursor.
+	Complete func() []string
+	// Adds color to a line as it's typed. It must only add escape codes.
+	Highlight func(line string) string
+}
+
+func New(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{in: bufio.NewReader(in), out: out, fd: -1, width: 80, History: &History{}}
+	inFile, inOk := in.(*os.File)
+	outFile, outOk := out.(*os.File)
+	if inOk && outOk && isTerminal(int(inFile.Fd())) && isTerminal(int(outFile.Fd())) {
+		e.fd = int(inFile.Fd())
+	}
+	return e
+}
+
+// Reports whether the editor can do line editing. When it can't,
+// ReadLine just reads a line.
+func (e *Editor) IsTerminal() bool {
+	return e.fd != -1
+}
+
+// Shows prompt and reads a line. Returns io.EOF when there's no more input
+// (or Ctrl-D is pressed on an empty line) and ErrInterrupted for Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd == -1 {
+		return e.readPlainLine(prompt)
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore()
+	if width, err := terminalWidth(e.fd); err == nil && width > 0 {
+		e.width = width
+	}
+	return e.edit(prompt)
+}
+
+func (e *Editor) readPlainLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	line, err := e.in.ReadString('\n')
+	// the last line might not end with a newline
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Key codes. Ctrl-<letter> is the letter's position in the alphabet.
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	backspace = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	del       = 127
+)
+
+// Keys that arrive as escape sequences are given codes
+// outside of the range of real characters
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyWordLeft
+	keyWordRight
+	keyUnknown
+)
+
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+	// Which history entry is being shown. History.Len() means
+	// the line being typed, which is kept in saved.
+	historyIndex int
+	saved        []rune
+}
+
+func (e *Editor) edit(prompt string) (string, error) {
+	s := &lineState{prompt: prompt, historyIndex: e.History.Len()}
+	e.refresh(s)
+	// a key that ended a reverse search and still needs handling
+	var pending rune
+	for {
+		key := pending
+		pending = 0
+		if key == 0 {
+			var err error
+			if key, err = e.readKey(); err != nil {
+				return "", err
+			}
+		}
+
+		switch key {
+		case enter, '\n':
+			s.pos = len(s.buf)
+			e.refresh(s)
+			fmt.Fprint(e.out, "\n")
+			return string(s.buf), nil
+		case ctrlC:
+			fmt.Fprint(e.out, "^C\n")
+			return "", ErrInterrupted
+		case ctrlD:
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			s.deleteAt(s.pos)
+		case backspace, del:
+			if s.pos > 0 {
+				s.pos--
+				s.deleteAt(s.pos)
+			}
+		case keyDelete:
+			s.deleteAt(s.pos)
+		case ctrlA, keyHome:
+			s.pos = 0
+		case ctrlE, keyEnd:
+			s.pos = len(s.buf)
+		case ctrlB, keyLeft:
+			s.pos = max(s.pos-1, 0)
+		case ctrlF, keyRight:
+			s.pos = min(s.pos+1, len(s.buf))
+		case keyWordLeft:
+			s.pos = s.wordStart()
+		case keyWordRight:
+			for s.pos < len(s.buf) && !isWordRune(s.buf[s.pos]) {
+				s.pos++
+			}
+			for s.pos < len(s.buf) && isWordRune(s.buf[s.pos]) {
+				s.pos++
+			}
+		case ctrlK:
+			s.buf = s.buf[:s.pos]
+		case ctrlU:
+			s.buf = s.buf[s.pos:]
+			s.pos = 0
+		case ctrlW:
+			start := s.wordStart()
+			s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+			s.pos = start
+		case ctrlL:
+			fmt.Fprint(e.out, "\033[H\033[2J")
+		case ctrlP, keyUp:
+			e.showHistory(s, s.historyIndex-1)
+		case ctrlN, keyDown:
+			e.showHistory(s, s.historyIndex+1)
+		case ctrlR:
+			line, submitted, next := e.reverseSearch(s)
+			if submitted {
+				return line, nil
+			}
+			pending = next
+		case tab:
+			e.complete(s)
+		default:
+			if key >= ' ' {
+				s.insert(key)
+			}
+		}
+		e.refresh(s)
+	}
+}
+
+// Reads a key press, turning escape sequences for arrow keys and such
+// into the key codes above
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != escape {
+		return r, err
+	}
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch next {
+	case 'b':
+		return keyWordLeft, nil
+	case 'f':
+		return keyWordRight, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+
+	// CSI sequences are parameters (digits and semicolons)
+	// followed by a final byte that says what the key was
+	params := ""
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			return csiKey(params, b), nil
+		}
+		params += string(b)
+	}
+}
+
+func csiKey(params string, final byte) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		if strings.HasSuffix(params, ";5") {
+			return keyWordRight // Ctrl-Right
+		}
+		return keyRight
+	case 'D':
+		if strings.HasSuffix(params, ";5") {
+			return keyWordLeft // Ctrl-Left
+		}
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		}
+	}
+	return keyUnknown
+}
+
+func (s *lineState) insert(r rune) {
+	s.buf = append(s.buf, 0)
+	copy(s.buf[s.pos+1:], s.buf[s.pos:])
+	s.buf[s.pos] = r
+	s.pos++
+}
+
+func (s *lineState) insertString(str string) {
+	for _, r := range str {
+		s.insert(r)
+	}
+}
+
+func (s *lineState) deleteAt(i int) {
+	if i < len(s.buf) {
+		s.buf = append(s.buf[:i], s.buf[i+1:]...)
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Returns the start of the word before the cursor
+func (s *lineState) wordStart() int {
+	start := s.pos
+	for start > 0 && !isWordRune(s.buf[start-1]) {
+		start--
+	}
+	for start > 0 && isWordRune(s.buf[start-1]) {
+		start--
+	}
+	return start
+}
+
+// Redraws the line. Lines too long for the terminal scroll sideways
+// to keep the cursor in view rather than wrapping.
+func (e *Editor) refresh(s *lineState) {
+	promptWidth := stringWidth(s.prompt)
+	available := max(e.width-promptWidth-1, 1)
+
+	start := 0
+	for start < s.pos && stringWidth(string(s.buf[start:s.pos])) > available {
+		start++
+	}
+	end, width := start, 0
+	for end < len(s.buf) && width+runeWidth(s.buf[end]) <= available {
+		width += runeWidth(s.buf[end])
+		end++
+	}
+
+	visible := string(s.buf[start:end])
+	if e.Highlight != nil {
+		visible = e.Highlight(visible)
+	}
+	visible = showNewlines(visible)
+	out := &strings.Builder{}
+	fmt.Fprintf(out, "\r%s%s\033[K\r", s.prompt, visible)
+	// a count of 0 would move one column in most terminals
+	if column := promptWidth + stringWidth(string(s.buf[start:s.pos])); column > 0 {
+		fmt.Fprintf(out, "\033[%dC", column)
+	}
+	io.WriteString(e.out, out.String())
+}
+
+// Everything is drawn on one row, so a newline in a multi-line history
+// entry is shown as ↵ (which is also one column wide). The buffer keeps
+// the real newline so ReadLine hands back what was typed.
+func showNewlines(s string) string {
+	return strings.ReplaceAll(s, "\n", "↵")
+}
+
+func (e *Editor) showHistory(s *lineState, index int) {
+	if index < 0 || index > e.History.Len() {
+		return
+	}
+	if s.historyIndex == e.History.Len() {
+		s.saved = s.buf
+	}
+	s.historyIndex = index
+	if index == e.History.Len() {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(e.History.At(index))
+	}
+	s.pos = len(s.buf)
+}
+
+// Searches history for lines containing what's typed, newest first.
+// Pressing Ctrl-R again finds the next older match, Enter runs the match,
+// Ctrl-G or Ctrl-C puts back the original line and any other key stops
+// searching with the match as the line. That key is returned so it can be
+// handled as normal.
+func (e *Editor) reverseSearch(s *lineState) (line string, submitted bool, next rune) {
+	original := append([]rune{}, s.buf...)
+	query := []rune{}
+	match := e.History.Len()
+	failed := false
+	matchText := func() string {
+		if match < e.History.Len() {
+			return e.History.At(match)
+		}
+		return ""
+	}
+	search := func(before int) {
+		if found := e.History.search(string(query), before); found != -1 {
+			match, failed = found, false
+		} else {
+			failed = true
+		}
+	}
+
+	for {
+		label := "reverse-i-search"
+		if failed {
+			label = "failed " + label
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\033[K", label, string(query), showNewlines(matchText()))
+
+		key, err := e.readKey()
+		if err != nil {
+			return "", false, ctrlD
+		}
+		switch {
+		case key == ctrlR:
+			search(match)
+		case key == backspace || key == del:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				search(e.History.Len())
+			}
+		case key == ctrlG || key == ctrlC:
+			s.buf, s.pos = original, len(original)
+			return "", false, 0
+		case key == enter || key == '\n':
+			line := matchText()
+			s.buf, s.pos = []rune(line), len([]rune(line))
+			e.refresh(s)
+			fmt.Fprint(e.out, "\n")
+			return line, true, 0
+		case key >= ' ':
+			query = append(query, key)
+			search(match + 1)
+		default:
+			if match < e.History.Len() {
+				s.buf = []rune(e.History.At(match))
+				s.pos = len(s.buf)
+				s.historyIndex = match
+			}
+			return "", false, key
+		}
+	}
+}
+
+// Completes the word before the cursor. If there are several ways to
+// complete it, the part they have in common is filled in. When there's
+// nothing in common to add, the choices are listed below the line.
+func (e *Editor) complete(s *lineState) {
+	if e.Complete == nil {
+		return
+	}
+	start := s.pos
+	for start > 0 && isWordRune(s.buf[start-1]) {
+		start--
+	}
+	prefix := string(s.buf[start:s.pos])
+	if prefix == "" {
+		return
+	}
+
+	seen := map[string]bool{}
+	candidates := []string{}
+	for _, word := range e.Complete() {
+		if strings.HasPrefix(word, prefix) && !seen[word] {
+			seen[word] = true
+			candidates = append(candidates, word)
+		}
+	}
+	sort.Strings(candidates)
+
+	switch {
+	case len(candidates) == 0:
+		fmt.Fprint(e.out, "\a")
+	case len(candidates) == 1:
+		s.insertString(strings.TrimPrefix(candidates[0], prefix))
+	default:
+		common := commonPrefix(candidates)
+		if len(common) > len(prefix) {
+			s.insertString(strings.TrimPrefix(common, prefix))
+			return
+		}
+		fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+	}
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
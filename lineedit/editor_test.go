@@ -0,0 +1,211 @@
+package lineedit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Feeds keys straight to the editing loop since tests don't run in a terminal
+func editKeys(t *testing.T, e *Editor, keys string) (string, error) {
+	t.Helper()
+	e.in.Reset(strings.NewReader(keys))
+	return e.edit("> ")
+}
+
+func newTestEditor(history ...string) *Editor {
+	e := New(strings.NewReader(""), &bytes.Buffer{})
+	e.History = &History{entries: history}
+	return e
+}
+
+func TestEditing(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     string
+		expected string
+	}{
+		{"typing", "let x = 1;\r", "let x = 1;"},
+		{"backspace", "lett\x7f x\r", "let x"},
+		{"left and insert", "abc\x1b[D\x1b[DX\r", "aXbc"},
+		{"home and end", "bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		{"ctrl-a and ctrl-e", "bc\x01a\x05d\r", "abcd"},
+		{"delete key", "abc\x01\x1b[3~\r", "bc"},
+		{"ctrl-k", "abcdef\x1b[D\x1b[D\x1b[D\x0b\r", "abc"},
+		{"ctrl-u", "abcdef\x1b[D\x1b[D\x15\r", "ef"},
+		{"ctrl-w", "let foo bar\x17\r", "let foo "},
+		{"word left", "let foo bar\x1bbX\r", "let foo Xbar"},
+		{"ctrl-d deletes", "abc\x01\x04\r", "bc"},
+		{"unicode", "\"héllo\"\x1b[D\x7f\r", "\"héll\""},
+	}
+
+	for _, tt := range tests {
+		line, err := editKeys(t, newTestEditor(), tt.keys)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", tt.name, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%s: expected %q. got=%q", tt.name, tt.expected, line)
+		}
+	}
+}
+
+func TestEditingInterrupts(t *testing.T) {
+	if _, err := editKeys(t, newTestEditor(), "abc\x03"); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("expected ErrInterrupted for ctrl-c. got=%v", err)
+	}
+	if _, err := editKeys(t, newTestEditor(), "\x04"); err != io.EOF {
+		t.Errorf("expected io.EOF for ctrl-d on an empty line. got=%v", err)
+	}
+	if _, err := editKeys(t, newTestEditor(), "abc"); err != io.EOF {
+		t.Errorf("expected io.EOF when input runs out. got=%v", err)
+	}
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x1b[A\r", "let y = 2"},
+		{"\x1b[A\x1b[A\r", "puts(x)"},
+		{"\x1b[A\x1b[A\x1b[A\x1b[A\x1b[A\r", "let x = 1"},
+		{"\x10\x10\x0e\r", "let y = 2"},
+		// going back down restores what was being typed
+		{"new\x1b[A\x1b[A\x1b[B\x1b[B\r", "new"},
+		// history entries can be edited
+		{"\x1b[A\x7f3\r", "let y = 3"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor("let x = 1", "puts(x)", "let y = 2")
+		line, err := editKeys(t, e, tt.keys)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q. got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12let\r", "let y = 2"},
+		{"\x12let\x12\r", "let x = 1"},
+		{"\x12puts\r", "puts(x)"},
+		// backspace widens the search again
+		{"\x12lex\x7f\r", "let y = 2"},
+		// any other key stops searching and edits the match
+		{"\x12puts\x05;\r", "puts(x);"},
+		// ctrl-g puts back the original line
+		{"abc\x12puts\x07\r", "abc"},
+		// a failed search keeps the last match
+		{"\x12putsz\r", "puts(x)"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor("let x = 1", "puts(x)", "let y = 2")
+		line, err := editKeys(t, e, tt.keys)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q. got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestTabCompletion(t *testing.T) {
+	words := []string{"puts", "push", "print", "len", "let", "len"}
+	tests := []struct {
+		keys     string
+		expected string
+		listed   string
+	}{
+		{"pr\t\r", "print", ""},
+		{"x = le\t\r", "x = le", "len  let"},
+		{"pu\t\r", "pu", "push  puts"},
+		{"pus\t\r", "push", ""},
+		{"l\t\r", "le", ""},
+		{"foo\t\r", "foo", ""},
+		{"pr\tin\t\r", "printin", ""},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor()
+		e.Complete = func() []string { return words }
+		out := &bytes.Buffer{}
+		e.out = out
+		line, err := editKeys(t, e, tt.keys)
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q. got=%q", tt.keys, tt.expected, line)
+		}
+		if tt.listed != "" && !strings.Contains(out.String(), "\n"+tt.listed+"\n") {
+			t.Errorf("keys %q: expected %q to be listed. got=%q", tt.keys, tt.listed, out.String())
+		}
+	}
+}
+
+func TestRefreshScrollsLongLines(t *testing.T) {
+	e := newTestEditor()
+	e.width = 12
+	out := &bytes.Buffer{}
+	e.out = out
+	s := &lineState{prompt: "> ", buf: []rune("abcdefghijklmnop")}
+	s.pos = len(s.buf)
+	e.refresh(s)
+	// 9 columns are left after the prompt and the cursor
+	expected := "\r> hijklmnop\033[K\r\033[11C"
+	if out.String() != expected {
+		t.Errorf("expected %q. got=%q", expected, out.String())
+	}
+}
+
+func TestReadLineWithoutTerminal(t *testing.T) {
+	out := &bytes.Buffer{}
+	e := New(strings.NewReader("let x = 1;\r\nputs(x)"), out)
+	if e.IsTerminal() {
+		t.Fatalf("expected a strings.Reader not to be a terminal")
+	}
+	for _, expected := range []string{"let x = 1;", "puts(x)"} {
+		line, err := e.ReadLine("> ")
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		if line != expected {
+			t.Errorf("expected %q. got=%q", expected, line)
+		}
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("expected io.EOF. got=%v", err)
+	}
+	if out.String() != "> > > " {
+		t.Errorf("expected the prompt to be printed for each line. got=%q", out.String())
+	}
+}
+
+func TestMultiLineHistoryEntry(t *testing.T) {
+	e := newTestEditor("let f = fn() {\n  1\n};")
+	out := &bytes.Buffer{}
+	e.out = out
+	line, err := editKeys(t, e, "\x1b[A\r")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if line != "let f = fn() {\n  1\n};" {
+		t.Errorf("expected the entry with its newlines. got=%q", line)
+	}
+	if !strings.Contains(out.String(), "let f = fn() {↵  1↵};") {
+		t.Errorf("expected newlines to be drawn as ↵. got=%q", out.String())
+	}
+}
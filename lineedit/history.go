@@ -0,0 +1,111 @@
+package lineedit
+
+import (
+	"os"
+	"strings"
+)
+
+// Only the most recent entries are kept
+const maxHistory = 1000
+
+// Lines entered in earlier sessions and this one, oldest first. If path is
+// set every new line is appended to that file so history survives restarts.
+// An entry can span several lines (the repl adds a multi-line input as one
+// entry), so entries are escaped in the file to keep one per line.
+type History struct {
+	entries []string
+	path    string
+}
+
+// Loads history from path. A missing file is fine since that's just what
+// history looks like the first time the repl runs. Add only ever appends
+// to the file, so if it has grown past maxHistory lines it's rewritten
+// here with just the entries we kept.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		if line = strings.TrimSuffix(line, "\r"); line != "" {
+			h.entries = append(h.entries, unescapeEntry(line))
+		}
+	}
+	if len(h.entries) <= maxHistory {
+		return h, nil
+	}
+	h.entries = h.entries[len(h.entries)-maxHistory:]
+	contents = nil
+	for _, entry := range h.entries {
+		contents = append(contents, escapeEntry(entry)+"\n"...)
+	}
+	return h, os.WriteFile(path, contents, 0600)
+}
+
+// Newlines become `\n` and backslashes `\\` so every entry fits on one
+// line of the history file
+func escapeEntry(entry string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(entry)
+}
+
+func unescapeEntry(line string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(line)
+}
+
+// Returns the path of the history file in the user's home directory
+func DefaultHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return home + string(os.PathSeparator) + ".monkey_history", nil
+}
+
+// Adds a line to the history (and the history file). Blank lines and
+// repeats of the previous line are skipped.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(escapeEntry(line) + "\n")
+	return err
+}
+
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// Returns the i-th entry where 0 is the oldest
+func (h *History) At(i int) string {
+	return h.entries[i]
+}
+
+// Searches backwards from entry `before` (exclusive) for an entry
+// containing query. Returns -1 if there isn't one.
+func (h *History) search(query string, before int) int {
+	for i := min(before, len(h.entries)) - 1; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+	return -1
+}
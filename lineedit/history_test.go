@@ -0,0 +1,86 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("a missing history file should be fine. got=%s", err)
+	}
+	for _, line := range []string{"let x = 1", "let x = 1", "", "  ", "puts(x)", "if (x) {\n\"a\\\\b\"\n}"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if string(contents) != "let x = 1\nputs(x)\nif (x) {\\n\"a\\\\\\\\b\"\\n}\n" {
+		t.Errorf("expected blank and repeated entries to be skipped and multi-line ones escaped. got=%q", contents)
+	}
+
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if reloaded.Len() != 3 || reloaded.At(0) != "let x = 1" || reloaded.At(1) != "puts(x)" ||
+		reloaded.At(2) != "if (x) {\n\"a\\\\b\"\n}" {
+		t.Errorf("expected history to be reloaded. got=%q", reloaded.entries)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	lines := []string{}
+	for i := 0; i < maxHistory+10; i++ {
+		lines = append(lines, "puts("+strings.Repeat("1", i%7+1)+string(rune('a'+i%26))+")")
+	}
+	os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+
+	h, err := LoadHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if h.Len() != maxHistory {
+		t.Fatalf("expected %d entries. got=%d", maxHistory, h.Len())
+	}
+	if h.At(0) != lines[10] {
+		t.Errorf("expected the oldest entries to be dropped. got=%q", h.At(0))
+	}
+
+	// The file is trimmed too so it doesn't grow forever
+	contents, _ := os.ReadFile(path)
+	expected := strings.Join(lines[10:], "\n") + "\n"
+	if string(contents) != expected {
+		t.Errorf("expected the history file to be trimmed to %d lines. got=%d", maxHistory, strings.Count(string(contents), "\n"))
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	h := &History{entries: []string{"let x = 1", "puts(x)", "let y = 2"}}
+	tests := []struct {
+		query    string
+		before   int
+		expected int
+	}{
+		{"let", 3, 2},
+		{"let", 2, 0},
+		{"x", 3, 1},
+		{"z", 3, -1},
+		{"let", 0, -1},
+	}
+	for _, tt := range tests {
+		if got := h.search(tt.query, tt.before); got != tt.expected {
+			t.Errorf("search(%q, %d): expected %d. got=%d", tt.query, tt.before, tt.expected, got)
+		}
+	}
+}
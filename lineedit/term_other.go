@@ -0,0 +1,22 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package lineedit
+
+import "errors"
+
+// Raw mode isn't supported here (e.g. on Windows) so the editor
+// always falls back to reading plain lines
+
+var errUnsupported = errors.New("raw mode is not supported on this platform")
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (restore func() error, err error) {
+	return nil, errUnsupported
+}
+
+func terminalWidth(fd int) (int, error) {
+	return 0, errUnsupported
+}
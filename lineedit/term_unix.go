@@ -0,0 +1,56 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package lineedit
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, getTermios, unsafe.Pointer(&termios)) == nil
+}
+
+// Puts the terminal into raw mode so we get each key press as it happens
+// instead of a line at a time, and so the terminal doesn't echo keys.
+// Output processing is left on so "\n" still moves to the start of the
+// next line. Returns a function that puts the terminal back.
+func makeRaw(fd int) (restore func() error, err error) {
+	var original syscall.Termios
+	if err := ioctl(fd, getTermios, unsafe.Pointer(&original)); err != nil {
+		return nil, err
+	}
+	raw := original
+	raw.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+	raw.Cflag |= syscall.CS8
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
+	// read returns after every byte
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, setTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return ioctl(fd, setTermios, unsafe.Pointer(&original))
+	}, nil
+}
+
+// Returns the number of columns in the terminal
+func terminalWidth(fd int) (int, error) {
+	var size struct {
+		rows, columns, xPixels, yPixels uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil {
+		return 0, err
+	}
+	return int(size.columns), nil
+}
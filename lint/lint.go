@@ -0,0 +1,285 @@
+// Package lint finds likely mistakes in Monkey programs without running
+// them. Most of the checks are built on the scope resolution in resolve.go.
+package lint
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/evaluator"
+	"monkey-pl/token"
+	"sort"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Codes identify the check that produced a diagnostic
+const (
+	CodeUndefined         = "undefined"
+	CodeUnused            = "unused"
+	CodeShadow            = "shadow"
+	CodeUnreachable       = "unreachable"
+	CodeArity             = "arity"
+	CodeConstantCondition = "constant-condition"
+)
+
+type Diagnostic struct {
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Offset   int      `json:"offset"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Line, d.Column, d.Severity, d.Message, d.Code)
+}
+
+func newDiagnostic(tok token.Token, severity Severity, code string, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Offset:   tok.Offset,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// Runs every check on a program and returns the diagnostics sorted by
+// where they are in the source. The program should have parsed without
+// errors. Names starting with `_` are never reported as unused.
+func Lint(program *ast.Program) []Diagnostic {
+	resolution := Resolve(program)
+	diagnostics := []Diagnostic{}
+	diagnostics = append(diagnostics, checkUndefined(resolution)...)
+	diagnostics = append(diagnostics, checkBindings(resolution)...)
+	diagnostics = append(diagnostics, checkCalls(program, resolution)...)
+	diagnostics = append(diagnostics, checkUnreachable(program)...)
+	diagnostics = append(diagnostics, checkConstantConditions(program)...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Offset < diagnostics[j].Offset
+	})
+	return diagnostics
+}
+
+func checkUndefined(resolution *Resolution) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, identifier := range resolution.Unresolved {
+		diagnostics = append(diagnostics, newDiagnostic(identifier.Token, Error, CodeUndefined,
+			"identifier not found: %s", identifier.Value))
+	}
+	return diagnostics
+}
+
+// Reports unused bindings and bindings that shadow another binding
+func checkBindings(resolution *Resolution) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, binding := range resolution.Bindings {
+		declaration := binding.Declarations[0]
+		if binding.Kind == SelfBinding {
+			continue
+		}
+		if len(binding.Uses) == 0 && !isIgnored(binding.Name) {
+			diagnostics = append(diagnostics, newDiagnostic(declaration.Token, Warning, CodeUnused,
+				"%s %s is never used", describeKind(binding.Kind), binding.Name))
+		}
+		if binding.Shadows != nil && binding.Shadows.Kind != BuiltinBinding && binding.Shadows.Kind != SelfBinding {
+			shadowed := binding.Shadows.Declarations[0].Token
+			diagnostics = append(diagnostics, newDiagnostic(declaration.Token, Warning, CodeShadow,
+				"%s shadows the %s declared at %d:%d", binding.Name, describeKind(binding.Shadows.Kind), shadowed.Line, shadowed.Column))
+		}
+	}
+	return diagnostics
+}
+
+func describeKind(kind BindingKind) string {
+	switch kind {
+	case ParameterBinding:
+		return "parameter"
+	case FunctionBinding:
+		return "function"
+	default:
+		return "variable"
+	}
+}
+
+// Checks the number of arguments in calls to builtins and to functions
+// that are only ever bound to one function literal
+func checkCalls(program *ast.Program, resolution *Resolution) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || hasSpread(call.Arguments) {
+			return true
+		}
+		identifier, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return true
+		}
+		binding := resolution.Uses[identifier]
+		if binding == nil {
+			return true
+		}
+
+		var min, max int
+		switch {
+		case binding.Kind == BuiltinBinding:
+			info, ok := evaluator.LookupBuiltin(binding.Name)
+			if !ok {
+				return true
+			}
+			min, max = info.MinArgs, info.MaxArgs
+		case binding.Function != nil:
+			min, max = evaluator.FunctionArity(binding.Function.Parameters)
+		default:
+			return true
+		}
+
+		count := len(call.Arguments)
+		if count < min || max != -1 && count > max {
+			diagnostics = append(diagnostics, newDiagnostic(identifier.Token, Error, CodeArity,
+				"%s is called with %s but expects %s", binding.Name, plural(count, "argument"), describeArity(min, max)))
+		}
+		return true
+	})
+	return diagnostics
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadElement); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// e.g. "1 argument" or "2 arguments"
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+func describeArity(min int, max int) string {
+	switch {
+	case max == -1:
+		return fmt.Sprintf("at least %d", min)
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	default:
+		return fmt.Sprintf("%d to %d", min, max)
+	}
+}
+
+// Reports the first statement after a return in the same block
+func checkUnreachable(program *ast.Program) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	check := func(statements []ast.Statement) {
+		for i, statement := range statements {
+			if _, ok := statement.(*ast.ReturnStatement); ok && i+1 < len(statements) {
+				diagnostics = append(diagnostics, newDiagnostic(statementToken(statements[i+1]), Warning, CodeUnreachable,
+					"unreachable code after return"))
+				return
+			}
+		}
+	}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Program:
+			check(node.Statements)
+		case *ast.BlockStatement:
+			check(node.Statements)
+		}
+		return true
+	})
+	return diagnostics
+}
+
+func statementToken(statement ast.Statement) token.Token {
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		return statement.Token
+	case *ast.ReturnStatement:
+		return statement.Token
+	case *ast.FunctionStatement:
+		return statement.Token
+	case *ast.ExpressionStatement:
+		return statement.Token
+	case *ast.BlockStatement:
+		return statement.Token
+	default:
+		return token.Token{}
+	}
+}
+
+// Reports if and while conditions that can't depend on anything
+func checkConstantConditions(program *ast.Program) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.IfExpression:
+			if isConstant(node.Condition) {
+				diagnostics = append(diagnostics, newDiagnostic(node.Token, Warning, CodeConstantCondition,
+					"if condition is always %s", truthiness(node.Condition)))
+			}
+		case *ast.WhileExpression:
+			if isConstant(node.Condition) {
+				diagnostics = append(diagnostics, newDiagnostic(node.Token, Warning, CodeConstantCondition,
+					"while condition is always %s", truthiness(node.Condition)))
+			}
+		}
+		return true
+	})
+	return diagnostics
+}
+
+// An expression is constant when it is made of literals and operators.
+// Function literals are always truthy so they count too.
+func isConstant(expression ast.Expression) bool {
+	constant := true
+	ast.Inspect(expression, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			return false
+		case *ast.Identifier, *ast.CallExpression, *ast.IndexExpression, *ast.IfExpression, *ast.WhileExpression:
+			constant = false
+		}
+		return constant
+	})
+	return constant
+}
+
+// Only a handful of constants are easy to evaluate without running the
+// program. For everything else we just say the condition is constant.
+func truthiness(expression ast.Expression) string {
+	switch expression := expression.(type) {
+	case *ast.BooleanLiteral:
+		if expression.Value {
+			return "true"
+		}
+		return "false"
+	case *ast.PrefixExpression:
+		if expression.Operator == "!" {
+			switch truthiness(expression.Right) {
+			case "true":
+				return "false"
+			case "false":
+				return "true"
+			}
+		}
+		return "the same"
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral:
+		// only false and null are falsy in Monkey
+		return "true"
+	default:
+		return "the same"
+	}
+}
@@ -0,0 +1,125 @@
+package lint
+
+import (
+	"monkey-pl/lexer"
+	"monkey-pl/parser"
+	"monkey-pl/parser/parsertest"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// clean programs
+		{"let x = 5; print(x);", []string{}},
+		{"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; isEven(4);", []string{}},
+		{"fn fact(n) { if (n < 2) { return 1; } n * fact(n - 1) } fact(5);", []string{}},
+		{"let unless = macro(c, a, b) { quote(if (!(unquote(c))) { unquote(a) } else { unquote(b) }) }; unless(true, 1, 2);", []string{}},
+		{"let i = 0; while (i < 3) { let i = i + 1; } i;", []string{}},
+		{"let [a, b = a, ...c] = [1]; let {d, e: f} = {}; print(a, b, c, d, f);", []string{}},
+		{"let f = fn(_unused, x) { x }; f(1, 2); let _ignored = 1;", []string{}},
+		{"quote(notDefined + 1)", []string{}},
+		// set by `monkey run`
+		{"print(args)", []string{}},
+		// undefined names
+		{"let x = y;\nx", []string{"1:9: error: identifier not found: y (undefined)"}},
+		{"quote(unquote(nope))", []string{"1:15: error: identifier not found: nope (undefined)"}},
+		// unused names
+		{"let x = 5;", []string{"1:5: warning: variable x is never used (unused)"}},
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{"1:15: warning: parameter b is never used (unused)"}},
+		{"fn helper() {}", []string{"1:4: warning: function helper is never used (unused)"}},
+		// shadowing
+		{
+			"let x = 1; let f = fn(x) { x }; f(x);",
+			[]string{"1:23: warning: x shadows the variable declared at 1:5 (shadow)"},
+		},
+		// shadowing a builtin is fine, and calls are checked against the new function
+		{"let len = fn(a, b) { a + b }; len(1, 2);", []string{}},
+		// arity
+		{"len(1, 2)", []string{"1:1: error: len is called with 2 arguments but expects 1 (arity)"}},
+		{"let f = fn(a, b = 1) { a + b }; f(); f(1); f(1, 2, 3);", []string{
+			"1:33: error: f is called with 0 arguments but expects 1 to 2 (arity)",
+			"1:44: error: f is called with 3 arguments but expects 1 to 2 (arity)",
+		}},
+		{"fn g(a, ...rest) { [a, rest] } g(); g(...[1]); g(1, 2, 3);", []string{
+			"1:32: error: g is called with 0 arguments but expects at least 1 (arity)",
+		}},
+		{"print(); print(1, 2, 3)", []string{}},
+		{"let h = fn(a, b) { a + b }; h(1);", []string{"1:29: error: h is called with 1 argument but expects 2 (arity)"}},
+		// unreachable code
+		{"let f = fn() { return 1; 2; 3 }; f();", []string{"1:26: warning: unreachable code after return (unreachable)"}},
+		// constant conditions
+		{"if (true) { 1 }", []string{"1:1: warning: if condition is always true (constant-condition)"}},
+		{"if (!true) { 1 }", []string{"1:1: warning: if condition is always false (constant-condition)"}},
+		{"while (1 < 2) { 1 }", []string{"1:1: warning: while condition is always the same (constant-condition)"}},
+		{"let x = 1; if (x < 2) { 1 }", []string{}},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Lint(parsertest.Parse(t, tt.input))
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("Lint(%q) returned %d diagnostics. Expected %d. Got %v", tt.input, len(diagnostics), len(tt.expected), diagnostics)
+			continue
+		}
+		for i, diagnostic := range diagnostics {
+			if diagnostic.String() != tt.expected[i] {
+				t.Errorf("Lint(%q)[%d] wrong. Expected %q. Got %q", tt.input, i, tt.expected[i], diagnostic.String())
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	program := parsertest.Parse(t, "let x = 1; let f = fn(y) { x + y }; f(x);")
+	resolution := Resolve(program)
+
+	if len(resolution.Unresolved) != 0 {
+		t.Errorf("Expected no unresolved identifiers. Got %v", resolution.Unresolved)
+	}
+	if len(resolution.Bindings) != 3 {
+		t.Fatalf("Expected 3 bindings. Got %d", len(resolution.Bindings))
+	}
+
+	x := resolution.Bindings[0]
+	if x.Name != "x" || x.Kind != LetBinding || len(x.Uses) != 2 {
+		t.Errorf("Wrong binding for x: %+v", x)
+	}
+	f := resolution.Bindings[1]
+	if f.Name != "f" || f.Function == nil || len(f.Uses) != 1 {
+		t.Errorf("Wrong binding for f: %+v", f)
+	}
+	y := resolution.Bindings[2]
+	if y.Name != "y" || y.Kind != ParameterBinding || y.Scope.Node != f.Function {
+		t.Errorf("Wrong binding for y: %+v", y)
+	}
+
+	for _, use := range x.Uses {
+		if resolution.Uses[use] != x {
+			t.Errorf("Expected use of x at %d:%d to resolve to x", use.Token.Line, use.Token.Column)
+		}
+	}
+	if resolution.Uses[x.Declarations[0]] != x {
+		t.Errorf("Expected the declaration of x to resolve to x")
+	}
+}
+
+// Editors resolve programs while they're being typed, so every prefix of
+// a program has to resolve without panicking
+func TestResolveBrokenPrograms(t *testing.T) {
+	input := `let x: int = 1;
+fn f(a, b = 1, ...rest) -> int {
+  let [c, ...d] = rest;
+  let {e, "g": h = 2, ...i} = {"e": a};
+  if (a > b) { return c; } else { while (x < 3) { let x = x + 1; } }
+  f(...d, [1, 2][0], {"k": fn(y) { y }});
+}
+let m = macro(a) { quote(unquote(a) + 1) };
+-x; !true; m(x)["s"];`
+
+	for i := range input {
+		program := parser.New(lexer.New(input[:i])).ParseProgram()
+		Resolve(program)
+	}
+}
@@ -0,0 +1,342 @@
+package lint
+
+import (
+	"monkey-pl/ast"
+	"monkey-pl/evaluator"
+	"strings"
+)
+
+type BindingKind string
+
+const (
+	LetBinding       BindingKind = "let"
+	ParameterBinding BindingKind = "parameter"
+	FunctionBinding  BindingKind = "function" // `fn name() {}` statements
+	// The name of a named function literal, which is only visible
+	// inside of the function itself (e.g. `fact` in `fn fact(n) {}`)
+	SelfBinding    BindingKind = "self"
+	BuiltinBinding BindingKind = "builtin"
+)
+
+// Names that are always defined but aren't in the builtins table. `args`
+// is set by `monkey run` to the script's command line arguments.
+var specialNames = []string{"quote", "unquote", "args"}
+
+// A name introduced by a let, parameter or function declaration (or a builtin)
+type Binding struct {
+	Name string
+	Kind BindingKind
+	// Monkey lets you `let` the same name more than once in a scope. We
+	// treat those as one binding so Declarations can have several entries.
+	// It is empty for builtins.
+	Declarations []*ast.Identifier
+	Uses         []*ast.Identifier
+	// The function bound to the name when there is exactly one declaration
+	// and it is a function literal. Used to check calls.
+	Function *ast.FunctionLiteral
+	Scope    *Scope
+	// The binding from an enclosing scope this one hides, if any
+	Shadows *Binding
+}
+
+// Monkey only has function scope (blocks don't introduce a new scope)
+// so there is a scope for the program and one for each function.
+type Scope struct {
+	Parent   *Scope
+	Node     ast.Node // *ast.Program, *ast.FunctionLiteral or *ast.MacroLiteral. nil for builtins
+	bindings map[string]*Binding
+}
+
+func newScope(parent *Scope, node ast.Node) *Scope {
+	return &Scope{Parent: parent, Node: node, bindings: map[string]*Binding{}}
+}
+
+// Looks name up in this scope and the scopes enclosing it
+func (s *Scope) Lookup(name string) *Binding {
+	for scope := s; scope != nil; scope = scope.Parent {
+		if binding, ok := scope.bindings[name]; ok {
+			return binding
+		}
+	}
+	return nil
+}
+
+// The result of resolving every identifier in a program
+type Resolution struct {
+	// Every binding declared in the program in the order they were declared
+	Bindings []*Binding
+	// Maps identifiers (both declarations and uses) to their binding
+	Uses map[*ast.Identifier]*Binding
+	// The scope each use appears in. Looking a name up in it once the whole
+	// program is resolved finds declarations that come after the use too.
+	UseScopes map[*ast.Identifier]*Scope
+	// Identifiers that don't refer to anything
+	Unresolved []*ast.Identifier
+	// Scopes for the program and every function
+	Scopes map[ast.Node]*Scope
+}
+
+// Works out what each identifier in the program refers to.
+//
+// Function bodies only run when the function is called, so they can refer
+// to names that are declared after the function (this is what makes mutual
+// recursion work). To handle that we resolve a function's body only after
+// the rest of the scope it was created in has been resolved.
+func Resolve(program *ast.Program) *Resolution {
+	r := &resolver{
+		result: &Resolution{
+			Uses:      map[*ast.Identifier]*Binding{},
+			UseScopes: map[*ast.Identifier]*Scope{},
+			Scopes:    map[ast.Node]*Scope{},
+		},
+	}
+	universe := newScope(nil, nil)
+	names := append(evaluator.BuiltinNames(), specialNames...)
+	for _, name := range names {
+		universe.bindings[name] = &Binding{Name: name, Kind: BuiltinBinding, Scope: universe}
+	}
+	scope := newScope(universe, program)
+	r.result.Scopes[program] = scope
+	r.body(program.Statements, scope)
+	return r.result
+}
+
+// Editors resolve programs with syntax errors too. Failed parses leave nil
+// nodes in the tree (sometimes typed nils), so each kind of node is
+// checked with ast.IsNil before we look inside it.
+type resolver struct {
+	result *Resolution
+	// function bodies waiting for their enclosing scope to finish
+	pending []pendingFunction
+}
+
+type pendingFunction struct {
+	function ast.Expression // *ast.FunctionLiteral or *ast.MacroLiteral
+	scope    *Scope
+}
+
+// Resolves the statements of a program or function body followed by the
+// bodies of any functions created in it
+func (r *resolver) body(statements []ast.Statement, scope *Scope) {
+	saved := r.pending
+	r.pending = nil
+	r.statements(statements, scope)
+	pending := r.pending
+	r.pending = saved
+	for _, p := range pending {
+		r.function(p.function, p.scope)
+	}
+}
+
+func (r *resolver) function(function ast.Expression, parent *Scope) {
+	if ast.IsNil(function) {
+		return
+	}
+	switch function := function.(type) {
+	case *ast.FunctionLiteral:
+		if function.Name != "" {
+			parent = newScope(parent, nil)
+			r.declare(parent, &ast.Identifier{Token: function.Token, Value: function.Name}, SelfBinding, function)
+		}
+		scope := newScope(parent, function)
+		r.result.Scopes[function] = scope
+		for _, param := range function.Parameters {
+			r.pattern(param, scope, ParameterBinding)
+		}
+		if function.Body != nil {
+			r.body(function.Body.Statements, scope)
+		}
+	case *ast.MacroLiteral:
+		scope := newScope(parent, function)
+		r.result.Scopes[function] = scope
+		for _, param := range function.Parameters {
+			r.declare(scope, param, ParameterBinding, nil)
+		}
+		if function.Body != nil {
+			r.body(function.Body.Statements, scope)
+		}
+	}
+}
+
+func (r *resolver) statements(statements []ast.Statement, scope *Scope) {
+	for _, statement := range statements {
+		r.statement(statement, scope)
+	}
+}
+
+func (r *resolver) statement(statement ast.Statement, scope *Scope) {
+	if ast.IsNil(statement) {
+		return
+	}
+	switch statement := statement.(type) {
+	case *ast.LetStatement:
+		r.expression(statement.Value, scope)
+		if statement.Pattern != nil {
+			r.pattern(statement.Pattern, scope, LetBinding)
+		} else if statement.Name != nil {
+			function, _ := statement.Value.(*ast.FunctionLiteral)
+			r.declare(scope, statement.Name, LetBinding, function)
+		}
+	case *ast.FunctionStatement:
+		if statement.Name != nil {
+			r.declare(scope, statement.Name, FunctionBinding, statement.Function)
+		}
+		if statement.Function != nil {
+			r.pending = append(r.pending, pendingFunction{statement.Function, scope})
+		}
+	case *ast.ReturnStatement:
+		r.expression(statement.ReturnValue, scope)
+	case *ast.ExpressionStatement:
+		r.expression(statement.Expression, scope)
+	case *ast.BlockStatement:
+		r.statements(statement.Statements, scope)
+	}
+}
+
+// Declares the names in a pattern. Defaults are resolved as we go since a
+// default can refer to names bound earlier in the same pattern.
+func (r *resolver) pattern(pattern ast.Pattern, scope *Scope, kind BindingKind) {
+	if ast.IsNil(pattern) {
+		return
+	}
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		r.declare(scope, pattern, kind, nil)
+	case *ast.AssignmentPattern:
+		r.expression(pattern.Default, scope)
+		r.pattern(pattern.Target, scope, kind)
+	case *ast.RestElement:
+		if pattern.Target != nil {
+			r.declare(scope, pattern.Target, kind, nil)
+		}
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			r.pattern(element, scope, kind)
+		}
+	case *ast.HashPattern:
+		for _, property := range pattern.Properties {
+			r.pattern(property.Value, scope, kind)
+		}
+		if pattern.Rest != nil {
+			r.pattern(pattern.Rest, scope, kind)
+		}
+	}
+}
+
+func (r *resolver) declare(scope *Scope, name *ast.Identifier, kind BindingKind, function *ast.FunctionLiteral) {
+	if binding, ok := scope.bindings[name.Value]; ok {
+		binding.Declarations = append(binding.Declarations, name)
+		binding.Function = nil
+		r.result.Uses[name] = binding
+		return
+	}
+	binding := &Binding{
+		Name:         name.Value,
+		Kind:         kind,
+		Declarations: []*ast.Identifier{name},
+		Function:     function,
+		Scope:        scope,
+	}
+	if scope.Parent != nil {
+		binding.Shadows = scope.Parent.Lookup(name.Value)
+	}
+	scope.bindings[name.Value] = binding
+	r.result.Bindings = append(r.result.Bindings, binding)
+	r.result.Uses[name] = binding
+}
+
+func (r *resolver) use(identifier *ast.Identifier, scope *Scope) {
+	r.result.UseScopes[identifier] = scope
+	binding := scope.Lookup(identifier.Value)
+	if binding == nil {
+		r.result.Unresolved = append(r.result.Unresolved, identifier)
+		return
+	}
+	binding.Uses = append(binding.Uses, identifier)
+	r.result.Uses[identifier] = binding
+}
+
+func (r *resolver) expression(expression ast.Expression, scope *Scope) {
+	if ast.IsNil(expression) {
+		return
+	}
+	switch expression := expression.(type) {
+	case *ast.Identifier:
+		r.use(expression, scope)
+	case *ast.PrefixExpression:
+		r.expression(expression.Right, scope)
+	case *ast.InfixExpression:
+		r.expression(expression.Left, scope)
+		r.expression(expression.Right, scope)
+	case *ast.IndexExpression:
+		r.expression(expression.Left, scope)
+		r.expression(expression.Index, scope)
+	case *ast.IfExpression:
+		r.expression(expression.Condition, scope)
+		if expression.Consequence != nil {
+			r.statement(expression.Consequence, scope)
+		}
+		if expression.Alternative != nil {
+			r.statement(expression.Alternative, scope)
+		}
+	case *ast.WhileExpression:
+		r.expression(expression.Condition, scope)
+		if expression.Body != nil {
+			r.statement(expression.Body, scope)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range expression.Elements {
+			r.expression(element, scope)
+		}
+	case *ast.HashLiteral:
+		for _, key := range expression.OrderedKeys() {
+			r.expression(key, scope)
+			r.expression(expression.Pairs[key], scope)
+		}
+	case *ast.SpreadElement:
+		r.expression(expression.Value, scope)
+	case *ast.CallExpression:
+		r.expression(expression.Function, scope)
+		if r.isBuiltin(expression.Function, "quote", scope) {
+			for _, arg := range expression.Arguments {
+				r.quoted(arg, scope)
+			}
+			return
+		}
+		for _, arg := range expression.Arguments {
+			r.expression(arg, scope)
+		}
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		r.pending = append(r.pending, pendingFunction{expression, scope})
+	}
+}
+
+// Quoted code isn't evaluated so names in it don't need to be defined.
+// The exception is code inside of unquote calls.
+func (r *resolver) quoted(node ast.Node, scope *Scope) {
+	ast.Inspect(node, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || !r.isBuiltin(call.Function, "unquote", scope) {
+			return true
+		}
+		r.expression(call.Function, scope)
+		for _, arg := range call.Arguments {
+			r.expression(arg, scope)
+		}
+		return false
+	})
+}
+
+func (r *resolver) isBuiltin(expression ast.Expression, name string, scope *Scope) bool {
+	identifier, ok := expression.(*ast.Identifier)
+	if !ok || identifier.Value != name {
+		return false
+	}
+	binding := scope.Lookup(name)
+	return binding != nil && binding.Kind == BuiltinBinding
+}
+
+// Names starting with an underscore are allowed to go unused
+func isIgnored(name string) bool {
+	return strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,245 @@
+package lsp
+
+import (
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/lint"
+	"monkey-pl/parser"
+	"monkey-pl/token"
+	"sort"
+	"unicode/utf8"
+)
+
+// An open file. It's parsed again every time it changes, which is
+// plenty fast for the size of programs people write in Monkey.
+type document struct {
+	uri  string
+	text string
+	// Parsed in trivia mode so program.Tokens has every token
+	program     *ast.Program
+	parseErrors []parser.ParseError
+	resolution  *lint.Resolution
+	// offset of the first byte of each line
+	lineStarts []int
+}
+
+func newDocument(uri string, text string) *document {
+	doc := &document{uri: uri, text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lineStarts = append(doc.lineStarts, i+1)
+		}
+	}
+	pars := parser.New(lexer.NewWithTrivia(text))
+	doc.program = pars.ParseProgram()
+	doc.parseErrors = pars.ParseErrors()
+	doc.resolution = lint.Resolve(doc.program)
+	return doc
+}
+
+// Converts a byte offset into an LSP position
+func (doc *document) position(offset int) Position {
+	if offset > len(doc.text) {
+		offset = len(doc.text)
+	}
+	line := sort.Search(len(doc.lineStarts), func(i int) bool { return doc.lineStarts[i] > offset }) - 1
+	character := 0
+	for _, r := range doc.text[doc.lineStarts[line]:offset] {
+		character += utf16Length(r)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// Converts an LSP position into a byte offset
+func (doc *document) offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(doc.lineStarts) {
+		return len(doc.text)
+	}
+	offset := doc.lineStarts[position.Line]
+	for character := 0; character < position.Character && offset < len(doc.text); {
+		r, size := utf8.DecodeRuneInString(doc.text[offset:])
+		if r == '\n' {
+			break
+		}
+		character += utf16Length(r)
+		offset += size
+	}
+	return offset
+}
+
+func utf16Length(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (doc *document) rangeOf(start int, end int) Range {
+	return Range{Start: doc.position(start), End: doc.position(end)}
+}
+
+func (doc *document) tokenRange(tok token.Token) Range {
+	return doc.rangeOf(tok.Offset, tokenEnd(tok))
+}
+
+func (doc *document) wholeRange() Range {
+	return doc.rangeOf(0, len(doc.text))
+}
+
+// The offset just past the end of a token. String literals don't
+// include their quotes.
+func tokenEnd(tok token.Token) int {
+	if tok.Type == token.STRING {
+		return tok.Offset + len(tok.Literal) + 2
+	}
+	if tok.Type == token.EOF {
+		return tok.Offset
+	}
+	return tok.Offset + len(tok.Literal)
+}
+
+// Returns the token that starts at offset, if there is one
+func (doc *document) tokenAt(offset int) (token.Token, bool) {
+	tokens := doc.program.Tokens
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i].Offset >= offset })
+	if i < len(tokens) && tokens[i].Offset == offset {
+		return tokens[i], true
+	}
+	return token.Token{}, false
+}
+
+// Works out the first and last byte of a node. The AST only holds the
+// first token of most nodes, so the end is found by taking the last token
+// of any node inside it and then moving past brackets that it left open
+// (like the `}` at the end of a function) and a trailing semicolon.
+func (doc *document) span(node ast.Node) (start int, end int) {
+	start, last := -1, -1
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if tok, ok := nodeToken(n); ok {
+			if start == -1 || tok.Offset < start {
+				start = tok.Offset
+			}
+			if tok.Offset > last {
+				last = tok.Offset
+			}
+		}
+		return true
+	})
+	if start == -1 {
+		return 0, 0
+	}
+
+	tokens := doc.program.Tokens
+	i := sort.Search(len(tokens), func(i int) bool { return tokens[i].Offset >= start })
+	depth := 0
+	end = start
+	for ; i < len(tokens) && tokens[i].Type != token.EOF; i++ {
+		tok := tokens[i]
+		if tok.Offset > last {
+			closes := isCloser(tok.Type) && depth > 0
+			if !closes && !(tok.Type == token.SEMICOLON && depth == 0) {
+				break
+			}
+		}
+		switch {
+		case isOpener(tok.Type):
+			depth++
+		case isCloser(tok.Type):
+			depth--
+		}
+		end = tokenEnd(tok)
+		if tok.Type == token.SEMICOLON && tok.Offset > last {
+			break
+		}
+	}
+	return start, end
+}
+
+func isOpener(t token.TokenType) bool {
+	return t == token.LPAREN || t == token.LBRACE || t == token.LBRACKET
+}
+
+func isCloser(t token.TokenType) bool {
+	return t == token.RPAREN || t == token.RBRACE || t == token.RBRACKET
+}
+
+// Returns the token stored in a node. Every node but Program has one.
+func nodeToken(node ast.Node) (token.Token, bool) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token, true
+	case *ast.ReturnStatement:
+		return node.Token, true
+	case *ast.ExpressionStatement:
+		return node.Token, true
+	case *ast.FunctionStatement:
+		return node.Token, true
+	case *ast.BlockStatement:
+		return node.Token, true
+	case *ast.Identifier:
+		return node.Token, true
+	case *ast.IntegerLiteral:
+		return node.Token, true
+	case *ast.StringLiteral:
+		return node.Token, true
+	case *ast.BooleanLiteral:
+		return node.Token, true
+	case *ast.PrefixExpression:
+		return node.Token, true
+	case *ast.InfixExpression:
+		return node.Token, true
+	case *ast.IndexExpression:
+		return node.Token, true
+	case *ast.IfExpression:
+		return node.Token, true
+	case *ast.WhileExpression:
+		return node.Token, true
+	case *ast.FunctionLiteral:
+		return node.Token, true
+	case *ast.MacroLiteral:
+		return node.Token, true
+	case *ast.CallExpression:
+		return node.Token, true
+	case *ast.ArrayLiteral:
+		return node.Token, true
+	case *ast.HashLiteral:
+		return node.Token, true
+	case *ast.SpreadElement:
+		return node.Token, true
+	case *ast.AssignmentPattern:
+		return node.Token, true
+	case *ast.RestElement:
+		return node.Token, true
+	case *ast.ArrayPattern:
+		return node.Token, true
+	case *ast.HashPattern:
+		return node.Token, true
+	case *ast.NamedType:
+		return node.Token, true
+	case *ast.ArrayType:
+		return node.Token, true
+	case *ast.HashType:
+		return node.Token, true
+	case *ast.FunctionType:
+		return node.Token, true
+	}
+	return token.Token{}, false
+}
+
+// Returns the identifier under the cursor
+func (doc *document) identifierAt(offset int) *ast.Identifier {
+	var found *ast.Identifier
+	ast.Inspect(doc.program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok && ident.Token.Offset <= offset && offset <= ident.Token.Offset+len(ident.Value) {
+			found = ident
+		}
+		return found == nil
+	})
+	return found
+}
@@ -0,0 +1,308 @@
+package lsp
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"monkey-pl/checker"
+	"monkey-pl/evaluator"
+	"monkey-pl/formatter"
+	"monkey-pl/lint"
+	"sort"
+	"strings"
+)
+
+// Syntax errors are reported on their own. Once the file parses it's
+// checked with the linter and the type checker.
+func diagnostics(doc *document) []Diagnostic {
+	result := []Diagnostic{}
+	if len(doc.parseErrors) != 0 {
+		for _, err := range doc.parseErrors {
+			result = append(result, Diagnostic{
+				Range:    doc.tokenRange(err.Token),
+				Severity: SeverityError,
+				Code:     "syntax",
+				Source:   "monkey",
+				Message:  err.Message,
+			})
+		}
+		return result
+	}
+
+	for _, diagnostic := range lint.Lint(doc.program) {
+		severity := SeverityWarning
+		if diagnostic.Severity == lint.Error {
+			severity = SeverityError
+		}
+		result = append(result, Diagnostic{
+			Range:    doc.rangeAt(diagnostic.Offset),
+			Severity: severity,
+			Code:     diagnostic.Code,
+			Source:   "monkey",
+			Message:  diagnostic.Message,
+		})
+	}
+	for _, typeError := range checker.Check(doc.program) {
+		result = append(result, Diagnostic{
+			Range:    doc.rangeAt(typeError.Offset),
+			Severity: SeverityError,
+			Code:     "type",
+			Source:   "monkey",
+			Message:  typeError.Message,
+		})
+	}
+	return result
+}
+
+// The range of the token starting at offset
+func (doc *document) rangeAt(offset int) Range {
+	if tok, ok := doc.tokenAt(offset); ok {
+		return doc.tokenRange(tok)
+	}
+	return doc.rangeOf(offset, offset)
+}
+
+func documentSymbols(doc *document) []DocumentSymbol {
+	return doc.symbols(doc.program.Statements)
+}
+
+// Blocks don't have their own scope so lets inside of ifs and whiles are
+// listed alongside the statements around them. Functions list the names
+// declared inside of them as children.
+func (doc *document) symbols(statements []ast.Statement) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, statement := range statements {
+		switch statement := statement.(type) {
+		case *ast.LetStatement:
+			start, end := doc.span(statement)
+			if statement.Name != nil {
+				symbols = append(symbols, doc.symbol(statement.Name, start, end, statement.Value))
+			}
+			for _, name := range patternNames(statement.Pattern) {
+				symbols = append(symbols, doc.symbol(name, start, end, nil))
+			}
+		case *ast.FunctionStatement:
+			if statement.Name != nil {
+				start, end := doc.span(statement)
+				symbols = append(symbols, doc.symbol(statement.Name, start, end, statement.Function))
+			}
+		case *ast.ExpressionStatement:
+			switch expression := statement.Expression.(type) {
+			case *ast.IfExpression:
+				if expression.Consequence != nil {
+					symbols = append(symbols, doc.symbols(expression.Consequence.Statements)...)
+				}
+				if expression.Alternative != nil {
+					symbols = append(symbols, doc.symbols(expression.Alternative.Statements)...)
+				}
+			case *ast.WhileExpression:
+				if expression.Body != nil {
+					symbols = append(symbols, doc.symbols(expression.Body.Statements)...)
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func (doc *document) symbol(name *ast.Identifier, start int, end int, value ast.Expression) DocumentSymbol {
+	symbol := DocumentSymbol{
+		Name:           name.Value,
+		Kind:           SymbolKindVariable,
+		Range:          doc.rangeOf(start, end),
+		SelectionRange: doc.tokenRange(name.Token),
+	}
+	if name.Type != nil {
+		symbol.Detail = name.Type.String()
+	}
+	if function, ok := value.(*ast.FunctionLiteral); ok {
+		symbol.Kind = SymbolKindFunction
+		symbol.Detail = signature("fn", function)
+		if function.Body != nil {
+			symbol.Children = doc.symbols(function.Body.Statements)
+		}
+	}
+	return symbol
+}
+
+// Returns the names a destructuring pattern binds
+func patternNames(pattern ast.Pattern) []*ast.Identifier {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		return []*ast.Identifier{pattern}
+	case *ast.AssignmentPattern:
+		return patternNames(pattern.Target)
+	case *ast.RestElement:
+		if pattern.Target != nil {
+			return []*ast.Identifier{pattern.Target}
+		}
+	case *ast.ArrayPattern:
+		names := []*ast.Identifier{}
+		for _, element := range pattern.Elements {
+			names = append(names, patternNames(element)...)
+		}
+		return names
+	case *ast.HashPattern:
+		names := []*ast.Identifier{}
+		for _, property := range pattern.Properties {
+			names = append(names, patternNames(property.Value)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, patternNames(pattern.Rest)...)
+		}
+		return names
+	}
+	return nil
+}
+
+// Looks up the binding of the identifier at position
+func (doc *document) bindingAt(position Position) (*ast.Identifier, *lint.Binding) {
+	ident := doc.identifierAt(doc.offset(position))
+	if ident == nil {
+		return nil, nil
+	}
+	return ident, doc.resolution.Uses[ident]
+}
+
+func definition(doc *document, position Position) []Location {
+	locations := []Location{}
+	_, binding := doc.bindingAt(position)
+	if binding == nil {
+		return locations
+	}
+	for _, declaration := range binding.Declarations {
+		locations = append(locations, doc.location(declaration))
+	}
+	return locations
+}
+
+func references(doc *document, position Position, includeDeclaration bool) []Location {
+	locations := []Location{}
+	_, binding := doc.bindingAt(position)
+	if binding == nil {
+		return locations
+	}
+	identifiers := append([]*ast.Identifier{}, binding.Uses...)
+	if includeDeclaration {
+		identifiers = append(identifiers, binding.Declarations...)
+	}
+	sort.Slice(identifiers, func(i, j int) bool {
+		return identifiers[i].Token.Offset < identifiers[j].Token.Offset
+	})
+	for _, ident := range identifiers {
+		locations = append(locations, doc.location(ident))
+	}
+	return locations
+}
+
+func (doc *document) location(ident *ast.Identifier) Location {
+	return Location{URI: doc.uri, Range: doc.tokenRange(ident.Token)}
+}
+
+func hover(doc *document, position Position) *Hover {
+	ident, binding := doc.bindingAt(position)
+	if binding == nil {
+		return nil
+	}
+	var code, docs string
+	switch {
+	case binding.Kind == lint.BuiltinBinding:
+		info, ok := evaluator.LookupBuiltin(binding.Name)
+		if !ok {
+			// quote and unquote
+			code = binding.Name + "(node)"
+			break
+		}
+		code = fmt.Sprintf("%s(%s)", info.Name, strings.Join(info.Params, ", "))
+		docs = info.Doc
+	case binding.Function != nil:
+		code = signature("fn "+binding.Name, binding.Function)
+	default:
+		declaration := binding.Declarations[0]
+		code = fmt.Sprintf("%s %s", binding.Kind, declaration.String())
+	}
+
+	value := "```monkey\n" + code + "\n```"
+	if docs != "" {
+		value += "\n\n" + docs
+	}
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    doc.tokenRange(ident.Token),
+	}
+}
+
+// e.g. `fn add(a: int, b = 1) -> int`
+func signature(prefix string, function *ast.FunctionLiteral) string {
+	params := []string{}
+	for _, param := range function.Parameters {
+		params = append(params, param.String())
+	}
+	result := fmt.Sprintf("%s(%s)", prefix, strings.Join(params, ", "))
+	if function.ReturnType != nil {
+		result += " -> " + function.ReturnType.String()
+	}
+	return result
+}
+
+// Offers every name that is visible at position, innermost first,
+// followed by the builtins
+func completion(doc *document, position Position) []CompletionItem {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	for scope := doc.scopeAt(doc.offset(position)); scope != nil; scope = scope.Parent {
+		for _, binding := range doc.resolution.Bindings {
+			if binding.Scope != scope || seen[binding.Name] {
+				continue
+			}
+			seen[binding.Name] = true
+			item := CompletionItem{Label: binding.Name, Kind: CompletionKindVariable, Detail: string(binding.Kind)}
+			if binding.Function != nil {
+				item.Kind = CompletionKindFunction
+				item.Detail = signature("fn", binding.Function)
+			}
+			items = append(items, item)
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if seen[name] {
+			continue
+		}
+		info, _ := evaluator.LookupBuiltin(name)
+		items = append(items, CompletionItem{
+			Label:         name,
+			Kind:          CompletionKindFunction,
+			Detail:        fmt.Sprintf("%s(%s)", name, strings.Join(info.Params, ", ")),
+			Documentation: info.Doc,
+		})
+	}
+	return items
+}
+
+// Returns the scope of the innermost function containing offset
+func (doc *document) scopeAt(offset int) *lint.Scope {
+	scope := doc.resolution.Scopes[doc.program]
+	ast.Inspect(doc.program, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			start, end := doc.span(node)
+			if offset < start || offset > end {
+				return false
+			}
+			if inner, ok := doc.resolution.Scopes[node]; ok {
+				scope = inner
+			}
+		}
+		return true
+	})
+	return scope
+}
+
+// Formatting replaces the whole document. Files with syntax
+// errors can't be formatted so they are left alone.
+func formatting(doc *document) []TextEdit {
+	formatted, err := formatter.Format(doc.text)
+	if err != nil || formatted == doc.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: doc.wholeRange(), NewText: formatted}}
+}
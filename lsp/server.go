@@ -0,0 +1,233 @@
+// Package lsp is a Language Server Protocol server for Monkey so editors
+// can show errors, jump to definitions and so on. It talks JSON-RPC over
+// stdin and stdout and is started with `monkey lsp`.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Server struct {
+	reader    *bufio.Reader
+	writer    io.Writer
+	documents map[string]*document
+	// The client has to send initialize before anything else and
+	// shutdown before exit
+	initialized bool
+	shutdown    bool
+}
+
+var ErrExitWithoutShutdown = errors.New("received exit before shutdown")
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		reader:    bufio.NewReader(in),
+		writer:    out,
+		documents: map[string]*document{},
+	}
+}
+
+// Handles messages until the client sends exit or closes the connection.
+// The spec says exit without a shutdown first should be treated as an
+// error, which is what ErrExitWithoutShutdown is for.
+func (s *Server) Serve() error {
+	for {
+		body, err := readMessage(s.reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		req := request{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(nil, nil, &responseError{Code: parseErrorCode, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		result, rpcErr := s.handle(req)
+		// notifications don't get a response, even if they fail
+		if req.ID != nil {
+			if err := s.reply(req.ID, result, rpcErr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *responseError) error {
+	if rpcErr != nil {
+		result = nil
+	}
+	return writeMessage(s.writer, response{JSONRPC: "2.0", ID: id, Result: result, Error: rpcErr})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return writeMessage(s.writer, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(req request) (result interface{}, rpcErr *responseError) {
+	// a bug in one feature shouldn't kill the editor's connection
+	defer func() {
+		if r := recover(); r != nil {
+			result, rpcErr = nil, &responseError{Code: internalErrorCode, Message: fmt.Sprint(r)}
+		}
+	}()
+
+	if req.Method == "initialize" {
+		s.initialized = true
+		return initializeResult(), nil
+	}
+	if !s.initialized {
+		return nil, &responseError{Code: serverNotInitializedCode, Message: "the server has not been initialized"}
+	}
+	if s.shutdown {
+		return nil, &responseError{Code: invalidRequestCode, Message: "the server is shutting down"}
+	}
+
+	switch req.Method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		params := DidOpenTextDocumentParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		params := DidChangeTextDocumentParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) > 0 {
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			s.update(params.TextDocument.URI, text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		params := DidCloseTextDocumentParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		// clear out the diagnostics for the closed file
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/documentSymbol":
+		params := DocumentSymbolParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return documentSymbols(doc), nil
+	case "textDocument/definition":
+		params := TextDocumentPositionParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return definition(doc, params.Position), nil
+	case "textDocument/references":
+		params := ReferenceParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return references(doc, params.Position, params.Context.IncludeDeclaration), nil
+	case "textDocument/hover":
+		params := TextDocumentPositionParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		if hover := hover(doc, params.Position); hover != nil {
+			return hover, nil
+		}
+		return nil, nil
+	case "textDocument/completion":
+		params := TextDocumentPositionParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return completion(doc, params.Position), nil
+	case "textDocument/formatting":
+		params := DocumentFormattingParams{}
+		if err := decodeParams(req, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return formatting(doc), nil
+	default:
+		return nil, &responseError{Code: methodNotFoundCode, Message: "method not found: " + req.Method}
+	}
+}
+
+func initializeResult() InitializeResult {
+	result := InitializeResult{Capabilities: ServerCapabilities{
+		TextDocumentSync:           1,
+		DocumentSymbolProvider:     true,
+		DefinitionProvider:         true,
+		ReferencesProvider:         true,
+		HoverProvider:              true,
+		CompletionProvider:         struct{}{},
+		DocumentFormattingProvider: true,
+	}}
+	result.ServerInfo.Name = "monkey"
+	return result
+}
+
+func decodeParams(req request, params interface{}) *responseError {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		return &responseError{Code: invalidParamsCode, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, *responseError) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: invalidParamsCode, Message: "unknown document: " + uri}
+	}
+	return doc, nil
+}
+
+// Reparses a document and sends the client its new diagnostics
+func (s *Server) update(uri string, text string) {
+	doc := newDocument(uri, text)
+	s.documents[uri] = doc
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics(doc)})
+}
@@ -0,0 +1,326 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"monkey-pl/evaluator"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///test.mk"
+
+// Sends every message to a server in one go and returns what it wrote back
+// keyed by id for responses and by method for notifications
+func session(t *testing.T, messages ...interface{}) map[string]json.RawMessage {
+	in := &bytes.Buffer{}
+	for _, message := range messages {
+		writeMessage(in, message)
+	}
+	out := &bytes.Buffer{}
+	if err := NewServer(in, out).Serve(); err != nil {
+		t.Fatalf("Serve returned an error: %s", err)
+	}
+
+	results := map[string]json.RawMessage{}
+	reader := bufio.NewReader(out)
+	for {
+		body, err := readMessage(reader)
+		if err != nil {
+			break
+		}
+		message := struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *responseError  `json:"error"`
+		}{}
+		json.Unmarshal(body, &message)
+		switch {
+		case message.Error != nil:
+			results[string(rune('0'+*message.ID))] = json.RawMessage(`"error: ` + message.Error.Message + `"`)
+		case message.ID != nil:
+			results[string(rune('0'+*message.ID))] = message.Result
+		default:
+			results[message.Method] = message.Params
+		}
+	}
+	return results
+}
+
+func call(id int, method string, params interface{}) request {
+	rawID := json.RawMessage(string(rune('0' + id)))
+	rawParams, _ := json.Marshal(params)
+	return request{JSONRPC: "2.0", ID: &rawID, Method: method, Params: rawParams}
+}
+
+func notify(method string, params interface{}) request {
+	rawParams, _ := json.Marshal(params)
+	return request{JSONRPC: "2.0", Method: method, Params: rawParams}
+}
+
+// Starts a session with a document open, runs the given requests
+// against it and shuts down
+func withDocument(t *testing.T, text string, requests ...interface{}) map[string]json.RawMessage {
+	messages := []interface{}{
+		call(0, "initialize", map[string]interface{}{}),
+		notify("initialized", map[string]interface{}{}),
+		notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Text: text}}),
+	}
+	messages = append(messages, requests...)
+	messages = append(messages, call(9, "shutdown", nil), notify("exit", nil))
+	return session(t, messages...)
+}
+
+func at(line int, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, character}}
+}
+
+func decode(t *testing.T, raw json.RawMessage, v interface{}) {
+	if err := json.Unmarshal(raw, v); err != nil {
+		t.Fatalf("could not decode %s: %s", raw, err)
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	results := withDocument(t, "")
+	result := InitializeResult{}
+	decode(t, results["0"], &result)
+	if !result.Capabilities.HoverProvider || !result.Capabilities.DefinitionProvider || result.Capabilities.TextDocumentSync != 1 {
+		t.Errorf("Unexpected capabilities: %s", results["0"])
+	}
+	if string(results["9"]) != "null" {
+		t.Errorf("Expected shutdown to return null. Got %s", results["9"])
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	in := &bytes.Buffer{}
+	writeMessage(in, notify("exit", nil))
+	if err := NewServer(in, &bytes.Buffer{}).Serve(); err != ErrExitWithoutShutdown {
+		t.Errorf("Expected ErrExitWithoutShutdown. Got %v", err)
+	}
+}
+
+func TestRequestsBeforeInitialize(t *testing.T) {
+	results := session(t, call(1, "textDocument/hover", at(0, 0)), call(2, "shutdown", nil))
+	if string(results["1"]) != `"error: the server has not been initialized"` {
+		t.Errorf("Expected an error before initialize. Got %s", results["1"])
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"let x = 1;\nx", []string{}},
+		{"let x 5;", []string{"0:6-0:7 error syntax: expected next token to be =, received INT"}},
+		{"let x = 1;\nlet y = \"😀\" + z;", []string{
+			"0:4-0:5 warning unused: variable x is never used",
+			"1:4-1:5 warning unused: variable y is never used",
+			"1:15-1:16 error undefined: identifier not found: z",
+		}},
+		{"let x: int = \"a\";\nx", []string{"0:13-0:16 error type: cannot use string as int in let x"}},
+		{"let s = \"abc", []string{"0:8-0:12 error syntax: unterminated string"}},
+	}
+
+	for _, tt := range tests {
+		params := PublishDiagnosticsParams{}
+		decode(t, withDocument(t, tt.text)["textDocument/publishDiagnostics"], &params)
+		got := []string{}
+		for _, d := range params.Diagnostics {
+			severity := map[int]string{SeverityError: "error", SeverityWarning: "warning"}[d.Severity]
+			got = append(got, formatRange(d.Range)+" "+severity+" "+d.Code+": "+d.Message)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Wrong diagnostics for %q.\nExpected: %q\nGot:      %q", tt.text, tt.expected, got)
+		}
+	}
+}
+
+func formatRange(r Range) string {
+	return strings.Join([]string{
+		strconv.Itoa(r.Start.Line) + ":" + strconv.Itoa(r.Start.Character),
+		strconv.Itoa(r.End.Line) + ":" + strconv.Itoa(r.End.Character),
+	}, "-")
+}
+
+const program = `let add = fn(a: int, b = 1) -> int { a + b };
+fn twice(f, x) {
+  let once = f(x);
+  f(once)
+}
+let [first, ...others] = [1, 2];
+twice(fn(n) { add(n) }, first);
+`
+
+func TestDocumentSymbols(t *testing.T) {
+	symbols := []DocumentSymbol{}
+	decode(t, withDocument(t, program, call(1, "textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}))["1"], &symbols)
+
+	got := []string{}
+	var describe func(symbols []DocumentSymbol, indent string)
+	describe = func(symbols []DocumentSymbol, indent string) {
+		for _, s := range symbols {
+			got = append(got, indent+s.Name+" "+s.Detail+" "+formatRange(s.Range)+" "+formatRange(s.SelectionRange))
+			describe(s.Children, indent+"  ")
+		}
+	}
+	describe(symbols, "")
+	expected := []string{
+		"add fn(a: int, b = 1) -> int 0:0-0:45 0:4-0:7",
+		"twice fn(f, x) 1:0-4:1 1:3-1:8",
+		"  once  2:2-2:18 2:6-2:10",
+		"first  5:0-5:32 5:5-5:10",
+		"others  5:0-5:32 5:15-5:21",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong symbols.\nExpected: %q\nGot:      %q", expected, got)
+	}
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	referenceParams := ReferenceParams{TextDocumentPositionParams: at(6, 15)}
+	referenceParams.Context.IncludeDeclaration = true
+	results := withDocument(t, program,
+		// `add` in the call on the last line
+		call(1, "textDocument/definition", at(6, 15)),
+		call(2, "textDocument/references", referenceParams),
+		// `f` inside of twice
+		call(3, "textDocument/references", ReferenceParams{TextDocumentPositionParams: at(1, 9)}),
+		// builtins don't have a definition
+		call(4, "textDocument/definition", at(0, 100)),
+	)
+
+	tests := []struct {
+		id       string
+		expected []string
+	}{
+		{"1", []string{"0:4-0:7"}},
+		{"2", []string{"0:4-0:7", "6:14-6:17"}},
+		{"3", []string{"2:13-2:14", "3:2-3:3"}},
+		{"4", []string{}},
+	}
+	for _, tt := range tests {
+		locations := []Location{}
+		decode(t, results[tt.id], &locations)
+		got := []string{}
+		for _, location := range locations {
+			got = append(got, formatRange(location.Range))
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Request %s wrong. Expected %q. Got %q", tt.id, tt.expected, got)
+		}
+	}
+}
+
+// Documents are mostly broken while they're being edited. Resolution
+// should still work for the parts that parsed.
+func TestBrokenDocument(t *testing.T) {
+	results := withDocument(t, "let x = 1;\nlet = 2;\nlet y = fn(a) { a +",
+		call(1, "textDocument/definition", at(2, 16)),
+	)
+	locations := []Location{}
+	decode(t, results["1"], &locations)
+	if len(locations) != 1 || formatRange(locations[0].Range) != "2:11-2:12" {
+		t.Errorf("Expected the definition of a at 2:11-2:12. Got %s", results["1"])
+	}
+}
+
+func TestHover(t *testing.T) {
+	results := withDocument(t, program+"len(others);",
+		call(1, "textDocument/hover", at(6, 15)),
+		call(2, "textDocument/hover", at(7, 1)),
+		call(3, "textDocument/hover", at(2, 7)),
+		call(4, "textDocument/hover", at(1, 12)),
+	)
+	tests := []struct {
+		id       string
+		expected string
+	}{
+		{"1", "```monkey\nfn add(a: int, b = 1) -> int\n```"},
+		{"2", "```monkey\nlen(value)\n```\n\nReturns the length of a string or array."},
+		{"3", "```monkey\nlet once\n```"},
+		{"4", "```monkey\nparameter x\n```"},
+	}
+	for _, tt := range tests {
+		hover := Hover{}
+		decode(t, results[tt.id], &hover)
+		if hover.Contents.Value != tt.expected {
+			t.Errorf("Hover %s wrong. Expected %q. Got %q", tt.id, tt.expected, hover.Contents.Value)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	results := withDocument(t, program,
+		// inside twice
+		call(1, "textDocument/completion", at(3, 2)),
+		// top level
+		call(2, "textDocument/completion", at(7, 0)),
+	)
+	labels := func(raw json.RawMessage) []string {
+		items := []CompletionItem{}
+		decode(t, raw, &items)
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	// every builtin but `first`, which is hidden by a variable
+	builtins := []string{}
+	for _, name := range evaluator.BuiltinNames() {
+		if name != "first" {
+			builtins = append(builtins, name)
+		}
+	}
+	// twice can see its own name
+	expected := append([]string{"f", "x", "once", "twice", "add", "first", "others"}, builtins...)
+	if got := labels(results["1"]); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong completions in function.\nExpected: %q\nGot:      %q", expected, got)
+	}
+	expected = append([]string{"add", "twice", "first", "others"}, builtins...)
+	if got := labels(results["2"]); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Wrong completions at top level.\nExpected: %q\nGot:      %q", expected, got)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	results := withDocument(t, "let x=1;x",
+		call(1, "textDocument/formatting", DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}),
+	)
+	edits := []TextEdit{}
+	decode(t, results["1"], &edits)
+	if len(edits) != 1 || edits[0].NewText != "let x = 1;\nx;\n" || formatRange(edits[0].Range) != "0:0-0:9" {
+		t.Errorf("Unexpected formatting edits: %s", results["1"])
+	}
+}
+
+func TestPositions(t *testing.T) {
+	doc := newDocument(uri, "a\n\"😀\" + b\n")
+	tests := []struct {
+		offset   int
+		position Position
+	}{
+		{0, Position{0, 0}},
+		{2, Position{1, 0}},
+		// the emoji is 4 bytes in UTF-8 but 2 code units in UTF-16
+		{7, Position{1, 3}},
+		{11, Position{1, 7}},
+		{13, Position{2, 0}},
+	}
+	for _, tt := range tests {
+		if got := doc.position(tt.offset); got != tt.position {
+			t.Errorf("position(%d) wrong. Expected %v. Got %v", tt.offset, tt.position, got)
+		}
+		if got := doc.offset(tt.position); got != tt.offset {
+			t.Errorf("offset(%v) wrong. Expected %d. Got %d", tt.position, tt.offset, got)
+		}
+	}
+}
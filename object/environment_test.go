@@ -0,0 +1,20 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", &Integer{Value: 1})
+	outer.Set("shadowed", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("a", &Integer{Value: 3})
+	inner.Set("shadowed", &Integer{Value: 4})
+
+	expected := []string{"a", "b", "shadowed"}
+	if names := inner.Names(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected names %v. Got %v", expected, names)
+	}
+}
@@ -0,0 +1,121 @@
+package object
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Reports whether two values are the same. Arrays and hashes are equal
+// when they have equal contents (hashes can have their keys in a different
+// order). Functions are only equal to themselves, or to another closure made
+// from the same function literal in the same environment.
+func Equal(a Object, b Object) bool {
+	return equal(a, b, map[[2]Object]bool{})
+}
+
+// comparing holds the pairs of arrays and hashes we're in the middle of
+// comparing. If we get back to one of them, the values contain themselves
+// in the same place, and they're equal unless something else differs.
+func equal(a Object, b Object, comparing map[[2]Object]bool) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Null:
+		return true
+	case *Error:
+		return a.Message == b.(*Error).Message
+	case *Function:
+		b := b.(*Function)
+		return a.Body == b.Body && a.Env == b.Env
+	case *Quote:
+		return a.Node.String() == b.(*Quote).Node.String()
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if comparing[[2]Object{a, b}] {
+			return true
+		}
+		comparing[[2]Object{a, b}] = true
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b := b.(*Hash)
+		if a.Len() != b.Len() {
+			return false
+		}
+		if comparing[[2]Object{a, b}] {
+			return true
+		}
+		comparing[[2]Object{a, b}] = true
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !equal(pair.Value, other, comparing) {
+				return false
+			}
+		}
+		return true
+	}
+	// builtins, macros and anything else are only equal to themselves
+	return false
+}
+
+// Returns obj as a Hashable if it can be used as a hash key. Arrays
+// can only be used when everything in them can.
+func AsHashable(obj Object) (Hashable, bool) {
+	hashable, ok := obj.(Hashable)
+	if !ok || !hashableContents(obj, map[*Array]bool{}) {
+		return nil, false
+	}
+	return hashable, true
+}
+
+func hashableContents(obj Object, visiting map[*Array]bool) bool {
+	array, ok := obj.(*Array)
+	if !ok {
+		_, ok := obj.(Hashable)
+		return ok
+	}
+	// an array that contains itself doesn't have a hash key
+	if visiting[array] {
+		return false
+	}
+	visiting[array] = true
+	defer delete(visiting, array)
+	for _, element := range array.Elements {
+		if !hashableContents(element, visiting) {
+			return false
+		}
+	}
+	return true
+}
+
+// Arrays with the same elements have the same key. Only call this on
+// arrays that AsHashable accepts.
+func (a *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, element := range a.Elements {
+		key := element.(Hashable).HashKey()
+		h.Write([]byte(key.Type))
+		binary.LittleEndian.PutUint64(buf, key.Value)
+		h.Write(buf)
+	}
+	return HashKey{Type: a.Type(), Value: h.Sum64()}
+}
@@ -26,6 +26,9 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
 )
 
 type Object interface {
@@ -34,6 +37,7 @@ type Object interface {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -90,13 +94,20 @@ func (s *String) Type() ObjectType {
 }
 
 func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Value: stringHashFunc(s.Value)}
+}
+
+// Different strings can end up with the same hash. That's very unlikely
+// with 64 bits, so tests swap this out to make it happen on purpose.
+var stringHashFunc = func(s string) uint64 {
 	h := fnv.New64a()
-	h.Write([]byte(s.Value))
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+	h.Write([]byte(s))
+	return h.Sum64()
 }
 
 type Function struct {
-	Parameters []*ast.Identifier
+	Name       string // empty for anonymous functions
+	Parameters []ast.Pattern
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -109,6 +120,9 @@ func (f *Function) Inspect() string {
 		params = append(params, p.String())
 	}
 	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
@@ -118,6 +132,40 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// A piece of unevaluated code. Returned by `quote` and passed to macros
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
 type Builtin struct {
 	Fn BuiltinFunction
 }
@@ -134,18 +182,9 @@ func (a *Array) Type() ObjectType {
 	return ARRAY_OBJ
 }
 
+// Nested strings are quoted. See Pretty.
 func (a *Array) Inspect() string {
-	var out bytes.Buffer
-	elements := []string{}
-	for _, element := range a.Elements {
-		elements = append(elements, element.Inspect())
-	}
-
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
-	return out.String()
+	return Pretty(a, PrettyOptions{})
 }
 
 type HashPair struct {
@@ -153,8 +192,16 @@ type HashPair struct {
 	Value Object
 }
 
+// Hashes remember the order keys were added in, like the keys of a
+// HashLiteral, so they print (and iterate) the same way every time.
+//
+// Lookups go through a map of HashKeys, but two different keys can have
+// the same HashKey, so each HashKey has a bucket of pairs and keys in the
+// same bucket are told apart with Equal.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	buckets map[HashKey][]*HashPair
+	// the same pairs as in buckets, in insertion order
+	pairs []*HashPair
 }
 
 func (h *Hash) Type() ObjectType {
@@ -162,15 +209,74 @@ func (h *Hash) Type() ObjectType {
 }
 
 func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	return Pretty(h, PrettyOptions{})
+}
+
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]*HashPair)}
+}
+
+func (h *Hash) find(key Hashable) *HashPair {
+	for _, pair := range h.buckets[key.HashKey()] {
+		if Equal(pair.Key, key) {
+			return pair
+		}
 	}
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-	return out.String()
+	return nil
+}
+
+// Setting a key that's already there keeps its original position
+func (h *Hash) Set(key Hashable, value Object) {
+	if pair := h.find(key); pair != nil {
+		pair.Value = value
+		return
+	}
+	pair := &HashPair{Key: key, Value: value}
+	hashed := key.HashKey()
+	h.buckets[hashed] = append(h.buckets[hashed], pair)
+	h.pairs = append(h.pairs, pair)
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	if pair := h.find(key); pair != nil {
+		return pair.Value, true
+	}
+	return nil, false
+}
+
+func (h *Hash) Delete(key Hashable) {
+	pair := h.find(key)
+	if pair == nil {
+		return
+	}
+	hashed := key.HashKey()
+	h.buckets[hashed] = without(h.buckets[hashed], pair)
+	if len(h.buckets[hashed]) == 0 {
+		delete(h.buckets, hashed)
+	}
+	h.pairs = without(h.pairs, pair)
+}
+
+func without(pairs []*HashPair, pair *HashPair) []*HashPair {
+	for i, p := range pairs {
+		if p == pair {
+			return append(pairs[:i:i], pairs[i+1:]...)
+		}
+	}
+	return pairs
+}
+
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Returns the pairs in the order their keys were added
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.pairs))
+	for _, pair := range h.pairs {
+		pairs = append(pairs, *pair)
+	}
+	return pairs
 }
 
 // It's good to be careful about using null
@@ -197,6 +303,21 @@ func (r *ReturnValue) Inspect() string {
 	return r.Value.Inspect()
 }
 
+// Like ReturnValue, this never escapes the evaluator. It's a call in
+// tail position that hasn't been made yet. See evalTailPosition.
+type TailCall struct {
+	Fn   *Function
+	Args []Object
+}
+
+func (tc *TailCall) Type() ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+func (tc *TailCall) Inspect() string {
+	return "tail call"
+}
+
 type Error struct {
 	Message string
 }
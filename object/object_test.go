@@ -20,3 +20,146 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content should have different hash keys")
 	}
 }
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"c", "a", "b"} {
+		hash.Set(&String{Value: key}, &Integer{Value: int64(len(key))})
+	}
+	hash.Set(&String{Value: "a"}, &Integer{Value: 10})
+	hash.Delete(&String{Value: "c"})
+	hash.Delete(&String{Value: "missing"})
+	hash.Set(&String{Value: "c"}, &Integer{Value: 20})
+
+	if hash.Len() != 3 {
+		t.Fatalf("expected 3 pairs. got=%d", hash.Len())
+	}
+	if got := hash.Inspect(); got != `{"a": 10, "b": 1, "c": 20}` {
+		t.Errorf("expected pairs in insertion order. got=%s", got)
+	}
+	if value, ok := hash.Get(&String{Value: "b"}); !ok || value.Inspect() != "1" {
+		t.Errorf("expected to find b. got=%v", value)
+	}
+	if _, ok := hash.Get(&Integer{Value: 1}); ok {
+		t.Errorf("expected not to find a key that was never set")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{one, &Integer{Value: 1}, true},
+		{one, two, false},
+		{&String{Value: "1"}, one, false},
+		{&Array{Elements: []Object{one, two}}, &Array{Elements: []Object{one, &Integer{Value: 2}}}, true},
+		{&Array{Elements: []Object{one}}, &Array{Elements: []Object{one, two}}, false},
+		{&Null{}, &Null{}, true},
+		{&Builtin{}, &Builtin{}, false},
+	}
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Equal(%s, %s): expected %t. got=%t", tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestEqualCycles(t *testing.T) {
+	selfHash := func() *Hash {
+		hash := NewHash()
+		hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+		hash.Set(&String{Value: "self"}, hash)
+		return hash
+	}
+	if !Equal(selfHash(), selfHash()) {
+		t.Errorf("expected hashes that contain themselves in the same place to be equal")
+	}
+	different := selfHash()
+	different.Set(&String{Value: "a"}, &Integer{Value: 2})
+	if Equal(selfHash(), different) {
+		t.Errorf("expected hashes with different values to be unequal")
+	}
+
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+	if !Equal(array, array) {
+		t.Errorf("expected an array to equal itself")
+	}
+	if _, ok := AsHashable(array); ok {
+		t.Errorf("expected an array that contains itself not to be hashable")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	b := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	c := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("arrays with the same elements should have the same hash key")
+	}
+	if a.HashKey() == c.HashKey() {
+		t.Errorf("arrays with elements in a different order should have different hash keys")
+	}
+	if _, ok := AsHashable(&Array{Elements: []Object{&Function{}}}); ok {
+		t.Errorf("expected an array containing a function not to be hashable")
+	}
+	if _, ok := AsHashable(&Array{Elements: []Object{a, &Boolean{Value: true}}}); !ok {
+		t.Errorf("expected nested arrays of hashable values to be hashable")
+	}
+}
+
+// Makes every string hash to the same value until the test ends
+func forceStringCollisions(t *testing.T) {
+	original := stringHashFunc
+	stringHashFunc = func(string) uint64 { return 42 }
+	t.Cleanup(func() { stringHashFunc = original })
+}
+
+func TestHashKeyCollisions(t *testing.T) {
+	forceStringCollisions(t)
+	a, b, c := &String{Value: "a"}, &String{Value: "b"}, &String{Value: "c"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("expected the test hook to make hash keys collide")
+	}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(c, &Integer{Value: 3})
+	hash.Set(b, &Integer{Value: 20})
+	if got := hash.Inspect(); got != `{"a": 1, "b": 20, "c": 3}` {
+		t.Errorf("expected colliding keys to stay separate. got=%s", got)
+	}
+	if value, ok := hash.Get(&String{Value: "c"}); !ok || value.Inspect() != "3" {
+		t.Errorf("expected to find c. got=%v", value)
+	}
+	if _, ok := hash.Get(&String{Value: "d"}); ok {
+		t.Errorf("expected a key with the same hash that was never set not to be found")
+	}
+
+	hash.Delete(b)
+	if _, ok := hash.Get(b); ok {
+		t.Errorf("expected b to be deleted")
+	}
+	if got := hash.Inspect(); got != `{"a": 1, "c": 3}` {
+		t.Errorf("expected deleting b to leave the other colliding keys. got=%s", got)
+	}
+
+	// equality has to look at the keys too, not just how many there are
+	other := NewHash()
+	other.Set(a, &Integer{Value: 1})
+	other.Set(&String{Value: "d"}, &Integer{Value: 3})
+	if Equal(hash, other) {
+		t.Errorf("expected hashes with different colliding keys to be unequal")
+	}
+
+	// arrays of strings are hashed through their elements
+	arrays := NewHash()
+	arrays.Set(&Array{Elements: []Object{a}}, &Integer{Value: 1})
+	arrays.Set(&Array{Elements: []Object{b}}, &Integer{Value: 2})
+	if arrays.Len() != 2 {
+		t.Errorf("expected colliding array keys to stay separate. got=%s", arrays.Inspect())
+	}
+}
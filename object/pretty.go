@@ -0,0 +1,144 @@
+package object
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type PrettyOptions struct {
+	// Arrays and hashes that don't fit in this many columns are split
+	// over several lines. 0 keeps everything on one line.
+	Width int
+	// Added in front of each element for every level of nesting
+	// when a value is split over several lines
+	Indent string
+	// Only this many elements of an array or hash are shown, followed by
+	// a count of the rest. 0 shows everything.
+	MaxItems int
+}
+
+// What the repl uses
+var DefaultPrettyOptions = PrettyOptions{Width: 80, Indent: "  ", MaxItems: 100}
+
+// Formats a value for people to read. Unlike Inspect, strings are quoted so
+// `"1"` and `1` look different. Values that contain themselves print `[...]`
+// or `{...}` where they would have started over, instead of recursing forever.
+func Pretty(obj Object, options PrettyOptions) string {
+	p := &prettyPrinter{options: options, visiting: map[Object]bool{}}
+	return p.print(obj, "", 0)
+}
+
+type prettyPrinter struct {
+	options PrettyOptions
+	// Arrays and hashes we're in the middle of printing. Seeing
+	// one of these again means there's a cycle.
+	visiting map[Object]bool
+}
+
+// Prints obj starting at column, with indent being the indentation
+// of the line it starts on
+func (p *prettyPrinter) print(obj Object, indent string, column int) string {
+	flat := p.flat(obj)
+	if p.options.Width <= 0 || column+utf8.RuneCountInString(flat) <= p.options.Width {
+		return flat
+	}
+
+	switch obj := obj.(type) {
+	case *Array:
+		if len(obj.Elements) == 0 || p.visiting[obj] {
+			return flat
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		inner := indent + p.options.Indent
+		lines := []string{}
+		shown, hidden := p.limit(len(obj.Elements))
+		for _, element := range obj.Elements[:shown] {
+			lines = append(lines, inner+p.print(element, inner, utf8.RuneCountInString(inner)))
+		}
+		if hidden > 0 {
+			lines = append(lines, inner+more(hidden))
+		}
+		return "[\n" + strings.Join(lines, ",\n") + "\n" + indent + "]"
+	case *Hash:
+		if obj.Len() == 0 || p.visiting[obj] {
+			return flat
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		inner := indent + p.options.Indent
+		lines := []string{}
+		pairs := obj.Pairs()
+		shown, hidden := p.limit(len(pairs))
+		for _, pair := range pairs[:shown] {
+			key := p.flat(pair.Key) + ": "
+			column := utf8.RuneCountInString(inner + key)
+			lines = append(lines, inner+key+p.print(pair.Value, inner, column))
+		}
+		if hidden > 0 {
+			lines = append(lines, inner+more(hidden))
+		}
+		return "{\n" + strings.Join(lines, ",\n") + "\n" + indent + "}"
+	}
+	return flat
+}
+
+// Prints obj on one line
+func (p *prettyPrinter) flat(obj Object) string {
+	switch obj := obj.(type) {
+	case *String:
+		return strconv.Quote(obj.Value)
+	case *Array:
+		if p.visiting[obj] {
+			return "[...]"
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		elements := []string{}
+		shown, hidden := p.limit(len(obj.Elements))
+		for _, element := range obj.Elements[:shown] {
+			elements = append(elements, p.flat(element))
+		}
+		if hidden > 0 {
+			elements = append(elements, more(hidden))
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *Hash:
+		if p.visiting[obj] {
+			return "{...}"
+		}
+		p.visiting[obj] = true
+		defer delete(p.visiting, obj)
+
+		pairs := []string{}
+		all := obj.Pairs()
+		shown, hidden := p.limit(len(all))
+		for _, pair := range all[:shown] {
+			pairs = append(pairs, p.flat(pair.Key)+": "+p.flat(pair.Value))
+		}
+		if hidden > 0 {
+			pairs = append(pairs, more(hidden))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case nil:
+		return "null"
+	}
+	return obj.Inspect()
+}
+
+// Returns how many of count elements to show and how many are left out
+func (p *prettyPrinter) limit(count int) (shown int, hidden int) {
+	if p.options.MaxItems > 0 && count > p.options.MaxItems {
+		return p.options.MaxItems, count - p.options.MaxItems
+	}
+	return count, 0
+}
+
+func more(hidden int) string {
+	return fmt.Sprintf("... %d more", hidden)
+}
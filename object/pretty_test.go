@@ -0,0 +1,99 @@
+package object
+
+import (
+	"strings"
+	"testing"
+)
+
+func ints(values ...int64) []Object {
+	objects := []Object{}
+	for _, value := range values {
+		objects = append(objects, &Integer{Value: value})
+	}
+	return objects
+}
+
+func hashOf(pairs ...Object) *Hash {
+	hash := NewHash()
+	for i := 0; i < len(pairs); i += 2 {
+		hash.Set(pairs[i].(Hashable), pairs[i+1])
+	}
+	return hash
+}
+
+func TestPrettyOneLine(t *testing.T) {
+	tests := []struct {
+		value    Object
+		expected string
+	}{
+		{&String{Value: "hi"}, `"hi"`},
+		{&String{Value: "say \"hi\"\n"}, `"say \"hi\"\n"`},
+		{&Array{Elements: []Object{&String{Value: "1"}, &Integer{Value: 1}}}, `["1", 1]`},
+		{&Array{Elements: []Object{}}, "[]"},
+		{hashOf(&String{Value: "b"}, &Boolean{Value: true}, &String{Value: "a"}, &Null{}), `{"b": true, "a": null}`},
+		{hashOf(&Integer{Value: 1}, &Array{Elements: ints(1, 2)}), "{1: [1, 2]}"},
+		{&Error{Message: "oops"}, "Error: oops"},
+	}
+	for _, tt := range tests {
+		if got := Pretty(tt.value, DefaultPrettyOptions); got != tt.expected {
+			t.Errorf("expected %s. got=%s", tt.expected, got)
+		}
+	}
+}
+
+func TestPrettyWraps(t *testing.T) {
+	value := hashOf(
+		&String{Value: "name"}, &String{Value: "Monkey"},
+		&String{Value: "scores"}, &Array{Elements: ints(100, 200, 300, 400)},
+	)
+	expected := strings.Join([]string{
+		"{",
+		`  "name": "Monkey",`,
+		`  "scores": [`,
+		"    100,",
+		"    200,",
+		"    300,",
+		"    400",
+		"  ]",
+		"}",
+	}, "\n")
+	got := Pretty(value, PrettyOptions{Width: 20, Indent: "  "})
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestPrettyTruncates(t *testing.T) {
+	value := &Array{Elements: ints(1, 2, 3, 4, 5)}
+	if got := Pretty(value, PrettyOptions{MaxItems: 2}); got != "[1, 2, ... 3 more]" {
+		t.Errorf("expected the array to be cut short. got=%s", got)
+	}
+	expected := "[\n  1,\n  2,\n  ... 3 more\n]"
+	if got := Pretty(value, PrettyOptions{Width: 10, Indent: "  ", MaxItems: 2}); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestPrettyCycles(t *testing.T) {
+	array := &Array{Elements: ints(1)}
+	array.Elements = append(array.Elements, array)
+	if got := array.Inspect(); got != "[1, [...]]" {
+		t.Errorf("expected the cycle to be cut off. got=%s", got)
+	}
+
+	hash := hashOf(&String{Value: "a"}, &Integer{Value: 1})
+	hash.Set(&String{Value: "self"}, hash)
+	if got := hash.Inspect(); got != `{"a": 1, "self": {...}}` {
+		t.Errorf("expected the cycle to be cut off. got=%s", got)
+	}
+	if got := Pretty(hash, PrettyOptions{Width: 5, Indent: "  "}); got != "{\n  \"a\": 1,\n  \"self\": {...}\n}" {
+		t.Errorf("expected the cycle to be cut off when wrapping. got=%s", got)
+	}
+
+	// the same value twice isn't a cycle
+	shared := &Array{Elements: ints(1)}
+	twice := &Array{Elements: []Object{shared, shared}}
+	if got := twice.Inspect(); got != "[[1], [1]]" {
+		t.Errorf("expected shared values to be printed twice. got=%s", got)
+	}
+}
@@ -0,0 +1,371 @@
+// Package optimize rewrites ASTs into faster ASTs that evaluate to the same
+// thing. It works on the AST alone so it can be used before the tree
+// walking evaluator (or a compiler, if this ever gets one).
+//
+// Passes run bottom up in a single ast.Modify, so the result of one pass
+// can feed another (e.g. `len("abc") * 2` is inlined and then folded).
+package optimize
+
+import (
+	"monkey-pl/ast"
+	"monkey-pl/lint"
+	"monkey-pl/token"
+	"strconv"
+	"strings"
+)
+
+type Pass int
+
+const (
+	// Evaluates operators whose operands are literals (`60 * 60` => `3600`)
+	FoldConstants Pass = 1 << iota
+	// Replaces an `if` whose condition is a literal with the branch that runs
+	EliminateDeadBranches
+	// Evaluates calls to side effect free builtins with literal arguments
+	// (`len("abc")` => `3`) when the builtin hasn't been shadowed
+	InlineBuiltins
+
+	AllPasses = FoldConstants | EliminateDeadBranches | InlineBuiltins
+)
+
+// Runs every pass over program. The program is changed in place.
+//
+// Whether a call refers to a builtin is worked out from the program alone,
+// so this assumes the program runs in an environment where builtins haven't
+// been rebound (which isn't true for later lines in the repl).
+func Optimize(program *ast.Program) *ast.Program {
+	return Run(program, AllPasses)
+}
+
+// Runs the given passes over program. The program is changed in place.
+func Run(program *ast.Program, passes Pass) *ast.Program {
+	o := &optimizer{passes: passes, skip: quotedNodes(program)}
+	if passes&InlineBuiltins != 0 {
+		o.resolution = lint.Resolve(program)
+	}
+	optimized, _ := ast.Modify(program, o.modify).(*ast.Program)
+	return optimized
+}
+
+type optimizer struct {
+	passes Pass
+	// Nodes that have to be left alone
+	skip       map[ast.Node]bool
+	resolution *lint.Resolution
+}
+
+// Quoted code is turned into a value instead of being run, so optimizing
+// it would change the result (`quote(1 + 2)` is not `quote(3)`). Macro
+// bodies are skipped too since they are mostly quoted code.
+func quotedNodes(program *ast.Program) map[ast.Node]bool {
+	skip := map[ast.Node]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			if node.Function.TokenLiteral() != "quote" {
+				return true
+			}
+		case *ast.MacroLiteral:
+		default:
+			return true
+		}
+		ast.Inspect(node, func(child ast.Node) bool {
+			if child != nil && child != node {
+				skip[child] = true
+			}
+			return true
+		})
+		return false
+	})
+	return skip
+}
+
+func (o *optimizer) enabled(pass Pass) bool {
+	return o.passes&pass != 0
+}
+
+func (o *optimizer) modify(node ast.Node) ast.Node {
+	if o.skip[node] {
+		return node
+	}
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		if o.enabled(FoldConstants) {
+			return foldPrefix(node)
+		}
+	case *ast.InfixExpression:
+		if o.enabled(FoldConstants) {
+			return foldInfix(node)
+		}
+	case *ast.CallExpression:
+		if o.enabled(InlineBuiltins) {
+			return o.inlineBuiltin(node)
+		}
+	case *ast.IfExpression:
+		if o.enabled(EliminateDeadBranches) {
+			return eliminateIf(node)
+		}
+	case *ast.BlockStatement:
+		if o.enabled(EliminateDeadBranches) {
+			node.Statements = spliceBranches(node.Statements)
+		}
+	case *ast.Program:
+		if o.enabled(EliminateDeadBranches) {
+			node.Statements = spliceBranches(node.Statements)
+		}
+	}
+	return node
+}
+
+func integerLiteral(at token.Token, value int64) *ast.IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{Token: literalToken(at, token.INT, literal), Value: value}
+}
+
+func stringLiteral(at token.Token, value string) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: literalToken(at, token.STRING, value), Value: value}
+}
+
+func booleanLiteral(at token.Token, value bool) *ast.BooleanLiteral {
+	if value {
+		return &ast.BooleanLiteral{Token: literalToken(at, token.TRUE, "true"), Value: true}
+	}
+	return &ast.BooleanLiteral{Token: literalToken(at, token.FALSE, "false"), Value: false}
+}
+
+// New literals keep the position of the expression they replace
+func literalToken(at token.Token, tokenType token.TokenType, literal string) token.Token {
+	return token.Token{Type: tokenType, Literal: literal, Line: at.Line, Column: at.Column, Offset: at.Offset}
+}
+
+func isLiteral(expression ast.Expression) bool {
+	switch expression.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.BooleanLiteral:
+		return true
+	}
+	return false
+}
+
+// These follow evalPrefixExpression. Anything that would be an error at
+// runtime is left as it is so the error still happens.
+func foldPrefix(node *ast.PrefixExpression) ast.Expression {
+	switch right := node.Right.(type) {
+	case *ast.BooleanLiteral:
+		if node.Operator == "!" {
+			return booleanLiteral(node.Token, !right.Value)
+		}
+	case *ast.IntegerLiteral:
+		switch node.Operator {
+		case "!":
+			// only false and null are falsy
+			return booleanLiteral(node.Token, false)
+		case "-":
+			return integerLiteral(node.Token, -right.Value)
+		}
+	case *ast.StringLiteral:
+		switch node.Operator {
+		case "!":
+			return booleanLiteral(node.Token, false)
+		case "-":
+			return stringLiteral(node.Token, reversed(right.Value))
+		}
+	}
+	return node
+}
+
+func reversed(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// These follow evalInfixExpression
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	if !isLiteral(node.Left) || !isLiteral(node.Right) {
+		return node
+	}
+	at := node.Token
+	switch left := node.Left.(type) {
+	case *ast.IntegerLiteral:
+		if right, ok := node.Right.(*ast.IntegerLiteral); ok {
+			return foldIntegers(node, left.Value, right.Value)
+		}
+	case *ast.StringLiteral:
+		if right, ok := node.Right.(*ast.StringLiteral); ok {
+			switch node.Operator {
+			case "+":
+				return stringLiteral(at, left.Value+right.Value)
+			case "==":
+				return booleanLiteral(at, left.Value == right.Value)
+			case "!=":
+				return booleanLiteral(at, left.Value != right.Value)
+			case "<":
+				return booleanLiteral(at, strings.Compare(left.Value, right.Value) == -1)
+			case ">":
+				return booleanLiteral(at, strings.Compare(left.Value, right.Value) == 1)
+			}
+			return node
+		}
+	}
+
+	// Booleans are singletons so == compares their values. Values of
+	// different types are never equal.
+	leftBool, leftIsBool := node.Left.(*ast.BooleanLiteral)
+	rightBool, rightIsBool := node.Right.(*ast.BooleanLiteral)
+	equal := leftIsBool && rightIsBool && leftBool.Value == rightBool.Value
+	switch node.Operator {
+	case "==":
+		return booleanLiteral(at, equal)
+	case "!=":
+		return booleanLiteral(at, !equal)
+	}
+	return node
+}
+
+func foldIntegers(node *ast.InfixExpression, left int64, right int64) ast.Expression {
+	at := node.Token
+	switch node.Operator {
+	case "+":
+		return integerLiteral(at, left+right)
+	case "-":
+		return integerLiteral(at, left-right)
+	case "*":
+		return integerLiteral(at, left*right)
+	case "/":
+		// left alone so it's still a divide by zero error at runtime
+		if right == 0 {
+			return node
+		}
+		return integerLiteral(at, left/right)
+	case "<":
+		return booleanLiteral(at, left < right)
+	case ">":
+		return booleanLiteral(at, left > right)
+	case "==":
+		return booleanLiteral(at, left == right)
+	case "!=":
+		return booleanLiteral(at, left != right)
+	}
+	return node
+}
+
+// Returns whether a literal condition is truthy. ok is false when the
+// condition isn't a literal.
+func literalCondition(condition ast.Expression) (truthy bool, ok bool) {
+	switch condition := condition.(type) {
+	case *ast.BooleanLiteral:
+		return condition.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+// An if with a literal condition can be replaced by its branch when the
+// branch is a single expression. Bigger branches are handled by
+// spliceBranches when the if is used as a statement.
+func eliminateIf(node *ast.IfExpression) ast.Expression {
+	truthy, ok := literalCondition(node.Condition)
+	if !ok {
+		return node
+	}
+	branch := node.Alternative
+	if truthy {
+		branch = node.Consequence
+	}
+	if branch == nil || len(branch.Statements) != 1 {
+		return node
+	}
+	if statement, ok := branch.Statements[0].(*ast.ExpressionStatement); ok && statement.Expression != nil {
+		return statement.Expression
+	}
+	return node
+}
+
+// Blocks don't have their own scope, so an if statement with a literal
+// condition can be replaced by the statements in the branch that runs.
+//
+// The last statement is special because its value is the value of the
+// whole block. An if that runs nothing evaluates to null, and an empty
+// block to nothing at all, so those are only removed when they aren't last.
+func spliceBranches(statements []ast.Statement) []ast.Statement {
+	result := []ast.Statement{}
+	for i, statement := range statements {
+		last := i == len(statements)-1
+		expression, ok := statement.(*ast.ExpressionStatement)
+		if !ok {
+			result = append(result, statement)
+			continue
+		}
+		ifExpression, ok := expression.Expression.(*ast.IfExpression)
+		if !ok {
+			result = append(result, statement)
+			continue
+		}
+		truthy, ok := literalCondition(ifExpression.Condition)
+		if !ok {
+			result = append(result, statement)
+			continue
+		}
+		branch := ifExpression.Alternative
+		if truthy {
+			branch = ifExpression.Consequence
+		}
+		switch {
+		case branch == nil && !last:
+			// runs nothing
+		case branch == nil:
+			result = append(result, statement)
+		case len(branch.Statements) == 0 && last:
+			result = append(result, statement)
+		default:
+			result = append(result, branch.Statements...)
+		}
+	}
+	return result
+}
+
+// Builtins that don't have side effects and only depend on their arguments
+func (o *optimizer) inlineBuiltin(call *ast.CallExpression) ast.Expression {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok || len(call.Arguments) != 1 {
+		return call
+	}
+	binding := o.resolution.Uses[ident]
+	if binding == nil || binding.Kind != lint.BuiltinBinding {
+		return call
+	}
+	// A use is resolved to whatever the name means at that point in the
+	// scope, but in a loop a `let` further down can rebind the name before
+	// the next time round. So we only inline when nothing ever rebinds it.
+	if o.resolution.UseScopes[ident].Lookup(ident.Value).Kind != lint.BuiltinBinding {
+		return call
+	}
+	at := ident.Token
+
+	switch arg := call.Arguments[0].(type) {
+	case *ast.StringLiteral:
+		switch ident.Value {
+		case "len":
+			return integerLiteral(at, int64(len(arg.Value)))
+		case "toUpperCase":
+			return stringLiteral(at, strings.ToUpper(arg.Value))
+		case "toLowerCase":
+			return stringLiteral(at, strings.ToLower(arg.Value))
+		}
+	case *ast.ArrayLiteral:
+		// the elements have to be evaluated for their side effects
+		// (and errors) unless they are all literals
+		for _, element := range arg.Elements {
+			if !isLiteral(element) {
+				return call
+			}
+		}
+		if ident.Value == "len" {
+			return integerLiteral(at, int64(len(arg.Elements)))
+		}
+	}
+	return call
+}
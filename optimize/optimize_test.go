@@ -0,0 +1,117 @@
+package optimize
+
+import (
+	"monkey-pl/evaluator"
+	"monkey-pl/object"
+	"monkey-pl/parser/parsertest"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// constant folding
+		{"60 * 60 * 24", "86400"},
+		{"1 + 2 * 3 - 4 / 2", "5"},
+		{"-(2 + 3)", "-5"},
+		{"!true; !!false; !5; !\"\"", "falsefalsefalsefalse"},
+		{`"foo" + "bar"`, "foobar"},
+		{`-"abc"`, "cba"},
+		{`"a" < "b"; "a" == "a"; 1 > 2; 1 != 2`, "truetruefalsetrue"},
+		{`true == true; true != false; 1 == "1"; true != 1`, "truetruefalsetrue"},
+		{"let x = 2 * 3; x * (1 + 1)", "let x = 6;(x * 2)"},
+		// things that are errors at runtime stay as they are
+		{"1 / 0", "(1 / 0)"},
+		{"10 / (5 - 5)", "(10 / 0)"},
+		{`1 + "a"; -true; true + true`, `(1 + a)(-true)(true + true)`},
+		// dead branches
+		{"let x = if (1 < 2) { 1 } else { 2 };", "let x = 1;"},
+		{"let x = if (false) { 1 };", "let x = iffalse 1;"},
+		{"if (true) { let a = 1; a + 2 } else { 0 }", "let a = 1;(a + 2)"},
+		{"if (false) { print(1) }; 5", "5"},
+		{"5; if (false) { print(1) }", "5iffalse print(1)"},
+		{"let f = fn(n) { if (!false) { return n; } n * 2 };", "let f = fn(n) return n;(n * 2);"},
+		// builtins
+		{`len("hello") * 2`, "10"},
+		{`toUpperCase("a" + "b"); toLowerCase("AB")`, "ABab"},
+		{"len([1, 2, 3]); len([1, x])", "3len([1, x])"},
+		{`let len = fn(x) { 0 }; len("abc")`, `let len = fn(x) 0;len(abc)`},
+		{`let f = fn(len) { len("abc") };`, `let f = fn(len) len(abc);`},
+		// len could be rebound before the call runs again
+		{`while (x) { len("abc"); let len = fn(x) { 0 }; }`, `whilex len(abc)let len = fn(x) 0;`},
+		// quoted code isn't touched
+		{"quote(1 + 2)", "quote((1 + 2))"},
+		{"quote(unquote(1 + 2))", "quote(unquote((1 + 2)))"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parsertest.Parse(t, tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("Optimize(%q) wrong. Expected %q. Got %q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestRunOnlyRunsSelectedPasses(t *testing.T) {
+	tests := []struct {
+		passes   Pass
+		expected string
+	}{
+		{FoldConstants, `iftrue len(abc)`},
+		{InlineBuiltins, `if((1 + 0) == 1) 3`},
+		{FoldConstants | EliminateDeadBranches, `len(abc)`},
+		{AllPasses, `3`},
+	}
+	for _, tt := range tests {
+		program := Run(parsertest.Parse(t, `if (1 + 0 == 1) { len("abc") }`), tt.passes)
+		if program.String() != tt.expected {
+			t.Errorf("Run with passes %b wrong. Expected %q. Got %q", tt.passes, tt.expected, program.String())
+		}
+	}
+}
+
+// Optimizing should never change what a program evaluates to
+func TestOptimizedProgramsEvaluateTheSame(t *testing.T) {
+	inputs := []string{
+		"let seconds = fn(days) { days * 60 * 60 * 24 }; seconds(2)",
+		"let i = 0; let total = 0; while (i < 10) { let total = total + 2 * 3; let i = i + 1; }; total",
+		"1 / 0",
+		"let f = fn() { 10 / (2 - 2) }; f()",
+		`1 + "a"`,
+		"-true",
+		"if (false) { 1 }",
+		"if (true) { }",
+		"5; if (false) { 1 }",
+		"5; if (true) { }",
+		"let f = fn() { if (true) { return 1; } 2 }; f()",
+		"if (true) { let a = 1; }; a",
+		`let f = fn(n) { if ("truthy") { n + len("abc") } else { 0 } }; f(1)`,
+		"fn count(n) { if (n == 0) { return 0; } if (1 > 0) { count(n - 1) } } count(5000)",
+		`let len = fn(x) { 42 }; len("abc")`,
+		`let i = 0; let out = []; while (i < 2) { let out = push(out, len("ab")); let len = fn(x) { 42 }; let i = i + 1; }; out`,
+		`toUpperCase("shout") + -"olleh"`,
+		"quote(1 + 2)",
+		"let x = 3; quote(unquote(x * 2) + 1)",
+		"[1 + 1, 2 * 2][0 + 1]",
+		`{"a" + "b": 1 + 1}["ab"]`,
+		"!(1 < 2) == false",
+		"len([1, 2, x])",
+	}
+
+	for _, input := range inputs {
+		expected := evaluator.Eval(parsertest.Parse(t, input), object.NewEnvironment())
+		optimized := evaluator.Eval(Optimize(parsertest.Parse(t, input)), object.NewEnvironment())
+		if inspect(expected) != inspect(optimized) {
+			t.Errorf("Optimizing %q changed its result. Expected %s. Got %s", input, inspect(expected), inspect(optimized))
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
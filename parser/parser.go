@@ -5,7 +5,9 @@ import (
 	"monkey-pl/ast"
 	"monkey-pl/lexer"
 	"monkey-pl/token"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // This defines order of operations
@@ -34,13 +36,41 @@ var precedences = map[token.TokenType]int{
 	token.LBRACKET: INDEX,
 }
 
+// Returns how tightly an infix operator binds (LOWEST if it isn't one).
+// Tools that print ASTs use this to decide where parentheses are needed.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 type Parser struct {
 	lex            *lexer.Lexer
 	currentToken   token.Token
 	peekToken      token.Token
-	errors         []string
+	errors         []ParseError
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+	// Only used in trivia mode. tokens records every token read
+	// from the lexer and spans records where each statement starts
+	// and ends so that comments can be attached to them.
+	tokens []token.Token
+	spans  []statementSpan
+}
+
+// A syntax error along with the token the parser was
+// looking at when it found the error
+type ParseError struct {
+	Message string
+	Token   token.Token
+}
+
+// start and end are the offsets of the first and last token of a statement
+type statementSpan struct {
+	statement ast.Statement
+	start     int
+	end       int
 }
 
 type (
@@ -49,7 +79,7 @@ type (
 )
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{lex: l, errors: []string{}}
+	p := &Parser{lex: l, errors: []ParseError{}}
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	// Register prefix parsers
@@ -66,6 +96,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.WHILE, p.parseWhileExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	// Register infix parsers
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -85,12 +116,31 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) Errors() []string {
+	messages := []string{}
+	for _, err := range p.errors {
+		messages = append(messages, err.Message)
+	}
+	return messages
+}
+
+// Like Errors but with the position of each error, for
+// tools like editors that need to point at the mistake
+func (p *Parser) ParseErrors() []ParseError {
 	return p.errors
 }
 
+func (p *Parser) addError(tok token.Token, message string) {
+	p.errors = append(p.errors, ParseError{Message: message, Token: tok})
+}
+
 func (p *Parser) nextToken() {
 	p.currentToken = p.peekToken
 	p.peekToken = p.lex.NextToken()
+	// the lexer keeps returning EOF after the end of the input
+	// and we only want to record it once
+	if p.lex.KeepsTrivia() && !(len(p.tokens) > 0 && p.tokens[len(p.tokens)-1].Type == token.EOF) {
+		p.tokens = append(p.tokens, p.peekToken)
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -100,19 +150,155 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 	// advance and handle tokens
 	for p.currentToken.Type != token.EOF {
+		start := p.currentToken
 		statement := p.parseStatement()
+		p.recordSpan(statement, start)
 		program.Statements = append(program.Statements, statement)
 		p.nextToken()
 	}
+
+	if p.lex.KeepsTrivia() {
+		program.Tokens = p.tokens
+		program.Comments = p.attachComments(program)
+	}
 	return program
 }
 
+func (p *Parser) recordSpan(statement ast.Statement, start token.Token) {
+	if p.lex.KeepsTrivia() {
+		p.spans = append(p.spans, statementSpan{statement, start.Offset, p.currentToken.Offset})
+	}
+}
+
+// Attaches each comment to the nearest statement. A comment on its own
+// line goes to the outermost statement starting right after it and a
+// trailing comment goes to the innermost statement ending right before it.
+// Failing that, comments go to the innermost statement they are inside
+// of, and comments outside of every statement go to the program.
+func (p *Parser) attachComments(program *ast.Program) ast.CommentMap {
+	comments := ast.CommentMap{}
+	spans := newSpanIndex(p.spans)
+	for _, c := range p.lex.Comments() {
+		comment := ast.Comment{Text: c.Text, Line: c.Line, Column: c.Column, Offset: c.Offset, OwnLine: c.OwnLine}
+		var node ast.Node
+		if comment.OwnLine {
+			if next, ok := p.tokenAfter(comment.Offset); ok {
+				node = spans.outermostStartingAt(next.Offset)
+			}
+		} else if previous, ok := p.tokenBefore(comment.Offset); ok {
+			node = spans.innermostEndingAt(previous.Offset)
+		}
+		if node == nil {
+			node = spans.innermostContaining(comment.Offset)
+		}
+		if node == nil {
+			node = program
+		}
+		comments[node] = append(comments[node], comment)
+	}
+	return comments
+}
+
+// Tokens are recorded in the order they're read so they're sorted by offset
+func (p *Parser) tokenAfter(offset int) (token.Token, bool) {
+	i := sort.Search(len(p.tokens), func(i int) bool { return p.tokens[i].Offset > offset })
+	if i < len(p.tokens) && p.tokens[i].Type != token.EOF {
+		return p.tokens[i], true
+	}
+	return token.Token{}, false
+}
+
+func (p *Parser) tokenBefore(offset int) (token.Token, bool) {
+	i := sort.Search(len(p.tokens), func(i int) bool { return p.tokens[i].Offset >= offset })
+	if i > 0 {
+		return p.tokens[i-1], true
+	}
+	return token.Token{}, false
+}
+
+// Lets us look up statements by where they start and end without going
+// through every span for every comment
+type spanIndex struct {
+	// sorted by start, with outer statements before the inner statements
+	// that start at the same place
+	sorted []statementSpan
+	// parents[i] is the index in sorted of the innermost span containing
+	// sorted[i], or -1 if there isn't one
+	parents  []int
+	starting map[int]ast.Node
+	ending   map[int]ast.Node
+}
+
+// Spans are recorded when a statement finishes parsing, so inner
+// statements always come before the statements that contain them
+func newSpanIndex(spans []statementSpan) *spanIndex {
+	index := &spanIndex{starting: map[int]ast.Node{}, ending: map[int]ast.Node{}}
+	for i := len(spans) - 1; i >= 0; i-- {
+		index.sorted = append(index.sorted, spans[i])
+	}
+	for _, span := range spans {
+		index.starting[span.start] = span.statement
+		if _, ok := index.ending[span.end]; !ok {
+			index.ending[span.end] = span.statement
+		}
+	}
+	sort.SliceStable(index.sorted, func(i, j int) bool {
+		a, b := index.sorted[i], index.sorted[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		return a.end > b.end
+	})
+	// Statements are either nested or don't overlap at all, so the spans
+	// that are still open when we reach a span are the ones containing it
+	open := []int{}
+	for i, span := range index.sorted {
+		for len(open) > 0 && index.sorted[open[len(open)-1]].end < span.end {
+			open = open[:len(open)-1]
+		}
+		parent := -1
+		if len(open) > 0 {
+			parent = open[len(open)-1]
+		}
+		index.parents = append(index.parents, parent)
+		open = append(open, i)
+	}
+	return index
+}
+
+func (index *spanIndex) outermostStartingAt(offset int) ast.Node {
+	return index.starting[offset]
+}
+
+func (index *spanIndex) innermostEndingAt(offset int) ast.Node {
+	return index.ending[offset]
+}
+
+// The innermost span containing offset has to be the last span starting
+// before it or one of that span's parents
+func (index *spanIndex) innermostContaining(offset int) ast.Node {
+	i := sort.Search(len(index.sorted), func(i int) bool { return index.sorted[i].start >= offset }) - 1
+	for ; i >= 0; i = index.parents[i] {
+		if offset < index.sorted[i].end {
+			return index.sorted[i].statement
+		}
+	}
+	return nil
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.FUNCTION:
+		// `fn(x) { x }(5)` is still an expression statement. Only
+		// `fn name(...)` at the start of a statement is a declaration
+		if p.peekToken.Type == token.IDENT {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -213,6 +399,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
 			return nil
 		}
@@ -242,12 +429,12 @@ func (p *Parser) parseExpressionList(endChar token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	list = append(list, p.parseListElement())
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+		list = append(list, p.parseListElement())
 	}
 
 	if !p.expectPeek(endChar) {
@@ -257,6 +444,19 @@ func (p *Parser) parseExpressionList(endChar token.TokenType) []ast.Expression {
 	return list
 }
 
+// Elements of array literals and call arguments can be spread
+// (e.g. `[...a, ...b]`). Spreading isn't allowed anywhere else
+// so it's handled here instead of as a prefix expression.
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.currentTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+	spread := &ast.SpreadElement{Token: p.currentToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+	return spread
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.currentToken}
 	if !p.expectPeek(token.LPAREN) {
@@ -308,11 +508,26 @@ func (p *Parser) parseWhileExpression() ast.Expression {
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.currentToken}
 
+	// Function literals can optionally be named (e.g. `let f = fn fact(n) {...}`)
+	if p.peekToken.Type == token.IDENT {
+		p.nextToken()
+		lit.Name = p.currentToken.Literal
+	}
+
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
 	lit.Parameters = p.parseFunctionParameters()
 
+	if p.peekToken.Type == token.ARROW {
+		p.nextToken()
+		p.nextToken()
+		lit.ReturnType = p.parseType()
+		if lit.ReturnType == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -322,11 +537,27 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.currentToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	lit.Parameters = p.parseMacroParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// Macro parameters are always plain identifiers. Since macros receive
+// unevaluated code there's nothing sensible for a default or a pattern
+// to do with their arguments.
+func (p *Parser) parseMacroParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
 	if p.peekToken.Type == token.RPAREN {
@@ -334,22 +565,215 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return identifiers
 	}
 
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	identifiers = append(identifiers, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+
+	for p.peekToken.Type == token.COMMA {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		identifiers = append(identifiers, &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return identifiers
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+}
 
-	ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	identifiers = append(identifiers, ident)
+func (p *Parser) parseFunctionParameters() []ast.Pattern {
+	params := []ast.Pattern{}
+
+	if p.peekToken.Type == token.RPAREN {
+		p.nextToken()
+		return params
+	}
+
+	p.nextToken()
+	params = append(params, p.parsePatternElement())
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, ident)
+		params = append(params, p.parsePatternElement())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
-	return identifiers
+
+	p.checkParameterOrder(params)
+	return params
+}
+
+// Parses a single parameter or destructured element: a pattern with an
+// optional default (e.g. `a`, `a = 10`, `[a, b] = pair`) or `...a`
+func (p *Parser) parsePatternElement() ast.Pattern {
+	if p.currentTokenIs(token.ELLIPSIS) {
+		rest := p.parseRestElement()
+		if rest == nil {
+			return nil
+		}
+		return rest
+	}
+
+	target := p.parsePattern()
+	if target == nil {
+		return nil
+	}
+	if ident, ok := target.(*ast.Identifier); ok && !p.parseTypeAnnotation(ident) {
+		return nil
+	}
+	if p.peekToken.Type != token.ASSIGN {
+		return target
+	}
+
+	p.nextToken()
+	pattern := &ast.AssignmentPattern{Token: p.currentToken, Target: target}
+	p.nextToken()
+	pattern.Default = p.parseExpression(LOWEST)
+	return pattern
+}
+
+func (p *Parser) parseRestElement() *ast.RestElement {
+	rest := &ast.RestElement{Token: p.currentToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	rest.Target = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	if !p.parseTypeAnnotation(rest.Target) {
+		return nil
+	}
+	return rest
+}
+
+// Parses something a value can be bound to: an identifier or
+// an array / hash destructuring pattern
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	default:
+		message := fmt.Sprintf("expected an identifier or destructuring pattern, received %s", p.currentToken.Type)
+		p.addError(p.currentToken, message)
+		return nil
+	}
+}
+
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.currentToken, Elements: []ast.Pattern{}}
+	for p.peekToken.Type != token.RBRACKET {
+		p.nextToken()
+		element := p.parsePatternElement()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if _, ok := element.(*ast.RestElement); ok && p.peekToken.Type != token.RBRACKET {
+			p.addError(p.peekToken, "rest element must be the last element of an array pattern")
+			return nil
+		}
+		if p.peekToken.Type != token.RBRACKET && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.currentToken, Properties: []*ast.HashPatternProperty{}}
+	for p.peekToken.Type != token.RBRACE {
+		p.nextToken()
+		if p.currentTokenIs(token.ELLIPSIS) {
+			pattern.Rest = p.parseRestElement()
+			if pattern.Rest == nil {
+				return nil
+			}
+			if p.peekToken.Type != token.RBRACE {
+				p.addError(p.peekToken, "rest element must be the last element of a hash pattern")
+				return nil
+			}
+			continue
+		}
+		property := p.parseHashPatternProperty()
+		if property == nil {
+			return nil
+		}
+		pattern.Properties = append(pattern.Properties, property)
+		if p.peekToken.Type != token.RBRACE && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return pattern
+}
+
+// Properties are either `key: <pattern>` where key is an identifier or
+// string, or the shorthand `name` / `name = <default>`
+func (p *Parser) parseHashPatternProperty() *ast.HashPatternProperty {
+	if !p.currentTokenIs(token.IDENT) && !p.currentTokenIs(token.STRING) {
+		message := fmt.Sprintf("expected hash pattern key to be an identifier or string, received %s", p.currentToken.Type)
+		p.addError(p.currentToken, message)
+		return nil
+	}
+	property := &ast.HashPatternProperty{Token: p.currentToken, Key: p.currentToken.Literal}
+
+	if p.peekToken.Type == token.COLON {
+		p.nextToken()
+		p.nextToken()
+		if p.currentTokenIs(token.ELLIPSIS) {
+			p.addError(p.currentToken, "rest element can not be used as a hash pattern value")
+			return nil
+		}
+		property.Value = p.parsePatternElement()
+	} else if p.currentTokenIs(token.IDENT) {
+		property.Value = p.parsePatternElement()
+	} else {
+		p.peekError(token.COLON)
+		return nil
+	}
+
+	if property.Value == nil {
+		return nil
+	}
+	return property
+}
+
+// Rest parameters have to come last and required parameters can't
+// come after parameters with defaults. Otherwise it would be unclear
+// which parameters the arguments are meant for.
+func (p *Parser) checkParameterOrder(params []ast.Pattern) {
+	seenDefault := false
+	for i, param := range params {
+		switch param.(type) {
+		case *ast.RestElement:
+			if i != len(params)-1 {
+				p.addError(p.currentToken, "rest parameter must be the last parameter")
+			}
+		case *ast.AssignmentPattern:
+			seenDefault = true
+		default:
+			if seenDefault {
+				p.addError(p.currentToken, "parameters without defaults cannot follow parameters with defaults")
+			}
+		}
+	}
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -362,7 +786,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 	if err != nil {
 		message := fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal)
-		p.errors = append(p.errors, message)
+		p.addError(p.currentToken, message)
 		return nil
 	}
 
@@ -378,12 +802,22 @@ func (p *Parser) parseBooleanLiteral() ast.Expression {
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	statement := &ast.LetStatement{Token: p.currentToken}
 
-	if !p.expectPeek(token.IDENT) {
-		return nil
+	if p.peekToken.Type == token.LBRACKET || p.peekToken.Type == token.LBRACE {
+		p.nextToken()
+		statement.Pattern = p.parsePattern()
+		if statement.Pattern == nil {
+			return nil
+		}
+	} else {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		if !p.parseTypeAnnotation(statement.Name) {
+			return nil
+		}
 	}
 
-	statement.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -399,6 +833,107 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return statement
 }
 
+// Parses an optional `: type` after an identifier. Returns false if
+// there was an annotation and it couldn't be parsed.
+func (p *Parser) parseTypeAnnotation(ident *ast.Identifier) bool {
+	if p.peekToken.Type != token.COLON {
+		return true
+	}
+	p.nextToken()
+	p.nextToken()
+	ident.Type = p.parseType()
+	return ident.Type != nil
+}
+
+// Types have their own tiny grammar so they don't go through parseExpression.
+// The current token should be the first token of the type.
+func (p *Parser) parseType() ast.TypeExpr {
+	switch p.currentToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.currentToken, Name: p.currentToken.Literal}
+	case token.LBRACKET:
+		arrayType := &ast.ArrayType{Token: p.currentToken}
+		p.nextToken()
+		arrayType.Element = p.parseType()
+		if arrayType.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return arrayType
+	case token.LBRACE:
+		hashType := &ast.HashType{Token: p.currentToken}
+		p.nextToken()
+		hashType.Key = p.parseType()
+		if hashType.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		hashType.Value = p.parseType()
+		if hashType.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return hashType
+	case token.FUNCTION:
+		return p.parseFunctionType()
+	default:
+		p.addError(p.currentToken, fmt.Sprintf("expected a type, received %s", p.currentToken.Type))
+		return nil
+	}
+}
+
+func (p *Parser) parseFunctionType() ast.TypeExpr {
+	functionType := &ast.FunctionType{Token: p.currentToken, Parameters: []ast.TypeExpr{}}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if p.peekToken.Type != token.RPAREN {
+		p.nextToken()
+		param := p.parseType()
+		if param == nil {
+			return nil
+		}
+		functionType.Parameters = append(functionType.Parameters, param)
+		for p.peekToken.Type == token.COMMA {
+			p.nextToken()
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			functionType.Parameters = append(functionType.Parameters, param)
+		}
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if p.peekToken.Type == token.ARROW {
+		p.nextToken()
+		p.nextToken()
+		functionType.Return = p.parseType()
+		if functionType.Return == nil {
+			return nil
+		}
+	}
+	return functionType
+}
+
+func (p *Parser) parseFunctionStatement() *ast.FunctionStatement {
+	statement := &ast.FunctionStatement{Token: p.currentToken}
+	statement.Name = &ast.Identifier{Token: p.peekToken, Value: p.peekToken.Literal}
+
+	lit, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok || lit == nil {
+		return nil
+	}
+	statement.Function = lit
+
+	if p.peekToken.Type == token.SEMICOLON {
+		p.nextToken()
+	}
+
+	return statement
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	statement := &ast.ReturnStatement{Token: p.currentToken}
 	p.nextToken()
@@ -417,7 +952,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block.Statements = []ast.Statement{}
 	p.nextToken()
 	for !p.currentTokenIs(token.RBRACE) && !p.currentTokenIs(token.EOF) {
+		start := p.currentToken
 		statement := p.parseStatement()
+		p.recordSpan(statement, start)
 		block.Statements = append(block.Statements, statement)
 		p.nextToken()
 	}
@@ -464,7 +1001,7 @@ func (p *Parser) currentPrecedence() int {
 
 func (p *Parser) peekError(t token.TokenType) {
 	message := fmt.Sprintf("expected next token to be %s, received %s", t, p.peekToken.Type)
-	p.errors = append(p.errors, message)
+	p.addError(p.peekToken, message)
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -476,6 +1013,10 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	if t == token.ILLEGAL && strings.HasPrefix(p.currentToken.Literal, `"`) {
+		p.addError(p.currentToken, "unterminated string")
+		return
+	}
 	message := fmt.Sprintf("no prefix parse function for '%s' found", t)
-	p.errors = append(p.errors, message)
+	p.addError(p.currentToken, message)
 }
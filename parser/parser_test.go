@@ -596,6 +596,229 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestDefaultAndRestParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 10) {};", "fn(a, b = 10) "},
+		{"fn(a, ...rest) {};", "fn(a, ...rest) "},
+		{"fn(a = 1 + 2, b = a, ...rest) {};", "fn(a = (1 + 2), b = a, ...rest) "},
+		{"fn(...rest) {};", "fn(...rest) "},
+	}
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		program := pars.ParseProgram()
+		checkForParserErrors(t, pars)
+		statement := program.Statements[0].(*ast.ExpressionStatement)
+		function := statement.Expression.(*ast.FunctionLiteral)
+		if function.String() != tt.expected {
+			t.Errorf("Expected function to be %q. Got %q", tt.expected, function.String())
+		}
+	}
+
+	pars := New(lexer.New("fn(a, b = 2, ...c) {}"))
+	program := pars.ParseProgram()
+	checkForParserErrors(t, pars)
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if !testIdentifier(t, function.Parameters[0], "a") {
+		return
+	}
+	withDefault, ok := function.Parameters[1].(*ast.AssignmentPattern)
+	if !ok {
+		t.Fatalf("Expected second parameter to be an AssignmentPattern. Got %T", function.Parameters[1])
+	}
+	testIdentifier(t, withDefault.Target, "b")
+	testIntegerLiteral(t, withDefault.Default, 2)
+	rest, ok := function.Parameters[2].(*ast.RestElement)
+	if !ok {
+		t.Fatalf("Expected third parameter to be a RestElement. Got %T", function.Parameters[2])
+	}
+	testIdentifier(t, rest.Target, "c")
+}
+
+func TestInvalidParameterOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...rest, a) {}", "rest parameter must be the last parameter"},
+		{"fn(a = 1, b) {}", "parameters without defaults cannot follow parameters with defaults"},
+	}
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		pars.ParseProgram()
+		errors := pars.Errors()
+		if len(errors) != 1 {
+			t.Errorf("Expected 1 parser error for %q. Got %d (%v)", tt.input, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Expected parser error %q. Got %q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestSpreadElements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[...a, ...b]", "[...a, ...b]"},
+		{"[1, ...[2, 3]]", "[1, ...[2, 3]]"},
+		{"f(...args)", "f(...args)"},
+		{"f(1, ...rest(xs))", "f(1, ...rest(xs))"},
+	}
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		program := pars.ParseProgram()
+		checkForParserErrors(t, pars)
+		if program.String() != tt.expected {
+			t.Errorf("Expected %q. Got %q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = arr;", "let [a, b] = arr;"},
+		{"let [a, b = 2, ...rest] = arr;", "let [a, b = 2, ...rest] = arr;"},
+		{"let [[a, b], c] = arr;", "let [[a, b], c] = arr;"},
+		{"let [] = arr;", "let [] = arr;"},
+		{"let {name, age: years} = person;", "let {name, age: years} = person;"},
+		{`let {"full name": name = "?", ...others} = person;`, `let {"full name": name = ?, ...others} = person;`},
+		{"let {name = 1, pets: [first]} = person;", "let {name = 1, pets: [first]} = person;"},
+	}
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		program := pars.ParseProgram()
+		checkForParserErrors(t, pars)
+		statement, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("Expected statement to be a LetStatement. Got %T", program.Statements[0])
+		}
+		if statement.Name != nil {
+			t.Errorf("Expected destructuring let to have no Name. Got %q", statement.Name.Value)
+		}
+		if statement.String() != tt.expected {
+			t.Errorf("Expected %q. Got %q", tt.expected, statement.String())
+		}
+	}
+
+	pars := New(lexer.New("let {name, age: years = 3} = person;"))
+	program := pars.ParseProgram()
+	checkForParserErrors(t, pars)
+	pattern, ok := program.Statements[0].(*ast.LetStatement).Pattern.(*ast.HashPattern)
+	if !ok {
+		t.Fatalf("Expected pattern to be a HashPattern. Got %T", program.Statements[0].(*ast.LetStatement).Pattern)
+	}
+	if len(pattern.Properties) != 2 {
+		t.Fatalf("Expected 2 properties. Got %d", len(pattern.Properties))
+	}
+	if pattern.Properties[1].Key != "age" {
+		t.Errorf("Expected second key to be 'age'. Got %q", pattern.Properties[1].Key)
+	}
+	withDefault, ok := pattern.Properties[1].Value.(*ast.AssignmentPattern)
+	if !ok {
+		t.Fatalf("Expected property value to be an AssignmentPattern. Got %T", pattern.Properties[1].Value)
+	}
+	testIdentifier(t, withDefault.Target, "years")
+	testIntegerLiteral(t, withDefault.Default, 3)
+}
+
+func TestDestructuringParameters(t *testing.T) {
+	input := "fn([a, b], {name} = {}, ...rest) {}"
+	pars := New(lexer.New(input))
+	program := pars.ParseProgram()
+	checkForParserErrors(t, pars)
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.Parameters) != 3 {
+		t.Fatalf("Expected 3 parameters. Got %d", len(function.Parameters))
+	}
+	if _, ok := function.Parameters[0].(*ast.ArrayPattern); !ok {
+		t.Errorf("Expected first parameter to be an ArrayPattern. Got %T", function.Parameters[0])
+	}
+	if function.String() != "fn([a, b], {name} = {}, ...rest) " {
+		t.Errorf("Unexpected String() output. Got %q", function.String())
+	}
+}
+
+func TestInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [...a, b] = arr;", "rest element must be the last element of an array pattern"},
+		{"let {...a, b} = h;", "rest element must be the last element of a hash pattern"},
+		{"let [1] = arr;", "expected an identifier or destructuring pattern, received INT"},
+		{`let {"a"} = h;`, "expected next token to be :, received }"},
+	}
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		pars.ParseProgram()
+		errors := pars.Errors()
+		if len(errors) == 0 {
+			t.Errorf("Expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Expected parser error %q. Got %q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestFunctionStatement(t *testing.T) {
+	input := "fn add(x, y) { x + y; }"
+	pars := New(lexer.New(input))
+	program := pars.ParseProgram()
+	checkForParserErrors(t, pars)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected program to have 1 statement. Got %d", len(program.Statements))
+	}
+
+	statement, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("Expected statement to be of type *ast.FunctionStatement. Got %T", program.Statements[0])
+	}
+
+	if !testIdentifier(t, statement.Name, "add") {
+		return
+	}
+
+	if statement.Function.Name != "add" {
+		t.Errorf("Expected function literal to be named 'add'. Got %q", statement.Function.Name)
+	}
+
+	if len(statement.Function.Parameters) != 2 {
+		t.Fatalf("Expected function to have 2 parameters. Got %d", len(statement.Function.Parameters))
+	}
+
+	if statement.String() != "fn add(x, y) (x + y)" {
+		t.Errorf("Unexpected String() output. Got %q", statement.String())
+	}
+}
+
+func TestNamedFunctionLiteral(t *testing.T) {
+	input := "let f = fn fact(n) { n };"
+	pars := New(lexer.New(input))
+	program := pars.ParseProgram()
+	checkForParserErrors(t, pars)
+
+	statement := program.Statements[0].(*ast.LetStatement)
+	function, ok := statement.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("Expected let value to be of type *ast.FunctionLiteral. Got %T", statement.Value)
+	}
+
+	if function.Name != "fact" {
+		t.Errorf("Expected function literal to be named 'fact'. Got %q", function.Name)
+	}
+}
+
 func TestCallExpression(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 	pars := New(lexer.New(input))
@@ -851,3 +1074,157 @@ func testInfixExpression(t *testing.T, expr ast.Expression, left interface{}, op
 	}
 	return true
 }
+
+func TestTriviaRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"let x = 5;",
+		"# only a comment",
+		"  let   x=5 ;   # five  \n\n\n\tx\n",
+		"let add = fn(a, b) { # adds\r\n  a + b # the sum\r\n};\r\nputs(\"hi there\", [1,2], {\"a\": 1})\n# bye\n",
+		"fn f(a, b = 1, ...rest) {\n  let [c, ...d] = rest;\n  if (a) { c } else { d }\n}",
+	}
+
+	for _, input := range inputs {
+		lex := lexer.NewWithTrivia(input)
+		pars := New(lex)
+		program := pars.ParseProgram()
+		checkForParserErrors(t, pars)
+
+		if program.Source() != input {
+			t.Errorf("Round trip failed. Expected %q. Got %q", input, program.Source())
+		}
+	}
+
+	// Source with errors should still round trip
+	for _, input := range []string{`let s = "abc`, `puts("hi`, "let = 5;"} {
+		program := New(lexer.NewWithTrivia(input)).ParseProgram()
+		if program.Source() != input {
+			t.Errorf("Round trip failed. Expected %q. Got %q", input, program.Source())
+		}
+	}
+
+	program := New(lexer.New("let x = 5; # five")).ParseProgram()
+	if program.Tokens != nil || program.Comments != nil {
+		t.Errorf("Expected no tokens or comments outside of trivia mode")
+	}
+}
+
+func TestCommentAttachment(t *testing.T) {
+	input := `# about x
+let x = 5; # five
+let f = fn() {
+  # inside
+  x # trailing x
+  # end of block
+};
+# end of file`
+
+	lex := lexer.NewWithTrivia(input)
+	pars := New(lex)
+	program := pars.ParseProgram()
+	checkForParserErrors(t, pars)
+
+	letX := program.Statements[0]
+	letF := program.Statements[1].(*ast.LetStatement)
+	body := letF.Value.(*ast.FunctionLiteral).Body
+	x := body.Statements[0]
+
+	tests := []struct {
+		node     ast.Node
+		expected []string
+	}{
+		{letX, []string{"# about x", "# five"}},
+		{letF, []string{"# end of block"}},
+		{x, []string{"# inside", "# trailing x"}},
+		{program, []string{"# end of file"}},
+	}
+
+	for _, tt := range tests {
+		comments := program.Comments[tt.node]
+		if len(comments) != len(tt.expected) {
+			t.Errorf("Expected %d comments on %q. Got %+v", len(tt.expected), tt.node.String(), comments)
+			continue
+		}
+		for i, comment := range comments {
+			if comment.Text != tt.expected[i] {
+				t.Errorf("Expected comment %q on %q. Got %q", tt.expected[i], tt.node.String(), comment.Text)
+			}
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1;", "let x: int = 1;"},
+		{"let xs: [string] = [];", "let xs: [string] = [];"},
+		{"let h: {string: [int]} = {};", "let h: {string: [int]} = {};"},
+		{"let f: fn(int, bool) -> fn() = g;", "let f: fn(int, bool) -> fn() = g;"},
+		{"fn(a: string, b: [int] = [1], ...c: [any]) -> bool { true }", "fn(a: string, b: [int] = [1], ...c: [any]) -> bool true"},
+		{"fn add(a: int, b: int) -> int { a + b }", "fn add(a: int, b: int) -> int (a + b)"},
+		{"let [a: int, b] = pair;", "let [a: int, b] = pair;"},
+	}
+
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		program := pars.ParseProgram()
+		checkForParserErrors(t, pars)
+		if program.String() != tt.expected {
+			t.Errorf("Expected %q. Got %q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestInvalidTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"let x: = 1;", "expected a type, received ="},
+		{"let x: [int = 1;", "expected next token to be ], received ="},
+		{"let x: {string} = 1;", "expected next token to be :, received }"},
+		{"fn(a) -> 5 { a }", "expected a type, received INT"},
+		{"let f: fn(int, ) = 1;", "expected a type, received )"},
+	}
+
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		pars.ParseProgram()
+		if len(pars.Errors()) == 0 || pars.Errors()[0] != tt.expectedError {
+			t.Errorf("Expected first error for %q to be %q. Got %v", tt.input, tt.expectedError, pars.Errors())
+		}
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		line     int
+		column   int
+	}{
+		{"let x 5;", "expected next token to be =, received INT", 1, 7},
+		{"let a = 1;\nlet = 2;", "expected next token to be IDENT, received =", 2, 5},
+		{"1 + ;", "no prefix parse function for ';' found", 1, 5},
+		{"let [...a, b] = c;", "rest element must be the last element of an array pattern", 1, 10},
+		{"let s = \"abc", "unterminated string", 1, 9},
+	}
+
+	for _, tt := range tests {
+		pars := New(lexer.New(tt.input))
+		pars.ParseProgram()
+		errors := pars.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("Expected errors for %q", tt.input)
+			continue
+		}
+		err := errors[0]
+		if err.Message != tt.expected || err.Token.Line != tt.line || err.Token.Column != tt.column {
+			t.Errorf("Wrong first error for %q. Expected %q at %d:%d. Got %q at %d:%d",
+				tt.input, tt.expected, tt.line, tt.column, err.Message, err.Token.Line, err.Token.Column)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// Helpers for tests in other packages that need to parse Monkey code.
+// They live here so every package doesn't need its own copy.
+package parsertest
+
+import (
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/parser"
+	"testing"
+)
+
+// Parses input and fails the test if there were any parser errors
+func Parse(t testing.TB, input string) *ast.Program {
+	t.Helper()
+	pars := parser.New(lexer.New(input))
+	program := pars.ParseProgram()
+	if len(pars.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", pars.Errors())
+	}
+	return program
+}
@@ -0,0 +1,140 @@
+// Package render draws ASTs as an indented tree or as a Graphviz DOT graph.
+// This is mostly for learning and debugging: String() adds parentheses to
+// show precedence, but seeing the actual shape of the tree makes it much
+// clearer how parseExpression put things together.
+package render
+
+import (
+	"fmt"
+	"monkey-pl/ast"
+	"strings"
+)
+
+// Renders node as an indented tree using plain ASCII. For `1 + 2 * 3`:
+//
+//	Program
+//	`-- ExpressionStatement
+//	    `-- InfixExpression +
+//	        |-- IntegerLiteral 1
+//	        `-- InfixExpression *
+//	            |-- IntegerLiteral 2
+//	            `-- IntegerLiteral 3
+func Tree(node ast.Node) string {
+	root := build(node)
+	if root == nil {
+		return ""
+	}
+	var out strings.Builder
+	out.WriteString(root.label + "\n")
+	writeChildren(&out, root, "")
+	return out.String()
+}
+
+func writeChildren(out *strings.Builder, parent *treeNode, prefix string) {
+	for i, child := range parent.children {
+		branch, indent := "|-- ", "|   "
+		if i == len(parent.children)-1 {
+			branch, indent = "`-- ", "    "
+		}
+		out.WriteString(prefix + branch + child.label + "\n")
+		writeChildren(out, child, prefix+indent)
+	}
+}
+
+// Renders node as a Graphviz DOT graph. Pipe it into `dot -Tsvg` to get a
+// picture. Children are drawn left to right in source order.
+func Dot(node ast.Node) string {
+	root := build(node)
+	var out strings.Builder
+	out.WriteString("digraph AST {\n")
+	out.WriteString("  ordering=out;\n")
+	out.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	if root != nil {
+		id := 0
+		writeDotNode(&out, root, &id)
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// Returns the id of the node it wrote so the parent can draw an edge to it
+func writeDotNode(out *strings.Builder, node *treeNode, nextID *int) int {
+	id := *nextID
+	*nextID++
+	fmt.Fprintf(out, "  n%d [label=%s];\n", id, dotQuote(node.label))
+	for _, child := range node.children {
+		childID := writeDotNode(out, child, nextID)
+		fmt.Fprintf(out, "  n%d -> n%d;\n", id, childID)
+	}
+	return id
+}
+
+// DOT strings use the same escapes as Go for quotes and backslashes
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+type treeNode struct {
+	label    string
+	children []*treeNode
+}
+
+// Walk's enter and exit hooks let us keep a stack of the nodes
+// we're inside of. Each new node becomes a child of the top one.
+type treeBuilder struct {
+	root  *treeNode
+	stack []*treeNode
+}
+
+func build(node ast.Node) *treeNode {
+	builder := &treeBuilder{}
+	ast.Walk(node, builder)
+	return builder.root
+}
+
+func (b *treeBuilder) Enter(node ast.Node) ast.Visitor {
+	current := &treeNode{label: Label(node)}
+	if len(b.stack) == 0 {
+		b.root = current
+	} else {
+		parent := b.stack[len(b.stack)-1]
+		parent.children = append(parent.children, current)
+	}
+	b.stack = append(b.stack, current)
+	return b
+}
+
+func (b *treeBuilder) Exit(node ast.Node) {
+	b.stack = b.stack[:len(b.stack)-1]
+}
+
+// Returns a short description of a node: its type plus whatever
+// detail isn't shown by its children (operators, names and values)
+func Label(node ast.Node) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	switch node := node.(type) {
+	case *ast.Identifier:
+		return name + " " + node.Value
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("%s %d", name, node.Value)
+	case *ast.BooleanLiteral:
+		return fmt.Sprintf("%s %t", name, node.Value)
+	case *ast.StringLiteral:
+		return fmt.Sprintf("%s %q", name, node.Value)
+	case *ast.PrefixExpression:
+		return name + " " + node.Operator
+	case *ast.InfixExpression:
+		return name + " " + node.Operator
+	case *ast.NamedType:
+		return name + " " + node.Name
+	case *ast.FunctionLiteral:
+		if node.Name != "" {
+			return name + " " + node.Name
+		}
+		return name
+	default:
+		return name
+	}
+}
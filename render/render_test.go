@@ -0,0 +1,68 @@
+package render
+
+import (
+	"monkey-pl/parser/parsertest"
+	"testing"
+)
+
+func TestTree(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"1 + 2 * 3",
+			"Program\n" +
+				"`-- ExpressionStatement\n" +
+				"    `-- InfixExpression +\n" +
+				"        |-- IntegerLiteral 1\n" +
+				"        `-- InfixExpression *\n" +
+				"            |-- IntegerLiteral 2\n" +
+				"            `-- IntegerLiteral 3\n",
+		},
+		{
+			"let f = fn(x) { -x }; f(\"a\")",
+			"Program\n" +
+				"|-- LetStatement\n" +
+				"|   |-- Identifier f\n" +
+				"|   `-- FunctionLiteral\n" +
+				"|       |-- Identifier x\n" +
+				"|       `-- BlockStatement\n" +
+				"|           `-- ExpressionStatement\n" +
+				"|               `-- PrefixExpression -\n" +
+				"|                   `-- Identifier x\n" +
+				"`-- ExpressionStatement\n" +
+				"    `-- CallExpression\n" +
+				"        |-- Identifier f\n" +
+				"        `-- StringLiteral \"a\"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		actual := Tree(parsertest.Parse(t, tt.input))
+		if actual != tt.expected {
+			t.Errorf("Tree(%q) wrong.\nExpected:\n%s\nGot:\n%s", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestDot(t *testing.T) {
+	expected := `digraph AST {
+  ordering=out;
+  node [shape=box, fontname="monospace"];
+  n0 [label="Program"];
+  n1 [label="ExpressionStatement"];
+  n2 [label="InfixExpression =="];
+  n3 [label="StringLiteral \"a\\\\b\""];
+  n2 -> n3;
+  n4 [label="BooleanLiteral true"];
+  n2 -> n4;
+  n1 -> n2;
+  n0 -> n1;
+}
+`
+	actual := Dot(parsertest.Parse(t, `"a\b" == true`))
+	if actual != expected {
+		t.Errorf("Dot wrong.\nExpected:\n%s\nGot:\n%s", expected, actual)
+	}
+}
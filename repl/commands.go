@@ -0,0 +1,256 @@
+package repl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"monkey-pl/ast"
+	"monkey-pl/lexer"
+	"monkey-pl/object"
+	"monkey-pl/parser"
+	"monkey-pl/render"
+	"monkey-pl/token"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const HELP = `Meta-commands:
+  :help           show this message
+  :env            list everything that's been defined
+  :tokens <code>  show the tokens the lexer makes from some code
+  :ast <code>     show the AST for some code as JSON
+  :tree <code>    show the AST for some code as a tree
+  :dot <code>     show the AST for some code as a Graphviz graph
+  :type <expr>    show the type the checker works out for an expression
+  :time <code>    run some code and show how long it took and how much it allocated
+  :load <file>    run a file in this session
+  :save <file>    save everything that ran without errors to a file
+  :reset          forget everything that's been defined
+  :exit           leave the repl
+`
+
+// Runs a meta-command like `:env` or `:type x`. Returns false when
+// the repl should stop.
+func (s *session) command(line string) bool {
+	command, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+
+	switch command {
+	case ":exit":
+		io.WriteString(s.out, "\n🐵 See you next time!!! 🐵\n")
+		return false
+	case ":help":
+		io.WriteString(s.out, HELP)
+	case ":env":
+		s.printEnv()
+	case ":reset":
+		*s = *newSession(s.out)
+		io.WriteString(s.out, "🙉 Everything has been forgotten 🙉\n")
+	case ":tokens", ":ast", ":tree", ":dot", ":type", ":time", ":load", ":save":
+		if argument == "" {
+			fmt.Fprintf(s.out, "usage: %s\n", usage(command))
+			return true
+		}
+		s.commandWithArgument(command, argument)
+	default:
+		fmt.Fprintf(s.out, "🙊 Unknown command %s. Try :help 🙊\n", command)
+	}
+	return true
+}
+
+func (s *session) commandWithArgument(command string, argument string) {
+	switch command {
+	case ":tokens":
+		printTokens(s.out, argument)
+	case ":ast", ":tree", ":dot":
+		printRenderedAst(s.out, command, argument)
+	case ":type":
+		s.printType(argument)
+	case ":time":
+		s.timeCode(argument)
+	case ":load":
+		s.load(argument)
+	case ":save":
+		s.save(argument)
+	}
+}
+
+// Pulls the usage line for a command out of the help message
+func usage(command string) string {
+	for _, line := range strings.Split(HELP, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == command && strings.HasPrefix(fields[1], "<") {
+			return command + " " + fields[1]
+		}
+	}
+	return command
+}
+
+func (s *session) printEnv() {
+	names := s.env.Names()
+	macros := s.macroEnv.Names()
+	if len(names) == 0 && len(macros) == 0 {
+		io.WriteString(s.out, "Nothing has been defined yet\n")
+		return
+	}
+	for _, name := range names {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, summarize(value))
+	}
+	for _, name := range macros {
+		fmt.Fprintf(s.out, "%s (macro)\n", name)
+	}
+}
+
+// Functions print their whole body, which is too much for a listing,
+// so anything that takes more than one line is cut short
+func summarize(value object.Object) string {
+	inspected := value.Inspect()
+	if firstLine, _, ok := strings.Cut(inspected, "\n"); ok {
+		return strings.TrimSuffix(firstLine, " {") + " { ... }"
+	}
+	return inspected
+}
+
+func printTokens(out io.Writer, code string) {
+	lex := lexer.New(code)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		fmt.Fprintf(out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+	}
+}
+
+// `:ast`, `:tree` and `:dot` show the AST for some code without
+// running it. Handy for seeing how precedence affects the tree.
+func printRenderedAst(out io.Writer, command string, code string) {
+	program, ok := parse(out, code)
+	if !ok {
+		return
+	}
+	switch command {
+	case ":dot":
+		io.WriteString(out, render.Dot(program))
+	case ":tree":
+		io.WriteString(out, render.Tree(program))
+	default:
+		encoded, err := ast.EncodeJSON(program)
+		if err != nil {
+			fmt.Fprintf(out, "Error: %s\n", err)
+			return
+		}
+		var indented bytes.Buffer
+		json.Indent(&indented, encoded, "", "  ")
+		indented.WriteString("\n")
+		out.Write(indented.Bytes())
+	}
+}
+
+func parse(out io.Writer, code string) (*ast.Program, bool) {
+	pars := parser.New(lexer.New(code))
+	program := pars.ParseProgram()
+	if len(pars.Errors()) != 0 {
+		printParserErrors(out, pars.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// Uses the session's checker, so names defined earlier have their types
+func (s *session) printType(code string) {
+	program, ok := parse(s.out, code)
+	if !ok {
+		return
+	}
+	if len(program.Statements) != 1 {
+		io.WriteString(s.out, "usage: :type <expr>\n")
+		return
+	}
+	statement, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		io.WriteString(s.out, "usage: :type <expr>\n")
+		return
+	}
+	typ, typeErrors := s.typeChecker.TypeOf(statement.Expression)
+	if len(typeErrors) != 0 {
+		printTypeErrors(s.out, typeErrors)
+		return
+	}
+	io.WriteString(s.out, yellow(typ.String())+"\n")
+}
+
+// Runs code like any other input but also reports how long it took and
+// how many allocations it made. The numbers include parsing and checking.
+func (s *session) timeCode(code string) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	evaluated, ok := s.evaluate(code)
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	if ok {
+		s.transcript = append(s.transcript, code)
+	}
+	s.printResult(evaluated)
+	fmt.Fprintf(s.out, "⏱  %s, %d allocations (%s)\n", elapsed, after.Mallocs-before.Mallocs, formatBytes(after.TotalAlloc-before.TotalAlloc))
+}
+
+func formatBytes(bytes uint64) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
+
+// Runs a file as if it had been typed in. Its definitions stay around
+// afterwards, which is the point.
+func (s *session) load(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "🙊 Couldn't load %s: %s 🙊\n", path, err)
+		return
+	}
+	evaluated, ok := s.evaluate(string(source))
+	if ok {
+		s.transcript = append(s.transcript, strings.TrimRight(string(source), "\n"))
+	}
+	s.printResult(evaluated)
+}
+
+// Writes every input that ran without errors to a file, so a session
+// can be turned into a script (or loaded again later with `:load`)
+func (s *session) save(path string) {
+	contents := ""
+	for _, input := range s.transcript {
+		contents += terminate(input) + "\n"
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		fmt.Fprintf(s.out, "🙊 Couldn't save %s: %s 🙊\n", path, err)
+		return
+	}
+	fmt.Fprintf(s.out, "Saved %d inputs to %s\n", len(s.transcript), path)
+}
+
+// The repl runs each input on its own but a saved file is parsed all at
+// once, so an input has to end with a semicolon or the next one could
+// continue it (e.g. `let a = [1]` followed by `[0]` would index the array).
+// The semicolon goes right after the last token so a trailing comment
+// doesn't swallow it.
+func terminate(input string) string {
+	lex := lexer.NewWithTrivia(input)
+	var last token.Token
+	tok := lex.NextToken()
+	for ; tok.Type != token.EOF; tok = lex.NextToken() {
+		last = tok
+	}
+	if last.Type == "" || last.Type == token.SEMICOLON {
+		return input
+	}
+	end := len(input) - len(last.TrailingTrivia) - len(tok.LeadingTrivia)
+	return input[:end] + ";" + input[end:]
+}
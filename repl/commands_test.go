@@ -0,0 +1,94 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runRepl(input string) string {
+	out := &bytes.Buffer{}
+	Start(strings.NewReader(input), out)
+	return out.String()
+}
+
+func TestMetaCommands(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{":help\n", []string{":env", ":tokens <code>", ":save <file>"}},
+		{":env\n", []string{"Nothing has been defined yet"}},
+		{"let x = 1; let add = fn(a, b) { a + b };\n:env\n", []string{"add = fn add(a, b) { ... }\n", "x = 1\n"}},
+		{"let m = macro(a) { a };\n:env\n", []string{"m (macro)"}},
+		{":tokens let x = \"hi\";\n", []string{"1:1\tLET\t\"let\"", "1:9\tSTRING\t\"hi\"", "1:13\t;\t\";\""}},
+		{":ast 1 + 2\n", []string{`"type": "InfixExpression"`}},
+		{":tree 1 + 2\n", []string{"InfixExpression"}},
+		{":ast let = ;\n", []string{"parser errors"}},
+		{"let greet = fn(name: string) -> string { name };\n:type greet\n", []string{"fn(string) -> string"}},
+		{":type [1, 2]\n", []string{"[int]"}},
+		{":type 1 + \"a\"\n", []string{"operator + is not supported for int and string"}},
+		{":type let x = 1;\n", []string{"usage: :type <expr>"}},
+		{":time 1 + 2\n", []string{"3", "allocations ("}},
+		{"let x = 1;\n:reset\nx\n", []string{"Everything has been forgotten", "identifier not found: x"}},
+		{":type\n", []string{"usage: :type <expr>"}},
+		{":load\n", []string{"usage: :load <file>"}},
+		{":nope\n", []string{"Unknown command :nope"}},
+		{":exit\n1 + 1\n", []string{"See you next time"}},
+	}
+
+	for _, tt := range tests {
+		output := runRepl(tt.input)
+		for _, expected := range tt.expected {
+			if !strings.Contains(output, expected) {
+				t.Errorf("input %q: expected output to contain %q. got=%q", tt.input, expected, output)
+			}
+		}
+	}
+
+	if output := runRepl(":exit\n1 + 1\n"); strings.Contains(output, "2") {
+		t.Errorf("expected nothing to run after :exit. got=%q", output)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "session.mk")
+
+	output := runRepl("let x = 1;\ny\nlet y = x +;\nlet double = fn(n) { n * 2 };\n:save " + saved + "\n")
+	if !strings.Contains(output, "Saved 2 inputs") {
+		t.Errorf("expected only inputs without errors to be saved. got=%q", output)
+	}
+	contents, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	expected := "let x = 1;\nlet double = fn(n) { n * 2 };\n"
+	if string(contents) != expected {
+		t.Errorf("expected saved file to be %q. got=%q", expected, contents)
+	}
+
+	output = runRepl(":load " + saved + "\ndouble(x + 20)\n")
+	if !strings.Contains(output, "42") {
+		t.Errorf("expected definitions from the loaded file to be usable. got=%q", output)
+	}
+	// Inputs without semicolons shouldn't run into each other when loaded
+	runRepl("let a = [1]\n[0]\n-1\nlen(a) # one\n(a)\n:save " + saved + "\n")
+	contents, _ = os.ReadFile(saved)
+	expected = "let a = [1];\n[0];\n-1;\nlen(a); # one\n(a);\n"
+	if string(contents) != expected {
+		t.Errorf("expected saved file to be %q. got=%q", expected, contents)
+	}
+	output = runRepl(":load " + saved + "\na\n")
+	// once for the last line of the file and once for `a`
+	if strings.Count(output, "[1]") != 2 {
+		t.Errorf("expected a to still be [1] after loading. got=%q", output)
+	}
+
+	output = runRepl(":load " + filepath.Join(dir, "missing.mk") + "\n")
+	if !strings.Contains(output, "Couldn't load") {
+		t.Errorf("expected an error for a missing file. got=%q", output)
+	}
+}
@@ -0,0 +1,71 @@
+package repl
+
+import (
+	"monkey-pl/lexer"
+	"monkey-pl/token"
+	"strings"
+)
+
+const CONTINUATION_PROMPT = "🐒 .. "
+
+// Tokens that can't end a statement, so a line ending with one of them
+// must continue on the next line
+var continuesStatement = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.GT:       true,
+	token.EQ:       true,
+	token.NEQ:      true,
+	token.COMMA:    true,
+	token.COLON:    true,
+	token.ELLIPSIS: true,
+	token.ARROW:    true,
+	token.FUNCTION: true,
+	token.LET:      true,
+	token.IF:       true,
+	token.ELSE:     true,
+	token.WHILE:    true,
+	token.MACRO:    true,
+}
+
+// Reports whether input is the start of a statement that continues on the
+// next line: it has brackets that haven't been closed, a string that hasn't
+// been closed or it ends with an operator.
+//
+// Input with a closing bracket that doesn't match anything is never
+// incomplete. More lines can't fix it so it's better to show the error.
+func isIncomplete(input string) bool {
+	lex := lexer.New(input)
+	open := []token.TokenType{}
+	var last token.Token
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			open = append(open, tok.Type)
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if len(open) == 0 || open[len(open)-1] != opening[tok.Type] {
+				return false
+			}
+			open = open[:len(open)-1]
+		case token.ILLEGAL:
+			// the lexer gives back unterminated strings as ILLEGAL tokens
+			// starting with a quote. They always run to the end of the input.
+			if strings.HasPrefix(tok.Literal, `"`) {
+				return true
+			}
+		}
+		last = tok
+	}
+	return len(open) > 0 || continuesStatement[last.Type]
+}
+
+var opening = map[token.TokenType]token.TokenType{
+	token.RPAREN:   token.LPAREN,
+	token.RBRACE:   token.LBRACE,
+	token.RBRACKET: token.LBRACKET,
+}
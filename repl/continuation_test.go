@@ -0,0 +1,35 @@
+package repl
+
+import "testing"
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"", false},
+		{"# just a comment", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n  a + b\n}", false},
+		{"[1, 2,", true},
+		{"[1, 2,\n3]", false},
+		{"f(1, {\"a\": [", true},
+		{`let s = "abc`, true},
+		{"let s = \"abc\ndef\"", false},
+		{"let x = 1 +", true},
+		{"let x =", true},
+		{"let f = fn(x: int) ->", true},
+		{"if (x) { 1 } else", true},
+		{"let x = 5 # a comment (with an open paren", false},
+		{"let x = 1 + # comment", true},
+		// more lines can't fix these so they are sent to the parser
+		{"let x = 1; }", false},
+		{"(1]", false},
+	}
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) wrong. Expected %t. Got %t", tt.input, tt.expected, got)
+		}
+	}
+}
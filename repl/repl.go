@@ -1,14 +1,19 @@
 package repl
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"monkey-pl/checker"
 	"monkey-pl/evaluator"
+	"monkey-pl/highlight"
 	"monkey-pl/lexer"
+	"monkey-pl/lineedit"
 	"monkey-pl/object"
 	"monkey-pl/parser"
+	"monkey-pl/token"
 	"runtime"
+	"strings"
 )
 
 func getEvalOutputColor() []string {
@@ -24,37 +29,149 @@ func getEvalOutputColor() []string {
 const PROMPT = "🐒 >> "
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	evalColorCodes := getEvalOutputColor()
-	yellow := func(str string) string {
-		return fmt.Sprintf("%s%s%s", evalColorCodes[0], str, evalColorCodes[1])
+	editor := lineedit.New(in, out)
+	session := newSession(out)
+	if editor.IsTerminal() {
+		setUpEditor(editor, session)
 	}
-	// this will allow let definitions to continue to be remembered
-	env := object.NewEnvironment()
 	for {
-		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		line, err := editor.ReadLine(PROMPT)
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			continue
 		}
-		line := scanner.Text()
-		if line == ":exit" {
-			io.WriteString(out, "\n🐵 See you next time!!! 🐵\n")
+		if err != nil {
 			return
 		}
-		lex := lexer.New(line)
-		pars := parser.New(lex)
-		program := pars.ParseProgram()
-
-		if len(pars.Errors()) != 0 {
-			printParserErrors(out, pars.Errors())
+		// Monkey code never starts with `:` so this is always a meta-command
+		if strings.HasPrefix(strings.TrimSpace(line), ":") {
+			editor.History.Add(line)
+			if !session.command(strings.TrimSpace(line)) {
+				return
+			}
 			continue
 		}
+		// Keep reading until the statement is complete. A blank line
+		// gives up and shows whatever errors the input has.
+		interrupted := false
+		for isIncomplete(line) {
+			next, err := editor.ReadLine(CONTINUATION_PROMPT)
+			if errors.Is(err, lineedit.ErrInterrupted) {
+				interrupted = true
+				break
+			}
+			if err != nil {
+				return
+			}
+			if strings.TrimSpace(next) == "" {
+				break
+			}
+			line += "\n" + next
+		}
+		// The whole input goes into history as one entry so pressing up
+		// brings all of it back, even if Ctrl-C threw it away
+		editor.History.Add(line)
+		// Ctrl-C throws away the whole statement
+		if interrupted {
+			continue
+		}
+		if evaluated, ok := session.evaluate(line); ok {
+			session.transcript = append(session.transcript, line)
+			session.printResult(evaluated)
+		} else if evaluated != nil {
+			session.printResult(evaluated)
+		}
+	}
+}
+
+// Everything the repl remembers between inputs
+type session struct {
+	out io.Writer
+	// this will allow let definitions to continue to be remembered
+	env *object.Environment
+	// macros live in their own environment since they're only used
+	// during macro expansion, before anything is evaluated
+	macroEnv *object.Environment
+	// the checker also remembers the types of earlier definitions
+	typeChecker *checker.Checker
+	// Inputs that ran without errors, which is what `:save` writes out
+	transcript []string
+}
+
+func newSession(out io.Writer) *session {
+	return &session{
+		out:         out,
+		env:         object.NewEnvironment(),
+		macroEnv:    object.NewEnvironment(),
+		typeChecker: checker.New(),
+	}
+}
+
+// Parses, checks and runs some code. Syntax, type and macro errors are
+// printed here. ok is false if anything went wrong, including the code
+// evaluating to an error.
+func (s *session) evaluate(code string) (evaluated object.Object, ok bool) {
+	pars := parser.New(lexer.New(code))
+	program := pars.ParseProgram()
+
+	if len(pars.Errors()) != 0 {
+		printParserErrors(s.out, pars.Errors())
+		return nil, false
+	}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, yellow(evaluated.Inspect()))
-			io.WriteString(out, "\n")
+	if typeErrors := s.typeChecker.Check(program); len(typeErrors) != 0 {
+		printTypeErrors(s.out, typeErrors)
+		return nil, false
+	}
+
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		io.WriteString(s.out, yellow("Error: "+err.Error()))
+		io.WriteString(s.out, "\n")
+		return nil, false
+	}
+
+	evaluated = evaluator.Eval(expanded, s.env)
+	return evaluated, evaluated == nil || evaluated.Type() != object.ERROR_OBJ
+}
+
+func (s *session) printResult(evaluated object.Object) {
+	if evaluated != nil {
+		io.WriteString(s.out, yellow(object.Pretty(evaluated, object.DefaultPrettyOptions)))
+		io.WriteString(s.out, "\n")
+	}
+}
+
+func yellow(str string) string {
+	evalColorCodes := getEvalOutputColor()
+	return fmt.Sprintf("%s%s%s", evalColorCodes[0], str, evalColorCodes[1])
+}
+
+// History is saved in ~/.monkey_history. Completion knows about keywords,
+// builtins and everything defined so far, and what's typed is highlighted
+// unless the terminal can't do colors.
+func setUpEditor(editor *lineedit.Editor, s *session) {
+	if path, err := lineedit.DefaultHistoryPath(); err == nil {
+		// if the history file can't be read we can still
+		// keep history for this session
+		if history, err := lineedit.LoadHistory(path); err == nil {
+			editor.History = history
+		}
+	}
+	editor.Complete = func() []string {
+		words := append(token.Keywords(), evaluator.BuiltinNames()...)
+		words = append(words, "quote", "unquote")
+		// these are looked up each time since `:reset` replaces them
+		words = append(words, s.env.Names()...)
+		return append(words, s.macroEnv.Names()...)
+	}
+	if getEvalOutputColor()[0] != "" {
+		editor.Highlight = func(line string) string {
+			// meta commands aren't monkey code
+			if strings.HasPrefix(line, ":") {
+				return line
+			}
+			return highlight.ANSI(line)
 		}
 	}
 }
@@ -67,3 +184,12 @@ func printParserErrors(out io.Writer, errors []string) {
 	}
 	io.WriteString(out, "\n")
 }
+
+func printTypeErrors(out io.Writer, errors []checker.TypeError) {
+	io.WriteString(out, "\n🙈 Those types don't line up! 🙈\n")
+	io.WriteString(out, " type errors:\n")
+	for _, typeError := range errors {
+		io.WriteString(out, "\t"+typeError.String()+"\n")
+	}
+	io.WriteString(out, "\n")
+}
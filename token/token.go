@@ -1,10 +1,26 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	// Where the token starts in the source. Lines and columns start
+	// at 1 and Offset is the byte offset from the start of the input.
+	// Tokens that didn't come from the lexer (e.g. ones made during
+	// macro expansion) have all of these set to 0.
+	Line   int
+	Column int
+	Offset int
+	// Only filled in when the lexer is in trivia mode. Leading trivia is
+	// the whitespace and comments between the previous token and this one.
+	// Trailing trivia is whitespace and a comment after the token on the
+	// same line. Joining trivia and source text for every token gives
+	// back the original input.
+	LeadingTrivia  string
+	TrailingTrivia string
 }
 
 var keywords = map[string]TokenType{
@@ -16,6 +32,17 @@ var keywords = map[string]TokenType{
 	"else":   ELSE,
 	"while":  WHILE,
 	"return": RETURN,
+	"macro":  MACRO,
+}
+
+// Returns every keyword, sorted
+func Keywords() []string {
+	names := []string{}
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func LookupIdent(identifierLiteral string) TokenType {
@@ -54,6 +81,8 @@ const (
 	RBRACE    = "}"
 	LBRACKET  = "["
 	RBRACKET  = "]"
+	ELLIPSIS  = "..."
+	ARROW     = "->" // return type annotations
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -63,4 +92,5 @@ const (
 	ELSE     = "ELSE"
 	WHILE    = "WHILE"
 	RETURN   = "RETURN"
+	MACRO    = "MACRO"
 )